@@ -0,0 +1,123 @@
+// Command migrate manages the database schema separately from server
+// startup. It reads the same configuration as the server.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/yokitheyo/go_wallet_test/internal/config"
+	"github.com/yokitheyo/go_wallet_test/internal/logging"
+	"github.com/yokitheyo/go_wallet_test/internal/repo"
+)
+
+const usage = `usage: migrate <command>
+
+commands:
+  up       apply all pending migrations
+  down     roll back the last applied migration
+  redo     roll back the last applied migration and apply it again
+  status   list migrations and whether they are applied
+  version  print the current schema version and the latest migration
+`
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	os.Exit(run(os.Args[1]))
+}
+
+func run(command string) int {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		return 1
+	}
+	logger, _, err := logging.New(logging.Config{
+		Level:       cfg.Logging.Level,
+		Encoding:    "console",
+		OutputPaths: []string{"stderr"},
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer logger.Sync()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db, err := repo.OpenPrimary(ctx, cfg, logger)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+
+	migrator, err := repo.NewMigrator(db, repo.Migrations(cfg.DB.MigrationsDir))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	switch command {
+	case "up":
+		results, err := migrator.Up(ctx)
+		for _, res := range results {
+			fmt.Println(res)
+		}
+		if err == nil && len(results) == 0 {
+			fmt.Println("no pending migrations")
+		}
+		return report(err)
+	case "down":
+		res, err := migrator.Down(ctx)
+		if res != nil {
+			fmt.Println(res)
+		}
+		return report(err)
+	case "redo":
+		results, err := migrator.Redo(ctx)
+		for _, res := range results {
+			fmt.Println(res)
+		}
+		return report(err)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return report(err)
+		}
+		for _, st := range statuses {
+			applied := "pending"
+			if !st.AppliedAt.IsZero() {
+				applied = st.AppliedAt.Local().Format(time.DateTime)
+			}
+			fmt.Printf("%-20s %s\n", applied, st.Source.Path)
+		}
+		return 0
+	case "version":
+		current, latest, err := migrator.Version(ctx)
+		if err != nil {
+			return report(err)
+		}
+		fmt.Printf("current: %d\nlatest:  %d\n", current, latest)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		return 2
+	}
+}
+
+func report(err error) int {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/yokitheyo/go_wallet_test/internal/auth"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"github.com/yokitheyo/go_wallet_test/internal/repo"
+)
+
+// createAdminKey implements the "create-admin-key" subcommand. It refuses to
+// run when an active admin key already exists unless -force is given, so it is
+// safe to keep in deployment scripts.
+func createAdminKey(ctx context.Context, r *repo.Repo, args []string) error {
+	fs := flag.NewFlagSet("create-admin-key", flag.ContinueOnError)
+	name := fs.String("name", "bootstrap-admin", "name of the new key")
+	force := fs.Bool("force", false, "create the key even if an admin key already exists")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	exists, err := r.HasActiveAdminKey(ctx)
+	if err != nil {
+		return err
+	}
+	if exists && !*force {
+		return fmt.Errorf("an active admin key already exists; use -force to create another one")
+	}
+
+	plain, hash, prefix, err := auth.GenerateAPIKey()
+	if err != nil {
+		return err
+	}
+
+	key, err := r.CreateAPIKey(ctx, model.APIKey{
+		ID:     uuid.New(),
+		Name:   *name,
+		Prefix: prefix,
+		Scopes: []model.Scope{model.ScopeAdmin},
+	}, hash)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("created admin key %s (%s)\n%s\n", key.ID, key.Name, plain)
+	return nil
+}
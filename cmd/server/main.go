@@ -2,34 +2,72 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
-	"path/filepath"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/google/uuid"
 	_ "github.com/lib/pq"
-	"github.com/pressly/goose/v3"
+	"github.com/yokitheyo/go_wallet_test/internal/auth"
 	"github.com/yokitheyo/go_wallet_test/internal/config"
 	"github.com/yokitheyo/go_wallet_test/internal/handler"
+	"github.com/yokitheyo/go_wallet_test/internal/logging"
+	"github.com/yokitheyo/go_wallet_test/internal/metrics"
+	"github.com/yokitheyo/go_wallet_test/internal/middleware"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"github.com/yokitheyo/go_wallet_test/internal/outbox"
+	"github.com/yokitheyo/go_wallet_test/internal/ratelimit"
 	"github.com/yokitheyo/go_wallet_test/internal/repo"
+	"github.com/yokitheyo/go_wallet_test/internal/stream"
+	"github.com/yokitheyo/go_wallet_test/internal/tracing"
+	"github.com/yokitheyo/go_wallet_test/internal/webhook"
 	"go.uber.org/zap"
 )
 
 func main() {
-	logger, err := zap.NewProduction()
+	if len(os.Args) > 1 && os.Args[1] == "--print-config" {
+		os.Exit(printConfig())
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(1)
+	}
+
+	logger, logLevel, err := logging.New(logConfig(cfg))
 	if err != nil {
 		panic("failed to initialize zap logger: " + err.Error())
 	}
 	defer logger.Sync()
 
-	cfg, err := config.Load()
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		FilePath:    cfg.Tracing.FilePath,
+		ServiceName: cfg.Tracing.ServiceName,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
 	if err != nil {
-		logger.Fatal("failed to load config", zap.Error(err))
+		logger.Fatal("failed to set up tracing", zap.Error(err))
 	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Error("failed to flush traces", zap.Error(err))
+		}
+	}()
 
-	repository, err := repo.NewPostgres(cfg)
+	// Let SIGTERM interrupt waiting for the database and the migration
+	// lock during startup.
+	startCtx, stopStart := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	repository, err := repo.NewPostgres(startCtx, cfg, logger)
 	if err != nil {
 		logger.Fatal("db connect error", zap.Error(err))
 	}
@@ -41,30 +79,205 @@ func main() {
 		}
 	}()
 
-	db := repository.DB()
+	if err := migrate(startCtx, repository.DB(), cfg.DB, logger); err != nil {
+		logger.Fatal("database migrations failed", zap.Error(err))
+	}
+	stopStart()
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "create-admin-key":
+			if err := createAdminKey(context.Background(), repository, os.Args[2:]); err != nil {
+				logger.Fatal("failed to create admin key", zap.Error(err))
+			}
+			return
+		default:
+			logger.Fatal("unknown command", zap.String("command", os.Args[1]))
+		}
+	}
+
+	publisher, closePublisher, err := newPublisher(cfg)
+	if err != nil {
+		logger.Fatal("failed to create outbox publisher", zap.Error(err))
+	}
+	defer closePublisher()
+
+	publisher = outbox.MultiPublisher{publisher, webhook.NewPublisher(repository)}
+
+	relay := outbox.NewRelay(repository, publisher, outbox.RelayConfig{
+		PollInterval:  cfg.Outbox.PollInterval,
+		BatchSize:     cfg.Outbox.BatchSize,
+		MinBackoff:    cfg.Outbox.MinBackoff,
+		MaxBackoff:    cfg.Outbox.MaxBackoff,
+		Retention:     cfg.Outbox.Retention,
+		PruneInterval: cfg.Outbox.PruneInterval,
+	}, logger)
+	webhookWorker := webhook.NewWorker(repository, webhook.WorkerConfig{
+		PollInterval: cfg.Webhook.PollInterval,
+		BatchSize:    cfg.Webhook.BatchSize,
+		Concurrency:  cfg.Webhook.Concurrency,
+		Timeout:      cfg.Webhook.Timeout,
+		MaxAttempts:  cfg.Webhook.MaxAttempts,
+		DisableAfter: cfg.Webhook.DisableAfter,
+		MinBackoff:   cfg.Webhook.MinBackoff,
+		MaxBackoff:   cfg.Webhook.MaxBackoff,
+	}, logger)
+
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	workers.Add(2)
+	go func() {
+		defer workers.Done()
+		relay.Run(workersCtx)
+	}()
+	go func() {
+		defer workers.Done()
+		webhookWorker.Run(workersCtx)
+	}()
+	if len(cfg.DB.Replicas) > 0 {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			repository.MonitorReplicas(workersCtx, cfg.DB.ReplicaCheckInterval, cfg.DB.ReplicaMaxLag, logger)
+		}()
+	}
+
+	broker := stream.NewBroker(cfg.Stream.BufferSize)
+	switch cfg.Stream.Fanout {
+	case "local":
+		repository.OnBalanceChange(broker.Publish)
+	case "postgres":
+		dsn, err := repo.DSN(cfg)
+		if err != nil {
+			logger.Fatal("invalid database config", zap.Error(err))
+		}
+		listener := stream.NewListener(stream.NewPQListener(dsn, logger), repository, broker, repo.BalanceChannel, logger)
+		if err := listener.Start(context.Background()); err != nil {
+			logger.Fatal("failed to start balance listener", zap.Error(err))
+		}
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			listener.Run(workersCtx)
+		}()
+	default:
+		logger.Fatal("unknown stream fanout", zap.String("fanout", cfg.Stream.Fanout))
+	}
 
-	migrationsDir := filepath.Join(".", "migrations")
-	if err := goose.SetDialect("postgres"); err != nil {
-		logger.Fatal("goose set dialect error", zap.Error(err))
+	var tokens middleware.TokenVerifier
+	if cfg.Auth.JWT.Enabled {
+		jwks, err := auth.LoadJWKS(cfg.Auth.JWT.JWKSFile)
+		if err != nil {
+			logger.Fatal("failed to load jwks", zap.Error(err))
+		}
+		tokens = auth.NewJWTVerifier(jwks, cfg.Auth.JWT.Issuer, cfg.Auth.JWT.Audience, cfg.Auth.JWT.Leeway)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			jwks.Watch(workersCtx, cfg.Auth.JWT.ReloadInterval, logger)
+		}()
 	}
 
-	if err := goose.Up(db, migrationsDir); err != nil {
-		logger.Fatal("goose migrate error", zap.Error(err))
+	var signatures *middleware.SignatureAuth
+	if cfg.Auth.HMAC.Enabled {
+		clients, err := signedClients(cfg.Auth.HMAC.Clients)
+		if err != nil {
+			logger.Fatal("invalid hmac clients", zap.Error(err))
+		}
+		var nonces middleware.NonceStore
+		switch cfg.Auth.HMAC.NonceStore {
+		case "memory":
+			nonces = middleware.NewMemoryNonces()
+		case "postgres":
+			pg := middleware.NewPostgresNonces(repository, logger)
+			workers.Add(1)
+			go func() {
+				defer workers.Done()
+				pg.Run(workersCtx, cfg.Auth.HMAC.ClockSkew)
+			}()
+			nonces = pg
+		default:
+			logger.Fatal("unknown nonce store", zap.String("nonce_store", cfg.Auth.HMAC.NonceStore))
+		}
+		signatures = middleware.NewSignatureAuth(clients, cfg.Auth.HMAC.ClockSkew, nonces, logger)
 	}
-	logger.Info("database migrations completed successfully")
+
+	var rateLimit *middleware.RateLimit
+	if rl := cfg.Limits.RateLimit; rl.Enabled {
+		limits := rateLimits(rl)
+		var limiter ratelimit.Limiter
+		switch rl.Backend {
+		case "memory":
+			limiter = ratelimit.NewMemoryLimiter()
+		case "postgres":
+			pg := ratelimit.NewPostgresLimiter(repository, logger)
+			idle := max(limits.IP.FillTime(), limits.Client.FillTime(), limits.Wallet.FillTime())
+			workers.Add(1)
+			go func() {
+				defer workers.Done()
+				pg.Run(workersCtx, rl.PruneInterval, idle)
+			}()
+			limiter = pg
+		default:
+			logger.Fatal("unknown rate limit backend", zap.String("backend", rl.Backend))
+		}
+		rateLimit = middleware.NewRateLimit(limiter, limits, logger)
+	}
+
+	m := metrics.New()
+	m.RegisterDBStats(repository.DB())
+	m.RegisterQueues(repository)
+	m.RegisterBreaker(func() int { return int(repository.BreakerState()) })
 
 	// Передаем интерфейсы вместо конкретных типов
-	router, gracefulShutdown := handler.NewRouter(repository, logger)
+	settings := config.NewWatcher(cfg, logger)
+	settings.OnReload(func(old, new *config.Config) {
+		if old.Logging.Level != new.Logging.Level {
+			l, _ := logging.ParseLevel(new.Logging.Level)
+			logLevel.SetLevel(l)
+		}
+		if rateLimit != nil {
+			rateLimit.SetLimits(rateLimits(new.Limits.RateLimit))
+		}
+	})
+	settings.Watch()
+
+	router, gracefulShutdown := handler.NewRouter(repository, broker, tokens, signatures, rateLimit, m, settings, logger)
 
-	addr := ":" + cfg.HTTPPort
+	addr := ":" + cfg.HTTP.Port
 	server := &http.Server{
 		Addr:         addr,
 		Handler:      router,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		ReadTimeout:  cfg.HTTP.ReadTimeout,
+		WriteTimeout: cfg.HTTP.WriteTimeout,
+		IdleTimeout:  cfg.HTTP.IdleTimeout,
 	}
 
+	adminAddr := ":" + cfg.Admin.Port
+	adminServer := &http.Server{
+		Addr:        adminAddr,
+		Handler:     handler.NewAdminRouter(repository, m, logLevel, gracefulShutdown, cfg, logger),
+		ReadTimeout: cfg.HTTP.ReadTimeout,
+		// pprof profiles and traces stream for as long as requested.
+		WriteTimeout: cfg.Admin.WriteTimeout,
+		IdleTimeout:  cfg.HTTP.IdleTimeout,
+	}
+
+	// SIGHUP forces a reload where file change notifications do not arrive,
+	// e.g. on some mounted volumes.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-workersCtx.Done():
+				return
+			case <-hup:
+				settings.Reload()
+			}
+		}
+	}()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
@@ -74,27 +287,160 @@ func main() {
 			logger.Fatal("failed to run server", zap.Error(err))
 		}
 	}()
+	go func() {
+		logger.Info("Starting admin server", zap.String("address", adminAddr))
+		if err := adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Fatal("failed to run admin server", zap.Error(err))
+		}
+	}()
 
 	sig := <-quit
 	logger.Info("Received shutdown signal", zap.String("signal", sig.String()))
 
 	logger.Info("Starting graceful shutdown...")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
 	defer cancel()
 
 	if err := gracefulShutdown.Shutdown(ctx); err != nil {
 		logger.Warn("Graceful shutdown middleware timeout", zap.Error(err))
 	}
 
-	if err := server.Shutdown(ctx); err != nil {
-		logger.Error("Server forced to shutdown", zap.Error(err))
-	} else {
-		logger.Info("Server exited gracefully")
+	// The admin server stays up while public requests drain so probes and
+	// metrics keep working, then both listeners are closed together.
+	var servers sync.WaitGroup
+	for name, srv := range map[string]*http.Server{"public": server, "admin": adminServer} {
+		servers.Add(1)
+		go func() {
+			defer servers.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				logger.Error("Server forced to shutdown", zap.String("server", name), zap.Error(err))
+			} else {
+				logger.Info("Server exited gracefully", zap.String("server", name))
+			}
+		}()
 	}
+	servers.Wait()
+
+	stopWorkers()
+	workers.Wait()
+	broker.Close()
 
 	logger.Info("Waiting for active operations to complete...")
 	time.Sleep(2 * time.Second)
 
 	logger.Info("Shutdown completed")
 }
+
+// printConfig writes the effective configuration with secrets masked,
+// followed by any validation problems, and returns the exit code.
+func printConfig() int {
+	cfg, err := config.Read()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := cfg.WriteYAML(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// migrate applies, verifies or skips migrations as cfg.Migrate says.
+func migrate(ctx context.Context, db *sql.DB, cfg config.DBConfig, logger *zap.Logger) error {
+	if cfg.Migrate == "skip" {
+		logger.Info("database migrations skipped")
+		return nil
+	}
+	migrator, err := repo.NewMigrator(db, repo.Migrations(cfg.MigrationsDir))
+	if err != nil {
+		return err
+	}
+	if cfg.Migrate == "verify" {
+		if err := migrator.Verify(ctx); err != nil {
+			return err
+		}
+		logger.Info("database schema is up to date")
+		return nil
+	}
+	results, err := migrator.Up(ctx)
+	for _, res := range results {
+		logger.Info("migration applied", zap.String("migration", res.Source.Path), zap.Duration("duration", res.Duration))
+	}
+	if err != nil {
+		return err
+	}
+	logger.Info("database migrations completed successfully", zap.Int("applied", len(results)))
+	return nil
+}
+
+func logConfig(cfg *config.Config) logging.Config {
+	return logging.Config{
+		Level:              cfg.Logging.Level,
+		Encoding:           cfg.Logging.Encoding,
+		OutputPaths:        cfg.Logging.OutputPaths,
+		SamplingInitial:    cfg.Logging.Sampling.Initial,
+		SamplingThereafter: cfg.Logging.Sampling.Thereafter,
+	}
+}
+
+func rateLimits(rl config.RateLimitConfig) middleware.RateLimits {
+	return middleware.RateLimits{
+		IP:     ratelimit.Limit{Rate: rl.IP.Rate, Burst: rl.IP.Burst},
+		Client: ratelimit.Limit{Rate: rl.Client.Rate, Burst: rl.Client.Burst},
+		Wallet: ratelimit.Limit{Rate: rl.Wallet.Rate, Burst: rl.Wallet.Burst},
+	}
+}
+
+func newPublisher(cfg *config.Config) (outbox.Publisher, func() error, error) {
+	noop := func() error { return nil }
+	switch cfg.Outbox.Publisher {
+	case "stdout", "":
+		return outbox.NewStdoutPublisher(), noop, nil
+	case "file":
+		p, f, err := outbox.NewFilePublisher(cfg.Outbox.FilePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return p, f.Close, nil
+	case "http":
+		return outbox.NewHTTPPublisher(cfg.Outbox.HTTPURL, cfg.Outbox.HTTPTimeout), noop, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown outbox publisher %q", cfg.Outbox.Publisher)
+	}
+}
+
+func signedClients(cfgClients []config.HMACClient) (map[string]middleware.SignedClient, error) {
+	clients := make(map[string]middleware.SignedClient, len(cfgClients))
+	for _, c := range cfgClients {
+		if c.ID == "" || c.Secret == "" {
+			return nil, fmt.Errorf("hmac client requires id and secret")
+		}
+		if _, ok := clients[c.ID]; ok {
+			return nil, fmt.Errorf("duplicate hmac client %q", c.ID)
+		}
+
+		p := model.Principal{Kind: model.PrincipalHMAC, ID: c.ID, AllWallets: len(c.WalletIDs) == 0}
+		for _, s := range c.Scopes {
+			scope := model.Scope(s)
+			if !scope.Valid() {
+				return nil, fmt.Errorf("hmac client %q: unknown scope %q", c.ID, s)
+			}
+			p.Scopes = append(p.Scopes, scope)
+		}
+		for _, raw := range c.WalletIDs {
+			id, err := uuid.Parse(raw)
+			if err != nil {
+				return nil, fmt.Errorf("hmac client %q: invalid wallet id %q", c.ID, raw)
+			}
+			p.WalletIDs = append(p.WalletIDs, id)
+		}
+		clients[c.ID] = middleware.SignedClient{Secret: c.Secret, Principal: p}
+	}
+	return clients, nil
+}
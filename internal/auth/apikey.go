@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	apiKeyPrefix = "wk_"
+	prefixLen    = len(apiKeyPrefix) + 8
+)
+
+// GenerateAPIKey returns a new plaintext key together with the values that are
+// stored: its SHA-256 hash and a short display prefix. Keys carry 256 bits of
+// entropy, so a fast hash is enough and keeps per-request lookups cheap.
+func GenerateAPIKey() (key, hash, prefix string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	key = apiKeyPrefix + hex.EncodeToString(buf)
+	return key, HashAPIKey(key), key[:prefixLen], nil
+}
+
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,23 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, hash, prefix, err := GenerateAPIKey()
+	assert.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(key, "wk_"))
+	assert.Len(t, key, 3+64)
+	assert.Equal(t, HashAPIKey(key), hash)
+	assert.True(t, strings.HasPrefix(key, prefix))
+	assert.NotContains(t, hash, key)
+
+	other, _, _, err := GenerateAPIKey()
+	assert.NoError(t, err)
+	assert.NotEqual(t, key, other)
+}
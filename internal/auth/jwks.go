@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// JWKS is a set of public keys loaded from a local JWKS file. Reloading
+// swaps the whole set atomically, which is how signing keys are rotated.
+type JWKS struct {
+	path string
+
+	mu      sync.RWMutex
+	keys    map[string]crypto.PublicKey
+	modTime time.Time
+}
+
+func LoadJWKS(path string) (*JWKS, error) {
+	ks := &JWKS{path: path}
+	if err := ks.Reload(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+func (ks *JWKS) Reload() error {
+	info, err := os.Stat(ks.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat jwks file: %w", err)
+	}
+	data, err := os.ReadFile(ks.path)
+	if err != nil {
+		return fmt.Errorf("failed to read jwks file: %w", err)
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return err
+	}
+
+	ks.mu.Lock()
+	ks.keys = keys
+	ks.modTime = info.ModTime()
+	ks.mu.Unlock()
+	return nil
+}
+
+func (ks *JWKS) ReloadIfChanged() (bool, error) {
+	info, err := os.Stat(ks.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat jwks file: %w", err)
+	}
+
+	ks.mu.RLock()
+	unchanged := info.ModTime().Equal(ks.modTime)
+	ks.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+	return true, ks.Reload()
+}
+
+// Watch polls the file and reloads it whenever its modification time changes.
+// A file that fails to parse leaves the previous keys in place.
+func (ks *JWKS) Watch(ctx context.Context, interval time.Duration, logger *zap.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := ks.ReloadIfChanged()
+			if err != nil {
+				logger.Error("failed to reload jwks", zap.String("path", ks.path), zap.Error(err))
+				continue
+			}
+			if changed {
+				logger.Info("jwks reloaded", zap.String("path", ks.path), zap.Int("keys", ks.Len()))
+			}
+		}
+	}
+}
+
+func (ks *JWKS) Key(kid string) (crypto.PublicKey, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	if key, ok := ks.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+}
+
+func (ks *JWKS) Len() int {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return len(ks.keys)
+}
+
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwk %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("jwks contains no signing keys")
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent: %w", err)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x coordinate: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y coordinate: %w", err)
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		if !key.Curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve")
+		}
+		return key, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+)
+
+var ErrNoWallets = errors.New("token grants access to no wallets")
+
+type walletClaims struct {
+	jwt.RegisteredClaims
+	Wallets []string `json:"wallets"`
+	Scope   string   `json:"scope"`
+}
+
+type JWTVerifier struct {
+	keys     *JWKS
+	issuer   string
+	audience string
+	leeway   time.Duration
+}
+
+func NewJWTVerifier(keys *JWKS, issuer, audience string, leeway time.Duration) *JWTVerifier {
+	return &JWTVerifier{keys: keys, issuer: issuer, audience: audience, leeway: leeway}
+}
+
+// Verify validates an RS256/ES256 token and returns the end user it
+// represents. The user may touch only the wallets in the "wallets" claim or,
+// when that is absent, the wallet whose ID is the subject. Tokens can narrow
+// access with a space-separated "scope" claim but never grant admin.
+func (v *JWTVerifier) Verify(token string) (model.Principal, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "ES256"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(v.leeway),
+	}
+	if v.issuer != "" {
+		opts = append(opts, jwt.WithIssuer(v.issuer))
+	}
+	if v.audience != "" {
+		opts = append(opts, jwt.WithAudience(v.audience))
+	}
+
+	var claims walletClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.keys.Key(kid)
+	}, opts...)
+	if err != nil {
+		return model.Principal{}, err
+	}
+
+	wallets, err := claimedWallets(claims)
+	if err != nil {
+		return model.Principal{}, err
+	}
+
+	return model.Principal{
+		Kind:      model.PrincipalJWT,
+		ID:        claims.Subject,
+		Scopes:    claimedScopes(claims.Scope),
+		WalletIDs: wallets,
+	}, nil
+}
+
+func claimedWallets(claims walletClaims) ([]uuid.UUID, error) {
+	if len(claims.Wallets) > 0 {
+		ids := make([]uuid.UUID, 0, len(claims.Wallets))
+		for _, w := range claims.Wallets {
+			id, err := uuid.Parse(w)
+			if err != nil {
+				return nil, fmt.Errorf("invalid wallet id %q in token: %w", w, err)
+			}
+			ids = append(ids, id)
+		}
+		return ids, nil
+	}
+	if id, err := uuid.Parse(claims.Subject); err == nil {
+		return []uuid.UUID{id}, nil
+	}
+	return nil, ErrNoWallets
+}
+
+func claimedScopes(scope string) []model.Scope {
+	if scope == "" {
+		return []model.Scope{model.ScopeWalletRead, model.ScopeWalletWrite}
+	}
+	var scopes []model.Scope
+	for _, s := range strings.Fields(scope) {
+		switch model.Scope(s) {
+		case model.ScopeWalletRead, model.ScopeWalletWrite:
+			scopes = append(scopes, model.Scope(s))
+		}
+	}
+	return scopes
+}
@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+)
+
+func b64(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func writeJWKS(t *testing.T, path string, keys ...map[string]string) {
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+}
+
+func rsaJWK(kid string, key *rsa.PrivateKey) map[string]string {
+	return map[string]string{"kty": "RSA", "kid": kid, "use": "sig", "n": b64(key.N), "e": b64(big.NewInt(int64(key.E)))}
+}
+
+func ecJWK(kid string, key *ecdsa.PrivateKey) map[string]string {
+	return map[string]string{"kty": "EC", "kid": kid, "crv": "P-256", "x": b64(key.X), "y": b64(key.Y)}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	s, err := token.SignedString(key)
+	require.NoError(t, err)
+	return s
+}
+
+func TestJWTVerifier_Verify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, path, rsaJWK("rsa-1", rsaKey), ecJWK("ec-1", ecKey))
+
+	keys, err := LoadJWKS(path)
+	require.NoError(t, err)
+	v := NewJWTVerifier(keys, "mobile-backend", "wallet", 0)
+
+	walletID := uuid.New()
+	exp := time.Now().Add(time.Hour).Unix()
+
+	t.Run("rs256 with subject wallet", func(t *testing.T) {
+		token := sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, jwt.MapClaims{
+			"sub": walletID.String(), "iss": "mobile-backend", "aud": "wallet", "exp": exp,
+		})
+		p, err := v.Verify(token)
+		require.NoError(t, err)
+		assert.Equal(t, model.PrincipalJWT, p.Kind)
+		assert.True(t, p.AllowsWallet(walletID))
+		assert.False(t, p.AllowsWallet(uuid.New()))
+		assert.True(t, p.HasScope(model.ScopeWalletWrite))
+		assert.False(t, p.HasScope(model.ScopeAdmin))
+	})
+
+	t.Run("es256 with wallets claim and narrowed scope", func(t *testing.T) {
+		other := uuid.New()
+		token := sign(t, jwt.SigningMethodES256, "ec-1", ecKey, jwt.MapClaims{
+			"sub": "user-42", "iss": "mobile-backend", "aud": "wallet", "exp": exp,
+			"wallets": []string{walletID.String(), other.String()}, "scope": "wallet:read admin",
+		})
+		p, err := v.Verify(token)
+		require.NoError(t, err)
+		assert.Equal(t, "user-42", p.ID)
+		assert.True(t, p.AllowsWallet(other))
+		assert.True(t, p.HasScope(model.ScopeWalletRead))
+		assert.False(t, p.HasScope(model.ScopeWalletWrite))
+	})
+
+	t.Run("rejects", func(t *testing.T) {
+		cases := map[string]string{
+			"expired": sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, jwt.MapClaims{
+				"sub": walletID.String(), "iss": "mobile-backend", "aud": "wallet", "exp": time.Now().Add(-time.Hour).Unix(),
+			}),
+			"missing exp": sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, jwt.MapClaims{
+				"sub": walletID.String(), "iss": "mobile-backend", "aud": "wallet",
+			}),
+			"wrong issuer": sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, jwt.MapClaims{
+				"sub": walletID.String(), "iss": "someone-else", "aud": "wallet", "exp": exp,
+			}),
+			"unknown kid": sign(t, jwt.SigningMethodRS256, "rsa-2", rsaKey, jwt.MapClaims{
+				"sub": walletID.String(), "iss": "mobile-backend", "aud": "wallet", "exp": exp,
+			}),
+			"no wallets": sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, jwt.MapClaims{
+				"sub": "user-42", "iss": "mobile-backend", "aud": "wallet", "exp": exp,
+			}),
+			"hs256": sign(t, jwt.SigningMethodHS256, "rsa-1", []byte("secret"), jwt.MapClaims{
+				"sub": walletID.String(), "iss": "mobile-backend", "aud": "wallet", "exp": exp,
+			}),
+		}
+		for name, token := range cases {
+			_, err := v.Verify(token)
+			assert.Error(t, err, name)
+		}
+	})
+}
+
+func TestJWKS_ReloadRotatesKeys(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, path, rsaJWK("old", oldKey))
+	keys, err := LoadJWKS(path)
+	require.NoError(t, err)
+
+	changed, err := keys.ReloadIfChanged()
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	writeJWKS(t, path, rsaJWK("new", newKey))
+	require.NoError(t, os.Chtimes(path, time.Now().Add(time.Minute), time.Now().Add(time.Minute)))
+
+	changed, err = keys.ReloadIfChanged()
+	assert.NoError(t, err)
+	assert.True(t, changed)
+
+	_, err = keys.Key("old")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	_, err = keys.Key("new")
+	assert.NoError(t, err)
+}
+
+func TestJWKS_InvalidFileKeepsPreviousKeys(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, path, rsaJWK("k1", key))
+	keys, err := LoadJWKS(path)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte("{broken"), 0o600))
+	assert.Error(t, keys.Reload())
+
+	_, err = keys.Key("k1")
+	assert.NoError(t, err)
+}
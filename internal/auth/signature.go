@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+const (
+	HeaderClientID  = "X-Client-ID"
+	HeaderTimestamp = "X-Timestamp"
+	HeaderNonce     = "X-Nonce"
+	HeaderSignature = "X-Signature"
+)
+
+// SignRequest returns the hex HMAC-SHA256, keyed by secret, of
+//
+//	METHOD\nREQUEST_URI\nUNIX_TIMESTAMP\nNONCE\nhex(sha256(body))
+//
+// where REQUEST_URI is the path including the query string.
+func SignRequest(secret, method, requestURI string, timestamp time.Time, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(method + "\n" + requestURI + "\n" + strconv.FormatInt(timestamp.Unix(), 10) + "\n" + nonce + "\n"))
+	mac.Write([]byte(hex.EncodeToString(bodyHash[:])))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func VerifyRequest(secret, method, requestURI string, timestamp time.Time, nonce string, body []byte, signature string) bool {
+	expected := SignRequest(secret, method, requestURI, timestamp, nonce, body)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
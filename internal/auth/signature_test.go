@@ -0,0 +1,24 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignRequest(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	body := []byte(`{"amount":100}`)
+	sig := SignRequest("secret", "POST", "/api/v1/wallet", ts, "n1", body)
+
+	assert.Len(t, sig, 64)
+	assert.True(t, VerifyRequest("secret", "POST", "/api/v1/wallet", ts, "n1", body, sig))
+
+	assert.False(t, VerifyRequest("other", "POST", "/api/v1/wallet", ts, "n1", body, sig))
+	assert.False(t, VerifyRequest("secret", "GET", "/api/v1/wallet", ts, "n1", body, sig))
+	assert.False(t, VerifyRequest("secret", "POST", "/api/v1/wallet?x=1", ts, "n1", body, sig))
+	assert.False(t, VerifyRequest("secret", "POST", "/api/v1/wallet", ts.Add(time.Second), "n1", body, sig))
+	assert.False(t, VerifyRequest("secret", "POST", "/api/v1/wallet", ts, "n2", body, sig))
+	assert.False(t, VerifyRequest("secret", "POST", "/api/v1/wallet", ts, "n1", []byte(`{"amount":900}`), sig))
+}
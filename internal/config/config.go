@@ -1,22 +1,306 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
 )
 
+const maskedSecret = "******"
+
 type Config struct {
-	DBHost   string
-	DBPort   int
-	DBUser   string
-	DBPass   string
-	DBName   string
-	HTTPPort string
+	DB      DBConfig      `mapstructure:"db"`
+	HTTP    HTTPConfig    `mapstructure:"http"`
+	Admin   AdminConfig   `mapstructure:"admin"`
+	Queue   QueueConfig   `mapstructure:"queue"`
+	Limits  LimitsConfig  `mapstructure:"limits"`
+	Logging LoggingConfig `mapstructure:"log"`
+	Auth    AuthConfig    `mapstructure:"auth"`
+	Health  HealthConfig  `mapstructure:"health"`
+	Outbox  OutboxConfig  `mapstructure:"outbox"`
+	Webhook WebhookConfig `mapstructure:"webhook"`
+	Stream  StreamConfig  `mapstructure:"stream"`
+	Tracing TracingConfig `mapstructure:"tracing"`
+}
+
+type DBConfig struct {
+	// URL is a full postgres:// connection URL. Parameters it sets take
+	// precedence over the individual fields below.
+	URL      string `mapstructure:"url"`
+	URLFile  string `mapstructure:"url_file"`
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	User     string `mapstructure:"user"`
+	Pass     string `mapstructure:"pass"`
+	PassFile string `mapstructure:"pass_file"`
+	Name     string `mapstructure:"name"`
+
+	SSLMode     string `mapstructure:"sslmode"`
+	SSLRootCert string `mapstructure:"sslrootcert"`
+	SSLCert     string `mapstructure:"sslcert"`
+	SSLKey      string `mapstructure:"sslkey"`
+
+	ApplicationName  string        `mapstructure:"application_name"`
+	StatementTimeout time.Duration `mapstructure:"statement_timeout"`
+
+	// Replicas are postgres:// URLs of read replicas. TLS, pool and
+	// session settings above apply to them as well.
+	Replicas             []string      `mapstructure:"replicas"`
+	ReplicaMaxLag        time.Duration `mapstructure:"replica_max_lag"`
+	ReplicaCheckInterval time.Duration `mapstructure:"replica_check_interval"`
+
+	MaxOpenConns    int           `mapstructure:"max_open_conns"`
+	MaxIdleConns    int           `mapstructure:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `mapstructure:"conn_max_idle_time"`
+
+	// ConnectTimeout bounds how long startup keeps retrying to reach the
+	// primary before giving up.
+	ConnectTimeout time.Duration `mapstructure:"connect_timeout"`
+	Breaker        BreakerConfig `mapstructure:"breaker"`
+
+	// Migrate is what the server does with migrations on startup: up,
+	// verify (refuse to start on a pending migration) or skip.
+	Migrate string `mapstructure:"migrate"`
+	// MigrationsDir overrides the migrations embedded in the binary.
+	MigrationsDir string `mapstructure:"migrations_dir"`
+}
+
+// BreakerConfig controls the circuit breaker around primary database calls.
+// A FailureThreshold of 0 disables it.
+type BreakerConfig struct {
+	FailureThreshold int           `mapstructure:"failure_threshold"`
+	OpenTimeout      time.Duration `mapstructure:"open_timeout"`
+}
+
+type HTTPConfig struct {
+	Port            string        `mapstructure:"port"`
+	ReadTimeout     time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout    time.Duration `mapstructure:"write_timeout"`
+	IdleTimeout     time.Duration `mapstructure:"idle_timeout"`
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+	// TrustedProxies are IPs or CIDRs whose X-Forwarded-For and X-Real-IP
+	// headers are believed. Empty means the client IP is the peer address.
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+}
+
+type AdminConfig struct {
+	Port         string        `mapstructure:"port"`
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+}
+
+type QueueConfig struct {
+	// Capacity is the number of jobs a wallet queue buffers before
+	// ChangeBalance blocks.
+	Capacity int `mapstructure:"capacity"`
+}
+
+type LimitsConfig struct {
+	MaxBatchSize int             `mapstructure:"max_batch_size"`
+	RateLimit    RateLimitConfig `mapstructure:"rate_limit"`
+}
+
+type RateLimitConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	Backend       string        `mapstructure:"backend"`
+	IP            RateConfig    `mapstructure:"ip"`
+	Client        RateConfig    `mapstructure:"client"`
+	Wallet        RateConfig    `mapstructure:"wallet"`
+	PruneInterval time.Duration `mapstructure:"prune_interval"`
+}
+
+type RateConfig struct {
+	Rate  float64 `mapstructure:"rate"`
+	Burst int     `mapstructure:"burst"`
+}
+
+type LoggingConfig struct {
+	Level       string         `mapstructure:"level"`
+	Encoding    string         `mapstructure:"encoding"`
+	OutputPaths []string       `mapstructure:"output_paths"`
+	Sampling    SamplingConfig `mapstructure:"sampling"`
+}
+
+type SamplingConfig struct {
+	Initial    int `mapstructure:"initial"`
+	Thereafter int `mapstructure:"thereafter"`
+}
+
+type AuthConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	CacheTTL time.Duration `mapstructure:"cache_ttl"`
+	JWT      JWTConfig     `mapstructure:"jwt"`
+	HMAC     HMACConfig    `mapstructure:"hmac"`
+}
+
+type JWTConfig struct {
+	Enabled        bool          `mapstructure:"enabled"`
+	JWKSFile       string        `mapstructure:"jwks_file"`
+	Issuer         string        `mapstructure:"issuer"`
+	Audience       string        `mapstructure:"audience"`
+	Leeway         time.Duration `mapstructure:"leeway"`
+	ReloadInterval time.Duration `mapstructure:"reload_interval"`
+}
+
+type HMACConfig struct {
+	Enabled   bool          `mapstructure:"enabled"`
+	ClockSkew time.Duration `mapstructure:"clock_skew"`
+	// NonceStore is postgres, shared by all replicas, or memory, which only
+	// protects a single instance against replays.
+	NonceStore string       `mapstructure:"nonce_store"`
+	Clients    []HMACClient `mapstructure:"clients"`
+}
+
+// HMACClient is a server-to-server caller that signs requests with Secret.
+type HMACClient struct {
+	ID         string   `mapstructure:"id"`
+	Secret     string   `mapstructure:"secret"`
+	SecretFile string   `mapstructure:"secret_file"`
+	Scopes     []string `mapstructure:"scopes"`
+	WalletIDs  []string `mapstructure:"wallet_ids"`
+}
+
+type HealthConfig struct {
+	Timeout         time.Duration `mapstructure:"timeout"`
+	QueueSaturation float64       `mapstructure:"queue_saturation"`
+}
+
+type OutboxConfig struct {
+	Publisher     string        `mapstructure:"publisher"`
+	FilePath      string        `mapstructure:"file_path"`
+	HTTPURL       string        `mapstructure:"http_url"`
+	HTTPTimeout   time.Duration `mapstructure:"http_timeout"`
+	PollInterval  time.Duration `mapstructure:"poll_interval"`
+	BatchSize     int           `mapstructure:"batch_size"`
+	MinBackoff    time.Duration `mapstructure:"min_backoff"`
+	MaxBackoff    time.Duration `mapstructure:"max_backoff"`
+	Retention     time.Duration `mapstructure:"retention"`
+	PruneInterval time.Duration `mapstructure:"prune_interval"`
+}
+
+type WebhookConfig struct {
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+	BatchSize    int           `mapstructure:"batch_size"`
+	Concurrency  int           `mapstructure:"concurrency"`
+	Timeout      time.Duration `mapstructure:"timeout"`
+	MaxAttempts  int           `mapstructure:"max_attempts"`
+	DisableAfter int           `mapstructure:"disable_after"`
+	MinBackoff   time.Duration `mapstructure:"min_backoff"`
+	MaxBackoff   time.Duration `mapstructure:"max_backoff"`
+}
+
+type StreamConfig struct {
+	Heartbeat  time.Duration `mapstructure:"heartbeat"`
+	BufferSize int           `mapstructure:"buffer_size"`
+	Fanout     string        `mapstructure:"fanout"`
+}
+
+type TracingConfig struct {
+	Exporter    string  `mapstructure:"exporter"`
+	Endpoint    string  `mapstructure:"endpoint"`
+	FilePath    string  `mapstructure:"file_path"`
+	ServiceName string  `mapstructure:"service_name"`
+	SampleRatio float64 `mapstructure:"sample_ratio"`
+}
+
+// renamedKeys maps keys of older config files to their current names.
+var renamedKeys = map[string]string{
+	"api.max_batch_size": "limits.max_batch_size",
+	"rate_limit":         "limits.rate_limit",
 }
 
+// Load reads and validates the configuration.
 func Load() (*Config, error) {
+	cfg, err := Read()
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Read loads config.yaml from the working directory, if there is one, and
+// applies defaults and environment overrides without validating the result.
+func Read() (*Config, error) {
+	v := newViper()
+	if err := v.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if !errors.As(err, &notFound) {
+			return nil, fmt.Errorf("cannot read config file: %w", err)
+		}
+	}
+
+	var renamed []string
+	for old, key := range renamedKeys {
+		if v.InConfig(old) {
+			renamed = append(renamed, fmt.Sprintf("%s was renamed to %s", old, key))
+		}
+	}
+	if len(renamed) > 0 {
+		sort.Strings(renamed)
+		return nil, fmt.Errorf("invalid config: %s", strings.Join(renamed, "; "))
+	}
+
+	bindEnv(v)
+	setDefaults(v)
+
+	var cfg Config
+	// Unknown keys are an error rather than silently falling back to
+	// defaults.
+	if err := v.UnmarshalExact(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if err := cfg.readSecretFiles(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// readSecretFiles fills secrets from their *_file counterparts, which is how
+// Docker and Kubernetes secrets are mounted. A file takes precedence over an
+// inline value.
+func (c *Config) readSecretFiles() error {
+	read := func(key, path string, dst *string) error {
+		if path == "" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("cannot read %s: %w", key, err)
+		}
+		*dst = strings.TrimRight(string(data), "\r\n")
+		return nil
+	}
+
+	if err := read("db.url_file", c.DB.URLFile, &c.DB.URL); err != nil {
+		return err
+	}
+	if err := read("db.pass_file", c.DB.PassFile, &c.DB.Pass); err != nil {
+		return err
+	}
+	for i := range c.Auth.HMAC.Clients {
+		client := &c.Auth.HMAC.Clients[i]
+		if err := read(fmt.Sprintf("auth.hmac.clients[%d].secret_file", i), client.SecretFile, &client.Secret); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newViper() *viper.Viper {
 	v := viper.New()
 
 	v.SetConfigName("config")
@@ -25,24 +309,367 @@ func Load() (*Config, error) {
 
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	v.AutomaticEnv()
+	return v
+}
 
-	if err := v.ReadInConfig(); err != nil {
-		fmt.Printf("warning: cannot read config.yaml: %v\n", err)
-	}
-
-	v.BindEnv("db.host", "DB_eHOST")
+func bindEnv(v *viper.Viper) {
+	v.BindEnv("db.url", "DATABASE_URL")
+	v.BindEnv("db.url_file", "DATABASE_URL_FILE")
+	v.BindEnv("db.host", "DB_HOST")
 	v.BindEnv("db.port", "DB_PORT")
 	v.BindEnv("db.user", "DB_USER")
 	v.BindEnv("db.pass", "DB_PASS")
+	v.BindEnv("db.pass_file", "DB_PASS_FILE")
 	v.BindEnv("db.name", "DB_NAME")
+	v.BindEnv("db.replicas", "DB_REPLICAS")
+	v.BindEnv("db.migrate", "DB_MIGRATE")
 	v.BindEnv("http.port", "HTTP_PORT")
+	v.BindEnv("http.trusted_proxies", "HTTP_TRUSTED_PROXIES")
+	v.BindEnv("admin.port", "ADMIN_PORT")
+	v.BindEnv("log.level", "LOG_LEVEL")
+	v.BindEnv("log.encoding", "LOG_ENCODING")
+	v.BindEnv("limits.max_batch_size", "API_MAX_BATCH_SIZE")
+	v.BindEnv("limits.rate_limit.enabled", "RATE_LIMIT_ENABLED")
+	v.BindEnv("limits.rate_limit.backend", "RATE_LIMIT_BACKEND")
+	v.BindEnv("outbox.publisher", "OUTBOX_PUBLISHER")
+	v.BindEnv("outbox.file_path", "OUTBOX_FILE_PATH")
+	v.BindEnv("outbox.http_url", "OUTBOX_HTTP_URL")
+	v.BindEnv("auth.enabled", "AUTH_ENABLED")
+	v.BindEnv("tracing.exporter", "TRACING_EXPORTER")
+	v.BindEnv("tracing.endpoint", "TRACING_ENDPOINT")
+}
+
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("db.port", 5432)
+	v.SetDefault("db.sslmode", "disable")
+	v.SetDefault("db.application_name", "wallet-service")
+	v.SetDefault("db.replica_max_lag", "10s")
+	v.SetDefault("db.replica_check_interval", "5s")
+	v.SetDefault("db.max_open_conns", 200)
+	v.SetDefault("db.max_idle_conns", 50)
+	v.SetDefault("db.conn_max_lifetime", "5m")
+	v.SetDefault("db.connect_timeout", "1m")
+	v.SetDefault("db.breaker.failure_threshold", 5)
+	v.SetDefault("db.breaker.open_timeout", "10s")
+	v.SetDefault("db.migrate", "up")
+	v.SetDefault("http.port", "8080")
+	v.SetDefault("http.read_timeout", "15s")
+	v.SetDefault("http.write_timeout", "15s")
+	v.SetDefault("http.idle_timeout", "60s")
+	v.SetDefault("http.shutdown_timeout", "30s")
+	v.SetDefault("admin.port", "9090")
+	v.SetDefault("admin.write_timeout", "2m")
+	v.SetDefault("queue.capacity", 1000)
+	v.SetDefault("limits.max_batch_size", 500)
+	v.SetDefault("limits.rate_limit.enabled", true)
+	v.SetDefault("limits.rate_limit.backend", "memory")
+	v.SetDefault("limits.rate_limit.ip.rate", 100)
+	v.SetDefault("limits.rate_limit.ip.burst", 200)
+	v.SetDefault("limits.rate_limit.client.rate", 200)
+	v.SetDefault("limits.rate_limit.client.burst", 400)
+	v.SetDefault("limits.rate_limit.wallet.rate", 50)
+	v.SetDefault("limits.rate_limit.wallet.burst", 100)
+	v.SetDefault("limits.rate_limit.prune_interval", "1m")
+	v.SetDefault("log.level", "info")
+	v.SetDefault("log.encoding", "json")
+	v.SetDefault("log.output_paths", []string{"stderr"})
+	v.SetDefault("log.sampling.initial", 100)
+	v.SetDefault("log.sampling.thereafter", 100)
+	v.SetDefault("auth.enabled", true)
+	v.SetDefault("auth.cache_ttl", "30s")
+	v.SetDefault("auth.jwt.enabled", false)
+	v.SetDefault("auth.jwt.leeway", "30s")
+	v.SetDefault("auth.jwt.reload_interval", "30s")
+	v.SetDefault("auth.hmac.enabled", false)
+	v.SetDefault("auth.hmac.clock_skew", "5m")
+	v.SetDefault("auth.hmac.nonce_store", "postgres")
+	v.SetDefault("health.timeout", "2s")
+	v.SetDefault("health.queue_saturation", 0.8)
+	v.SetDefault("outbox.publisher", "stdout")
+	v.SetDefault("outbox.http_timeout", "10s")
+	v.SetDefault("outbox.poll_interval", "1s")
+	v.SetDefault("outbox.batch_size", 100)
+	v.SetDefault("outbox.min_backoff", "1s")
+	v.SetDefault("outbox.max_backoff", "5m")
+	v.SetDefault("outbox.retention", "168h")
+	v.SetDefault("outbox.prune_interval", "1h")
+	v.SetDefault("webhook.poll_interval", "1s")
+	v.SetDefault("webhook.batch_size", 50)
+	v.SetDefault("webhook.concurrency", 8)
+	v.SetDefault("webhook.timeout", "10s")
+	v.SetDefault("webhook.max_attempts", 10)
+	v.SetDefault("webhook.disable_after", 20)
+	v.SetDefault("webhook.min_backoff", "5s")
+	v.SetDefault("webhook.max_backoff", "1h")
+	v.SetDefault("stream.heartbeat", "15s")
+	v.SetDefault("stream.buffer_size", 64)
+	v.SetDefault("stream.fanout", "postgres")
+	v.SetDefault("tracing.exporter", "none")
+	v.SetDefault("tracing.service_name", "wallet-service")
+	v.SetDefault("tracing.sample_ratio", 1.0)
+}
+
+// Validate reports every problem in the configuration at once.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	positive := func(key string, d time.Duration) {
+		check(d > 0, "%s must be a positive duration", key)
+	}
+	oneOf := func(key, value string, allowed ...string) {
+		for _, a := range allowed {
+			if value == a {
+				return
+			}
+		}
+		errs = append(errs, fmt.Errorf("%s must be one of %s, got %q", key, strings.Join(allowed, ", "), value))
+	}
+
+	if c.DB.URL != "" {
+		check(isPostgresURL(c.DB.URL), "db.url must be a postgres:// URL")
+	} else {
+		check(c.DB.Host != "", "db.host is required")
+		check(c.DB.Port > 0 && c.DB.Port <= 65535, "db.port must be between 1 and 65535")
+		check(c.DB.User != "", "db.user is required")
+		check(c.DB.Name != "", "db.name is required")
+	}
+	oneOf("db.sslmode", c.DB.SSLMode, "disable", "allow", "prefer", "require", "verify-ca", "verify-full")
+	check((c.DB.SSLCert == "") == (c.DB.SSLKey == ""), "db.sslcert and db.sslkey must be set together")
+	check(c.DB.StatementTimeout >= 0, "db.statement_timeout must not be negative")
+	for i, replica := range c.DB.Replicas {
+		check(isPostgresURL(replica), "db.replicas[%d] must be a postgres:// URL", i)
+	}
+	if len(c.DB.Replicas) > 0 {
+		positive("db.replica_max_lag", c.DB.ReplicaMaxLag)
+		positive("db.replica_check_interval", c.DB.ReplicaCheckInterval)
+	}
+	check(c.DB.MaxOpenConns >= 0, "db.max_open_conns must not be negative")
+	check(c.DB.MaxIdleConns >= 0, "db.max_idle_conns must not be negative")
+	check(c.DB.ConnMaxLifetime >= 0, "db.conn_max_lifetime must not be negative")
+	check(c.DB.ConnMaxIdleTime >= 0, "db.conn_max_idle_time must not be negative")
+	check(c.DB.ConnectTimeout >= 0, "db.connect_timeout must not be negative")
+	check(c.DB.Breaker.FailureThreshold >= 0, "db.breaker.failure_threshold must not be negative")
+	if c.DB.Breaker.FailureThreshold > 0 {
+		positive("db.breaker.open_timeout", c.DB.Breaker.OpenTimeout)
+	}
+	oneOf("db.migrate", c.DB.Migrate, "up", "verify", "skip")
+
+	check(c.HTTP.Port != "", "http.port is required")
+	check(c.Admin.Port != "", "admin.port is required")
+	check(c.HTTP.Port != c.Admin.Port, "admin.port must differ from http.port")
+	positive("http.read_timeout", c.HTTP.ReadTimeout)
+	positive("http.write_timeout", c.HTTP.WriteTimeout)
+	positive("http.idle_timeout", c.HTTP.IdleTimeout)
+	positive("http.shutdown_timeout", c.HTTP.ShutdownTimeout)
+	for i, proxy := range c.HTTP.TrustedProxies {
+		check(isIPOrCIDR(proxy), "http.trusted_proxies[%d] must be an IP or CIDR", i)
+	}
+	positive("admin.write_timeout", c.Admin.WriteTimeout)
+
+	check(c.Queue.Capacity > 0, "queue.capacity must be positive")
+
+	check(c.Limits.MaxBatchSize > 0, "limits.max_batch_size must be positive")
+	if rl := c.Limits.RateLimit; rl.Enabled {
+		oneOf("limits.rate_limit.backend", rl.Backend, "memory", "postgres")
+		for _, r := range []struct {
+			name string
+			RateConfig
+		}{{"ip", rl.IP}, {"client", rl.Client}, {"wallet", rl.Wallet}} {
+			check(r.Rate >= 0, "limits.rate_limit.%s.rate must not be negative", r.name)
+			check(r.Rate == 0 || r.Burst > 0, "limits.rate_limit.%s.burst must be positive", r.name)
+		}
+		positive("limits.rate_limit.prune_interval", rl.PruneInterval)
+	}
+
+	oneOf("log.level", c.Logging.Level, "debug", "info", "warn", "error")
+	oneOf("log.encoding", c.Logging.Encoding, "json", "console")
+	check(c.Logging.Sampling.Initial >= 0 && c.Logging.Sampling.Thereafter >= 0, "log.sampling values must not be negative")
+	check(c.Logging.Sampling.Initial == 0 || c.Logging.Sampling.Thereafter > 0, "log.sampling.thereafter must be positive when log.sampling.initial is set")
+
+	positive("auth.cache_ttl", c.Auth.CacheTTL)
+	if c.Auth.JWT.Enabled {
+		check(c.Auth.JWT.JWKSFile != "", "auth.jwt.jwks_file is required when jwt is enabled")
+		check(c.Auth.JWT.Leeway >= 0, "auth.jwt.leeway must not be negative")
+		positive("auth.jwt.reload_interval", c.Auth.JWT.ReloadInterval)
+	}
+	if c.Auth.HMAC.Enabled {
+		positive("auth.hmac.clock_skew", c.Auth.HMAC.ClockSkew)
+		oneOf("auth.hmac.nonce_store", c.Auth.HMAC.NonceStore, "postgres", "memory")
+		for i, client := range c.Auth.HMAC.Clients {
+			check(client.ID != "", "auth.hmac.clients[%d].id is required", i)
+			check(client.Secret != "", "auth.hmac.clients[%d].secret is required", i)
+		}
+	}
+
+	positive("health.timeout", c.Health.Timeout)
+	check(c.Health.QueueSaturation > 0 && c.Health.QueueSaturation <= 1, "health.queue_saturation must be in (0, 1]")
+
+	oneOf("outbox.publisher", c.Outbox.Publisher, "stdout", "file", "http")
+	check(c.Outbox.Publisher != "file" || c.Outbox.FilePath != "", "outbox.file_path is required for the file publisher")
+	check(c.Outbox.Publisher != "http" || c.Outbox.HTTPURL != "", "outbox.http_url is required for the http publisher")
+	positive("outbox.http_timeout", c.Outbox.HTTPTimeout)
+	positive("outbox.poll_interval", c.Outbox.PollInterval)
+	check(c.Outbox.BatchSize > 0, "outbox.batch_size must be positive")
+	positive("outbox.min_backoff", c.Outbox.MinBackoff)
+	check(c.Outbox.MaxBackoff >= c.Outbox.MinBackoff, "outbox.max_backoff must not be less than outbox.min_backoff")
+	check(c.Outbox.Retention >= 0, "outbox.retention must not be negative")
+	positive("outbox.prune_interval", c.Outbox.PruneInterval)
 
-	return &Config{
-		DBHost:   v.GetString("db.host"),
-		DBPort:   v.GetInt("db.port"),
-		DBUser:   v.GetString("db.user"),
-		DBPass:   v.GetString("db.pass"),
-		DBName:   v.GetString("db.name"),
-		HTTPPort: v.GetString("http.port"),
-	}, nil
+	positive("webhook.poll_interval", c.Webhook.PollInterval)
+	check(c.Webhook.BatchSize > 0, "webhook.batch_size must be positive")
+	check(c.Webhook.Concurrency > 0, "webhook.concurrency must be positive")
+	positive("webhook.timeout", c.Webhook.Timeout)
+	check(c.Webhook.MaxAttempts > 0, "webhook.max_attempts must be positive")
+	check(c.Webhook.DisableAfter > 0, "webhook.disable_after must be positive")
+	positive("webhook.min_backoff", c.Webhook.MinBackoff)
+	check(c.Webhook.MaxBackoff >= c.Webhook.MinBackoff, "webhook.max_backoff must not be less than webhook.min_backoff")
+
+	positive("stream.heartbeat", c.Stream.Heartbeat)
+	check(c.Stream.BufferSize > 0, "stream.buffer_size must be positive")
+	oneOf("stream.fanout", c.Stream.Fanout, "postgres", "local")
+
+	oneOf("tracing.exporter", c.Tracing.Exporter, "none", "otlp", "stdout", "file")
+	check(c.Tracing.Exporter != "file" || c.Tracing.FilePath != "", "tracing.file_path is required for the file exporter")
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio must be in [0, 1]")
+
+	return errors.Join(errs...)
+}
+
+// Masked returns a copy of the configuration with secrets replaced, safe to
+// print or log.
+func (c Config) Masked() Config {
+	if c.DB.Pass != "" {
+		c.DB.Pass = maskedSecret
+	}
+	c.DB.URL = MaskURL(c.DB.URL)
+	replicas := make([]string, len(c.DB.Replicas))
+	for i, replica := range c.DB.Replicas {
+		replicas[i] = MaskURL(replica)
+	}
+	c.DB.Replicas = replicas
+	clients := make([]HMACClient, len(c.Auth.HMAC.Clients))
+	for i, client := range c.Auth.HMAC.Clients {
+		if client.Secret != "" {
+			client.Secret = maskedSecret
+		}
+		clients[i] = client
+	}
+	c.Auth.HMAC.Clients = clients
+	return c
+}
+
+func isPostgresURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql")
+}
+
+func isIPOrCIDR(s string) bool {
+	if net.ParseIP(s) != nil {
+		return true
+	}
+	_, _, err := net.ParseCIDR(s)
+	return err == nil
+}
+
+// MaskURL hides the password in a connection URL.
+func MaskURL(s string) string {
+	if s == "" {
+		return s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return maskedSecret
+	}
+	if _, ok := u.User.Password(); !ok {
+		return s
+	}
+	// URL.String would percent-encode the mask, so swap Redacted's placeholder.
+	return strings.Replace(u.Redacted(), ":xxxxx@", ":"+maskedSecret+"@", 1)
+}
+
+// toMap converts the configuration to nested maps keyed like config.yaml.
+func (c Config) toMap() (map[string]any, error) {
+	m, err := structToMap(c)
+	if err != nil {
+		return nil, err
+	}
+	return m.(map[string]any), nil
+}
+
+// structToMap is mapstructure.Decode into a map, extended to slices of
+// structs, which mapstructure leaves as they are.
+func structToMap(v any) (any, error) {
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.Kind() == reflect.Struct:
+		var m map[string]any
+		if err := mapstructure.Decode(v, &m); err != nil {
+			return nil, err
+		}
+		return structToMap(m)
+	case rv.Kind() == reflect.Map:
+		m, ok := v.(map[string]any)
+		if !ok {
+			return v, nil
+		}
+		for k, val := range m {
+			converted, err := structToMap(val)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = converted
+		}
+		return m, nil
+	case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Struct:
+		out := make([]any, rv.Len())
+		for i := range out {
+			converted, err := structToMap(rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			out[i] = converted
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+// flatten returns the configuration as dotted keys, e.g. "http.port".
+func (c Config) flatten() (map[string]any, error) {
+	nested, err := c.toMap()
+	if err != nil {
+		return nil, err
+	}
+	flat := make(map[string]any)
+	var walk func(prefix string, m map[string]any)
+	walk = func(prefix string, m map[string]any) {
+		for k, v := range m {
+			if sub, ok := v.(map[string]any); ok {
+				walk(prefix+k+".", sub)
+				continue
+			}
+			flat[prefix+k] = v
+		}
+	}
+	walk("", nested)
+	return flat, nil
+}
+
+// WriteYAML writes the configuration with secrets masked, using the same
+// keys as config.yaml.
+func (c Config) WriteYAML(w io.Writer) error {
+	out, err := c.Masked().toMap()
+	if err != nil {
+		return err
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(out); err != nil {
+		return err
+	}
+	return enc.Close()
 }
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// inDir runs Read with dir as the working directory, where config.yaml is
+// looked up.
+func inDir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestLoad_FileDefaultsAndEnv(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(dir+"/config.yaml", []byte(`
+db:
+  host: localhost
+  user: wallet_user
+  pass: secret
+  name: wallet_db
+http:
+  read_timeout: 5s
+auth:
+  hmac:
+    clients:
+      - id: processor
+        secret: s3cret
+        scopes: [wallet:read]
+`), 0o600))
+	inDir(t, dir)
+	t.Setenv("DB_HOST", "postgres")
+	t.Setenv("RATE_LIMIT_ENABLED", "false")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "postgres", cfg.DB.Host)
+	assert.Equal(t, 5432, cfg.DB.Port)
+	assert.Equal(t, 5*time.Second, cfg.HTTP.ReadTimeout)
+	assert.Equal(t, 15*time.Second, cfg.HTTP.WriteTimeout)
+	assert.Equal(t, 1000, cfg.Queue.Capacity)
+	assert.False(t, cfg.Limits.RateLimit.Enabled)
+	assert.Equal(t, []string{"stderr"}, cfg.Logging.OutputPaths)
+	require.Len(t, cfg.Auth.HMAC.Clients, 1)
+	assert.Equal(t, []string{"wallet:read"}, cfg.Auth.HMAC.Clients[0].Scopes)
+}
+
+func TestLoad_ReportsAllProblems(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(dir+"/config.yaml", []byte(`
+http:
+  port: "9090"
+  write_timeout: 0s
+  trusted_proxies: ["10.0.0.0/8", "lb.internal"]
+outbox:
+  publisher: http
+stream:
+  fanout: kafka
+log:
+  sampling:
+    initial: 10
+    thereafter: 0
+`), 0o600))
+	inDir(t, dir)
+
+	t.Setenv("DB_MIGRATE", "always")
+
+	_, err := Load()
+	require.Error(t, err)
+	for _, problem := range []string{
+		"db.host is required",
+		`db.migrate must be one of up, verify, skip, got "always"`,
+		"db.user is required",
+		"db.name is required",
+		"admin.port must differ from http.port",
+		"http.write_timeout must be a positive duration",
+		"http.trusted_proxies[1] must be an IP or CIDR",
+		"outbox.http_url is required for the http publisher",
+		`stream.fanout must be one of postgres, local, got "kafka"`,
+		"log.sampling.thereafter must be positive when log.sampling.initial is set",
+	} {
+		assert.Contains(t, err.Error(), problem)
+	}
+}
+
+func TestLoad_RejectsUnknownAndRenamedKeys(t *testing.T) {
+	dir := t.TempDir()
+	inDir(t, dir)
+
+	require.NoError(t, os.WriteFile(dir+"/config.yaml", []byte(`
+rate_limit:
+  enabled: false
+api:
+  max_batch_size: 5
+`), 0o600))
+	_, err := Read()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "api.max_batch_size was renamed to limits.max_batch_size")
+	assert.Contains(t, err.Error(), "rate_limit was renamed to limits.rate_limit")
+
+	require.NoError(t, os.WriteFile(dir+"/config.yaml", []byte(`
+limits:
+  max_batch_sise: 5
+`), 0o600))
+	_, err = Read()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "max_batch_sise")
+}
+
+func TestLoad_MissingFileUsesDefaultsAndEnv(t *testing.T) {
+	inDir(t, t.TempDir())
+	t.Setenv("DB_HOST", "postgres")
+	t.Setenv("DB_USER", "wallet_user")
+	t.Setenv("DB_NAME", "wallet_db")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "8080", cfg.HTTP.Port)
+	assert.Equal(t, "postgres", cfg.Stream.Fanout)
+}
+
+func TestConfig_WriteYAMLMasksSecrets(t *testing.T) {
+	cfg := Config{
+		DB:   DBConfig{Host: "postgres", Pass: "wallet_pass"},
+		HTTP: HTTPConfig{ReadTimeout: 15 * time.Second},
+		Auth: AuthConfig{HMAC: HMACConfig{Clients: []HMACClient{{ID: "processor", Secret: "s3cret"}}}},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, cfg.WriteYAML(&buf))
+	out := buf.String()
+	assert.Contains(t, out, "host: postgres")
+	assert.Contains(t, out, "read_timeout: 15s")
+	assert.Contains(t, out, "id: processor")
+	assert.Contains(t, out, "wallet_ids: []")
+	assert.NotContains(t, out, "wallet_pass")
+	assert.NotContains(t, out, "s3cret")
+	assert.Equal(t, "s3cret", cfg.Auth.HMAC.Clients[0].Secret, "masking must not modify the original")
+}
+
+func TestLoad_SecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(dir+"/db_url", []byte("postgres://wallet_user:from-file@db:5432/wallet_db\n"), 0o600))
+	require.NoError(t, os.WriteFile(dir+"/hmac", []byte("hmac-secret\n"), 0o600))
+	require.NoError(t, os.WriteFile(dir+"/config.yaml", []byte(`
+auth:
+  hmac:
+    clients:
+      - id: processor
+        secret_file: `+dir+`/hmac
+`), 0o600))
+	inDir(t, dir)
+	t.Setenv("DATABASE_URL_FILE", dir+"/db_url")
+
+	cfg, err := Load()
+	require.NoError(t, err, "host, user and name are not required with a url")
+	assert.Equal(t, "postgres://wallet_user:from-file@db:5432/wallet_db", cfg.DB.URL)
+	assert.Equal(t, "hmac-secret", cfg.Auth.HMAC.Clients[0].Secret)
+
+	var buf bytes.Buffer
+	require.NoError(t, cfg.WriteYAML(&buf))
+	assert.NotContains(t, buf.String(), "from-file")
+	assert.Contains(t, buf.String(), "postgres://wallet_user:******@db:5432/wallet_db")
+
+	t.Setenv("DB_PASS_FILE", dir+"/missing")
+	_, err = Load()
+	assert.ErrorContains(t, err, "cannot read db.pass_file")
+}
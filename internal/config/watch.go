@@ -0,0 +1,169 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// reloadable lists the keys, or key prefixes ending in ".", that may change
+// while the service runs. Changes to any other key need a restart.
+var reloadable = []string{
+	"log.level",
+	"limits.max_batch_size",
+	"limits.rate_limit.ip.",
+	"limits.rate_limit.client.",
+	"limits.rate_limit.wallet.",
+}
+
+func isReloadable(key string) bool {
+	for _, r := range reloadable {
+		if key == r || strings.HasSuffix(r, ".") && strings.HasPrefix(key, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Change is a single key that differs between two configurations. Secrets
+// are masked.
+type Change struct {
+	Key string `json:"key"`
+	Old any    `json:"old"`
+	New any    `json:"new"`
+}
+
+// Diff lists the keys that differ between old and new, sorted by key.
+func Diff(old, new *Config) ([]Change, error) {
+	oldFlat, err := old.flatten()
+	if err != nil {
+		return nil, err
+	}
+	newFlat, err := new.flatten()
+	if err != nil {
+		return nil, err
+	}
+	oldMasked, err := old.Masked().flatten()
+	if err != nil {
+		return nil, err
+	}
+	newMasked, err := new.Masked().flatten()
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for key, v := range newFlat {
+		if !reflect.DeepEqual(oldFlat[key], v) {
+			changes = append(changes, Change{Key: key, Old: oldMasked[key], New: newMasked[key]})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes, nil
+}
+
+// Watcher holds the current configuration and swaps in the reloadable part
+// of a new one when the config file changes.
+type Watcher struct {
+	current atomic.Pointer[Config]
+	mu      sync.Mutex // serializes reloads
+	hooks   []func(old, new *Config)
+	logger  *zap.Logger
+}
+
+func NewWatcher(cfg *Config, logger *zap.Logger) *Watcher {
+	w := &Watcher{logger: logger}
+	w.current.Store(cfg)
+	return w
+}
+
+// Current returns the configuration in effect. The returned value must not
+// be modified.
+func (w *Watcher) Current() *Config {
+	return w.current.Load()
+}
+
+// OnReload registers fn to be called after a reload changed the
+// configuration. Hooks run in registration order on the reloading goroutine.
+func (w *Watcher) OnReload(fn func(old, new *Config)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.hooks = append(w.hooks, fn)
+}
+
+// Watch reloads the configuration whenever the config file changes. It does
+// nothing if there is no config file.
+func (w *Watcher) Watch() {
+	v := newViper()
+	if err := v.ReadInConfig(); err != nil {
+		w.logger.Warn("config file is not watched", zap.Error(err))
+		return
+	}
+	v.OnConfigChange(func(fsnotify.Event) {
+		w.Reload()
+	})
+	v.WatchConfig()
+	w.logger.Info("watching config file", zap.String("file", v.ConfigFileUsed()))
+}
+
+// Reload reads and validates the configuration and applies the reloadable
+// keys that changed. An invalid configuration is rejected as a whole;
+// changes to keys that need a restart are ignored with a warning.
+func (w *Watcher) Reload() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	next, err := Read()
+	if err == nil {
+		err = next.Validate()
+	}
+	if err != nil {
+		w.logger.Error("config reload rejected", zap.Error(err))
+		return err
+	}
+
+	old := w.Current()
+	changes, err := Diff(old, next)
+	if err != nil {
+		w.logger.Error("config reload rejected", zap.Error(err))
+		return err
+	}
+
+	var applied []Change
+	for _, ch := range changes {
+		if !isReloadable(ch.Key) {
+			w.logger.Warn("config change requires a restart, ignored",
+				zap.String("key", ch.Key), zap.Any("old", ch.Old), zap.Any("new", ch.New))
+			continue
+		}
+		applied = append(applied, ch)
+	}
+	if len(applied) == 0 {
+		return nil
+	}
+
+	updated := *old
+	updated.Logging.Level = next.Logging.Level
+	updated.Limits.MaxBatchSize = next.Limits.MaxBatchSize
+	updated.Limits.RateLimit.IP = next.Limits.RateLimit.IP
+	updated.Limits.RateLimit.Client = next.Limits.RateLimit.Client
+	updated.Limits.RateLimit.Wallet = next.Limits.RateLimit.Wallet
+	w.current.Store(&updated)
+
+	keys := make([]string, len(applied))
+	for i, ch := range applied {
+		keys[i] = ch.Key
+	}
+	w.logger.Info("config reloaded",
+		zap.String("event", "config_reloaded"), zap.Strings("changed", keys), zap.Any("changes", applied))
+
+	for _, hook := range w.hooks {
+		hook(old, &updated)
+	}
+	return nil
+}
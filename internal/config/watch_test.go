@@ -0,0 +1,106 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+const baseConfig = `
+db:
+  host: postgres
+  user: wallet_user
+  pass: wallet_pass
+  name: wallet_db
+log:
+  level: info
+limits:
+  max_batch_size: 500
+`
+
+func TestDiff(t *testing.T) {
+	old := Config{DB: DBConfig{Host: "a", Pass: "old"}, Limits: LimitsConfig{MaxBatchSize: 10}}
+	new := old
+	new.DB.Pass = "new"
+	new.Limits.MaxBatchSize = 20
+
+	changes, err := Diff(&old, &new)
+	require.NoError(t, err)
+	assert.Equal(t, []Change{
+		{Key: "db.pass", Old: maskedSecret, New: maskedSecret},
+		{Key: "limits.max_batch_size", Old: 10, New: 20},
+	}, changes)
+}
+
+func TestWatcher_Reload(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/config.yaml"
+	require.NoError(t, os.WriteFile(path, []byte(baseConfig), 0o600))
+	inDir(t, dir)
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	core, logs := observer.New(zap.InfoLevel)
+	w := NewWatcher(cfg, zap.New(core))
+
+	var calls int
+	w.OnReload(func(old, new *Config) {
+		calls++
+		assert.Equal(t, "info", old.Logging.Level)
+		assert.Equal(t, "debug", new.Logging.Level)
+	})
+
+	require.NoError(t, os.WriteFile(path, []byte(baseConfig+`
+  rate_limit:
+    wallet:
+      rate: 5
+      burst: 10
+http:
+  port: "8081"
+`), 0o600))
+	// log.level is set via the env var binding, like in a deployment.
+	t.Setenv("LOG_LEVEL", "debug")
+	require.NoError(t, w.Reload())
+
+	current := w.Current()
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, "debug", current.Logging.Level)
+	assert.Equal(t, RateConfig{Rate: 5, Burst: 10}, current.Limits.RateLimit.Wallet)
+	assert.Equal(t, "8080", current.HTTP.Port, "port changes need a restart")
+	assert.Equal(t, "info", cfg.Logging.Level, "the previous config must not be modified")
+
+	refused := logs.FilterMessage("config change requires a restart, ignored").All()
+	require.Len(t, refused, 1)
+	assert.Equal(t, "http.port", refused[0].ContextMap()["key"])
+	reloaded := logs.FilterMessage("config reloaded").All()
+	require.Len(t, reloaded, 1)
+	assert.ElementsMatch(t, []any{"log.level", "limits.rate_limit.wallet.rate", "limits.rate_limit.wallet.burst"}, reloaded[0].ContextMap()["changed"])
+	assert.NotContains(t, reloaded[0].ContextMap(), "log.level")
+
+	// Reloading the same file again changes nothing.
+	require.NoError(t, w.Reload())
+	assert.Equal(t, 1, calls)
+}
+
+func TestWatcher_ReloadRejectsInvalidConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/config.yaml"
+	require.NoError(t, os.WriteFile(path, []byte(baseConfig), 0o600))
+	inDir(t, dir)
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	w := NewWatcher(cfg, zap.NewNop())
+	w.OnReload(func(_, _ *Config) { t.Error("hook must not run for an invalid config") })
+
+	require.NoError(t, os.WriteFile(path, []byte(strings.Replace(baseConfig, "max_batch_size: 500", "max_batch_size: 0", 1)), 0o600))
+	err = w.Reload()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "limits.max_batch_size must be positive")
+	assert.Same(t, cfg, w.Current())
+}
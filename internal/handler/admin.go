@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yokitheyo/go_wallet_test/internal/config"
+	"github.com/yokitheyo/go_wallet_test/internal/logctx"
+	"github.com/yokitheyo/go_wallet_test/internal/metrics"
+	"github.com/yokitheyo/go_wallet_test/internal/middleware"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"github.com/yokitheyo/go_wallet_test/internal/repo"
+	"go.uber.org/zap"
+)
+
+type adminStore interface {
+	FreezeWallet(ctx context.Context, walletID uuid.UUID, reason string) error
+	UnfreezeWallet(ctx context.Context, walletID uuid.UUID) error
+	SetMinBalance(ctx context.Context, walletID uuid.UUID, minBalance int64, reason string) (model.WalletBalance, error)
+	MinBalanceChanges(ctx context.Context, walletID uuid.UUID, limit int) ([]model.MinBalanceChange, error)
+	Reconcile(ctx context.Context, walletID *uuid.UUID) ([]model.ReconciliationMismatch, error)
+	QueueStats() (map[uuid.UUID]int, int)
+}
+
+// minBalanceHistoryLimit caps the credit limit history returned at once.
+const minBalanceHistoryLimit = 100
+
+// NewAdminRouter serves operational endpoints on the admin listener. It has
+// no authentication of its own and must only be reachable from the internal
+// network.
+func NewAdminRouter(r *repo.Repo, m *metrics.Metrics, level zap.AtomicLevel, shutdown *middleware.GracefulShutdown, cfg *config.Config, logger *zap.Logger) http.Handler {
+	router := gin.New()
+	trustProxies(router, cfg.HTTP.TrustedProxies, logger)
+	router.Use(gin.Recovery())
+	router.Use(middleware.RequestID(logger))
+	router.Use(middleware.Logger(logger))
+
+	router.GET("/metrics", gin.WrapH(m.Handler()))
+	router.GET("/livez", livez())
+	router.GET("/readyz", readyz(r, shutdown.IsShuttingDown, newReadinessConfig(cfg), logger))
+	router.GET("/log/level", gin.WrapH(level))
+	router.PUT("/log/level", gin.WrapH(level))
+	registerAdminRoutes(router.Group(""), r, cfg.Queue.Capacity, logger)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/", router)
+	return mux
+}
+
+func registerAdminRoutes(g *gin.RouterGroup, s adminStore, queueCapacity int, logger *zap.Logger) {
+	g.GET("/queues", listQueues(s, queueCapacity))
+	g.POST("/wallets/:id/freeze", freezeWallet(s, logger))
+	g.POST("/wallets/:id/unfreeze", unfreezeWallet(s, logger))
+	g.PUT("/wallets/:id/min-balance", setMinBalance(s, logger))
+	g.GET("/wallets/:id/min-balance/changes", listMinBalanceChanges(s, logger))
+	g.POST("/reconcile", reconcile(s, logger))
+}
+
+func parseWalletParam(c *gin.Context, logger *zap.Logger) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		logger.Warn("invalid wallet id", zap.String("id", c.Param("id")), zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid uuid"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func respondAdminError(c *gin.Context, logger *zap.Logger, op string, err error) {
+	if middleware.AbortUnavailable(c, err) {
+		logger.Warn("database unavailable on "+op, zap.Error(err))
+		return
+	}
+	if errors.Is(err, repo.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	logger.Error("internal error on "+op, zap.Error(err))
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error", "detail": err.Error()})
+}
+
+// listQueues reports wallet queues that have pending jobs, fullest first.
+func listQueues(s adminStore, capacity int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		depths, workers := s.QueueStats()
+		queues := []model.QueueInfo{}
+		for id, depth := range depths {
+			if depth > 0 {
+				queues = append(queues, model.QueueInfo{WalletID: id, Depth: depth})
+			}
+		}
+		sort.Slice(queues, func(i, j int) bool { return queues[i].Depth > queues[j].Depth })
+
+		c.JSON(http.StatusOK, gin.H{"capacity": capacity, "workers": workers, "queues": queues})
+	}
+}
+
+func freezeWallet(s adminStore, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := logctx.Logger(c.Request.Context(), logger)
+
+		id, ok := parseWalletParam(c, log)
+		if !ok {
+			return
+		}
+		var req model.FreezeWalletRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				log.Warn("invalid request payload in freezeWallet", zap.Error(err))
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload", "detail": err.Error()})
+				return
+			}
+		}
+		if err := s.FreezeWallet(c.Request.Context(), id, req.Reason); err != nil {
+			respondAdminError(c, log, "FreezeWallet", err)
+			return
+		}
+		log.Info("wallet frozen", zap.String("wallet_id", id.String()), zap.String("reason", req.Reason))
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func unfreezeWallet(s adminStore, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := logctx.Logger(c.Request.Context(), logger)
+
+		id, ok := parseWalletParam(c, log)
+		if !ok {
+			return
+		}
+		if err := s.UnfreezeWallet(c.Request.Context(), id); err != nil {
+			respondAdminError(c, log, "UnfreezeWallet", err)
+			return
+		}
+		log.Info("wallet unfrozen", zap.String("wallet_id", id.String()))
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func setMinBalance(s adminStore, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := logctx.Logger(c.Request.Context(), logger)
+
+		id, ok := parseWalletParam(c, log)
+		if !ok {
+			return
+		}
+		var req model.SetMinBalanceRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			log.Warn("invalid request payload in setMinBalance", zap.Error(err))
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload", "detail": err.Error()})
+			return
+		}
+		bal, err := s.SetMinBalance(c.Request.Context(), id, *req.MinBalance, req.Reason)
+		if err != nil {
+			respondAdminError(c, log, "SetMinBalance", err)
+			return
+		}
+		log.Info("wallet min balance set", zap.String("wallet_id", id.String()),
+			zap.Int64("min_balance", bal.MinBalance), zap.String("reason", req.Reason))
+		c.JSON(http.StatusOK, bal)
+	}
+}
+
+func listMinBalanceChanges(s adminStore, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := logctx.Logger(c.Request.Context(), logger)
+
+		id, ok := parseWalletParam(c, log)
+		if !ok {
+			return
+		}
+		changes, err := s.MinBalanceChanges(c.Request.Context(), id, minBalanceHistoryLimit)
+		if err != nil {
+			respondAdminError(c, log, "MinBalanceChanges", err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"changes": changes})
+	}
+}
+
+func reconcile(s adminStore, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := logctx.Logger(c.Request.Context(), logger)
+
+		var walletID *uuid.UUID
+		if raw := c.Query("walletId"); raw != "" {
+			id, err := uuid.Parse(raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid uuid"})
+				return
+			}
+			walletID = &id
+		}
+
+		mismatches, err := s.Reconcile(c.Request.Context(), walletID)
+		if err != nil {
+			respondAdminError(c, log, "Reconcile", err)
+			return
+		}
+		if len(mismatches) > 0 {
+			log.Warn("reconciliation found mismatches", zap.Int("count", len(mismatches)))
+		}
+		c.JSON(http.StatusOK, gin.H{"mismatches": mismatches})
+	}
+}
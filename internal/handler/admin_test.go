@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"github.com/yokitheyo/go_wallet_test/internal/repo"
+	"go.uber.org/zap"
+)
+
+type MockAdminStore struct {
+	mock.Mock
+}
+
+func (m *MockAdminStore) FreezeWallet(ctx context.Context, walletID uuid.UUID, reason string) error {
+	return m.Called(ctx, walletID, reason).Error(0)
+}
+
+func (m *MockAdminStore) UnfreezeWallet(ctx context.Context, walletID uuid.UUID) error {
+	return m.Called(ctx, walletID).Error(0)
+}
+
+func (m *MockAdminStore) SetMinBalance(ctx context.Context, walletID uuid.UUID, minBalance int64, reason string) (model.WalletBalance, error) {
+	args := m.Called(ctx, walletID, minBalance, reason)
+	return args.Get(0).(model.WalletBalance), args.Error(1)
+}
+
+func (m *MockAdminStore) MinBalanceChanges(ctx context.Context, walletID uuid.UUID, limit int) ([]model.MinBalanceChange, error) {
+	args := m.Called(ctx, walletID, limit)
+	return args.Get(0).([]model.MinBalanceChange), args.Error(1)
+}
+
+func (m *MockAdminStore) Reconcile(ctx context.Context, walletID *uuid.UUID) ([]model.ReconciliationMismatch, error) {
+	args := m.Called(ctx, walletID)
+	return args.Get(0).([]model.ReconciliationMismatch), args.Error(1)
+}
+
+func (m *MockAdminStore) QueueStats() (map[uuid.UUID]int, int) {
+	args := m.Called()
+	return args.Get(0).(map[uuid.UUID]int), args.Int(1)
+}
+
+func newAdminTestRouter(s adminStore) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	registerAdminRoutes(router.Group(""), s, testQueueCapacity, zap.NewNop())
+	return router
+}
+
+func doAdmin(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAdmin_FreezeUnfreeze(t *testing.T) {
+	id := uuid.New()
+	missing := uuid.New()
+	s := &MockAdminStore{}
+	s.On("FreezeWallet", mock.Anything, id, "chargeback").Return(nil)
+	s.On("FreezeWallet", mock.Anything, missing, "").Return(repo.ErrNotFound)
+	s.On("UnfreezeWallet", mock.Anything, id).Return(nil)
+	router := newAdminTestRouter(s)
+
+	assert.Equal(t, http.StatusNoContent, doAdmin(router, http.MethodPost, "/wallets/"+id.String()+"/freeze", `{"reason":"chargeback"}`).Code)
+	assert.Equal(t, http.StatusNotFound, doAdmin(router, http.MethodPost, "/wallets/"+missing.String()+"/freeze", "").Code)
+	assert.Equal(t, http.StatusBadRequest, doAdmin(router, http.MethodPost, "/wallets/nope/freeze", "").Code)
+	assert.Equal(t, http.StatusNoContent, doAdmin(router, http.MethodPost, "/wallets/"+id.String()+"/unfreeze", "").Code)
+	s.AssertExpectations(t)
+}
+
+func TestAdmin_MinBalance(t *testing.T) {
+	id := uuid.New()
+	s := &MockAdminStore{}
+	s.On("SetMinBalance", mock.Anything, id, int64(-5000), "contract 17").
+		Return(model.NewWalletBalance(-1200, -5000), nil)
+	s.On("MinBalanceChanges", mock.Anything, id, minBalanceHistoryLimit).Return([]model.MinBalanceChange{
+		{ID: 1, WalletID: id, OldMinBalance: 0, NewMinBalance: -5000, Reason: "contract 17"},
+	}, nil)
+	router := newAdminTestRouter(s)
+
+	w := doAdmin(router, http.MethodPut, "/wallets/"+id.String()+"/min-balance", `{"minBalance":-5000,"reason":"contract 17"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"balance":-1200,"minBalance":-5000,"creditUsed":1200,"creditAvailable":3800}`, w.Body.String())
+
+	assert.Equal(t, http.StatusBadRequest, doAdmin(router, http.MethodPut, "/wallets/"+id.String()+"/min-balance", `{"minBalance":100}`).Code)
+	assert.Equal(t, http.StatusBadRequest, doAdmin(router, http.MethodPut, "/wallets/"+id.String()+"/min-balance", `{}`).Code)
+
+	w = doAdmin(router, http.MethodGet, "/wallets/"+id.String()+"/min-balance/changes", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Changes []model.MinBalanceChange `json:"changes"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Changes, 1)
+	assert.Equal(t, int64(-5000), resp.Changes[0].NewMinBalance)
+	s.AssertExpectations(t)
+}
+
+func TestAdmin_Reconcile(t *testing.T) {
+	id := uuid.New()
+	s := &MockAdminStore{}
+	s.On("Reconcile", mock.Anything, (*uuid.UUID)(nil)).Return([]model.ReconciliationMismatch{
+		{WalletID: id, Balance: 100, LedgerBalance: 90, LedgerEntryID: 7},
+	}, nil)
+	s.On("Reconcile", mock.Anything, &id).Return([]model.ReconciliationMismatch{}, nil)
+	router := newAdminTestRouter(s)
+
+	w := doAdmin(router, http.MethodPost, "/reconcile", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Mismatches []model.ReconciliationMismatch `json:"mismatches"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, int64(90), resp.Mismatches[0].LedgerBalance)
+
+	w = doAdmin(router, http.MethodPost, "/reconcile?walletId="+id.String(), "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"mismatches":[]}`, w.Body.String())
+
+	assert.Equal(t, http.StatusBadRequest, doAdmin(router, http.MethodPost, "/reconcile?walletId=nope", "").Code)
+}
+
+func TestAdmin_ListQueues(t *testing.T) {
+	busy, busier, idle := uuid.New(), uuid.New(), uuid.New()
+	s := &MockAdminStore{}
+	s.On("QueueStats").Return(map[uuid.UUID]int{busy: 3, busier: 9, idle: 0}, 3)
+	router := newAdminTestRouter(s)
+
+	w := doAdmin(router, http.MethodGet, "/queues", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Capacity int               `json:"capacity"`
+		Workers  int               `json:"workers"`
+		Queues   []model.QueueInfo `json:"queues"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, testQueueCapacity, resp.Capacity)
+	assert.Equal(t, 3, resp.Workers)
+	assert.Equal(t, []model.QueueInfo{{WalletID: busier, Depth: 9}, {WalletID: busy, Depth: 3}}, resp.Queues)
+}
@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yokitheyo/go_wallet_test/internal/auth"
+	"github.com/yokitheyo/go_wallet_test/internal/logctx"
+	"github.com/yokitheyo/go_wallet_test/internal/middleware"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"github.com/yokitheyo/go_wallet_test/internal/repo"
+	"go.uber.org/zap"
+)
+
+type apiKeyStore interface {
+	CreateAPIKey(ctx context.Context, key model.APIKey, hash string) (model.APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]model.APIKey, error)
+	RotateAPIKey(ctx context.Context, id uuid.UUID, hash, prefix string) (model.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id uuid.UUID) error
+}
+
+func registerAPIKeyRoutes(g *gin.RouterGroup, s apiKeyStore, invalidate func(), logger *zap.Logger) {
+	g.POST("/keys", createAPIKey(s, logger))
+	g.GET("/keys", listAPIKeys(s, logger))
+	g.POST("/keys/:id/rotate", rotateAPIKey(s, invalidate, logger))
+	g.DELETE("/keys/:id", revokeAPIKey(s, invalidate, logger))
+}
+
+func respondAPIKeyError(c *gin.Context, logger *zap.Logger, op string, err error) {
+	if middleware.AbortUnavailable(c, err) {
+		logger.Warn("database unavailable on "+op, zap.Error(err))
+		return
+	}
+	if errors.Is(err, repo.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+		return
+	}
+	logger.Error("internal error on "+op, zap.Error(err))
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error", "detail": err.Error()})
+}
+
+func parseAPIKeyID(c *gin.Context, logger *zap.Logger) (uuid.UUID, bool) {
+	idStr := c.Param("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		logger.Warn("invalid api key id", zap.String("id", idStr), zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid uuid"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func createAPIKey(s apiKeyStore, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := logctx.Logger(c.Request.Context(), logger)
+
+		var req model.CreateAPIKeyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			log.Warn("invalid request payload in createAPIKey", zap.Error(err))
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":  "invalid request payload",
+				"detail": err.Error(),
+			})
+			return
+		}
+
+		plain, hash, prefix, err := auth.GenerateAPIKey()
+		if err != nil {
+			respondAPIKeyError(c, log, "GenerateAPIKey", err)
+			return
+		}
+
+		key, err := s.CreateAPIKey(c.Request.Context(), model.APIKey{
+			ID:        uuid.New(),
+			Name:      req.Name,
+			Prefix:    prefix,
+			Scopes:    req.Scopes,
+			WalletIDs: req.WalletIDs,
+		}, hash)
+		if err != nil {
+			respondAPIKeyError(c, log, "CreateAPIKey", err)
+			return
+		}
+
+		log.Info("api key created", zap.String("key_id", key.ID.String()), zap.String("name", key.Name), zap.Any("scopes", key.Scopes))
+		c.JSON(http.StatusCreated, model.APIKeyWithSecret{APIKey: key, Key: plain})
+	}
+}
+
+func listAPIKeys(s apiKeyStore, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := logctx.Logger(c.Request.Context(), logger)
+
+		keys, err := s.ListAPIKeys(c.Request.Context())
+		if err != nil {
+			respondAPIKeyError(c, log, "ListAPIKeys", err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"keys": keys})
+	}
+}
+
+func rotateAPIKey(s apiKeyStore, invalidate func(), logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := logctx.Logger(c.Request.Context(), logger)
+
+		id, ok := parseAPIKeyID(c, log)
+		if !ok {
+			return
+		}
+
+		plain, hash, prefix, err := auth.GenerateAPIKey()
+		if err != nil {
+			respondAPIKeyError(c, log, "GenerateAPIKey", err)
+			return
+		}
+
+		key, err := s.RotateAPIKey(c.Request.Context(), id, hash, prefix)
+		if err != nil {
+			respondAPIKeyError(c, log, "RotateAPIKey", err)
+			return
+		}
+		invalidate()
+
+		log.Info("api key rotated", zap.String("key_id", id.String()))
+		c.JSON(http.StatusOK, model.APIKeyWithSecret{APIKey: key, Key: plain})
+	}
+}
+
+func revokeAPIKey(s apiKeyStore, invalidate func(), logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := logctx.Logger(c.Request.Context(), logger)
+
+		id, ok := parseAPIKeyID(c, log)
+		if !ok {
+			return
+		}
+		if err := s.RevokeAPIKey(c.Request.Context(), id); err != nil {
+			respondAPIKeyError(c, log, "RevokeAPIKey", err)
+			return
+		}
+		invalidate()
+
+		log.Info("api key revoked", zap.String("key_id", id.String()))
+		c.Status(http.StatusNoContent)
+	}
+}
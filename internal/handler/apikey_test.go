@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/yokitheyo/go_wallet_test/internal/auth"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"github.com/yokitheyo/go_wallet_test/internal/repo"
+	"go.uber.org/zap"
+)
+
+type MockAPIKeyStore struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyStore) CreateAPIKey(ctx context.Context, key model.APIKey, hash string) (model.APIKey, error) {
+	args := m.Called(ctx, key, hash)
+	return args.Get(0).(model.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyStore) ListAPIKeys(ctx context.Context) ([]model.APIKey, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]model.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyStore) RotateAPIKey(ctx context.Context, id uuid.UUID, hash, prefix string) (model.APIKey, error) {
+	args := m.Called(ctx, id, hash, prefix)
+	return args.Get(0).(model.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyStore) RevokeAPIKey(ctx context.Context, id uuid.UUID) error {
+	return m.Called(ctx, id).Error(0)
+}
+
+func setupAPIKeyRouter() (*gin.Engine, *MockAPIKeyStore, *int) {
+	gin.SetMode(gin.TestMode)
+	store := &MockAPIKeyStore{}
+	invalidations := 0
+	router := gin.New()
+	registerAPIKeyRoutes(router.Group("/api/v1/admin"), store, func() { invalidations++ }, zap.NewNop())
+	return router, store, &invalidations
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	router, store, _ := setupAPIKeyRouter()
+
+	var storedHash string
+	store.On("CreateAPIKey", mock.Anything, mock.MatchedBy(func(k model.APIKey) bool {
+		return k.Name == "dashboard" && len(k.Scopes) == 1 && k.Scopes[0] == model.ScopeWalletRead
+	}), mock.Anything).Run(func(args mock.Arguments) {
+		storedHash = args.String(2)
+	}).Return(model.APIKey{ID: uuid.New(), Name: "dashboard", Scopes: []model.Scope{model.ScopeWalletRead}}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/admin/keys", bytes.NewBufferString(`{"name":"dashboard","scopes":["wallet:read"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response model.APIKeyWithSecret
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, strings.HasPrefix(response.Key, "wk_"))
+	assert.Equal(t, auth.HashAPIKey(response.Key), storedHash)
+	store.AssertExpectations(t)
+}
+
+func TestCreateAPIKey_InvalidScope(t *testing.T) {
+	router, store, _ := setupAPIKeyRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/admin/keys", bytes.NewBufferString(`{"name":"x","scopes":["wallet:delete"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	store.AssertNotCalled(t, "CreateAPIKey", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRotateAPIKey(t *testing.T) {
+	router, store, invalidations := setupAPIKeyRouter()
+
+	id := uuid.New()
+	store.On("RotateAPIKey", mock.Anything, id, mock.Anything, mock.Anything).Return(model.APIKey{ID: id}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/admin/keys/"+id.String()+"/rotate", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"key":"wk_`)
+	assert.Equal(t, 1, *invalidations)
+	store.AssertExpectations(t)
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	router, store, invalidations := setupAPIKeyRouter()
+
+	id := uuid.New()
+	missing := uuid.New()
+	store.On("RevokeAPIKey", mock.Anything, id).Return(nil)
+	store.On("RevokeAPIKey", mock.Anything, missing).Return(repo.ErrNotFound)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/api/v1/admin/keys/"+id.String(), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, 1, *invalidations)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/api/v1/admin/keys/"+missing.String(), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
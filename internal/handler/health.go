@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yokitheyo/go_wallet_test/internal/config"
+	"github.com/yokitheyo/go_wallet_test/internal/repo"
+	"go.uber.org/zap"
+)
+
+type readinessSource interface {
+	Ping(ctx context.Context) error
+	SchemaVersion(ctx context.Context) (int64, error)
+	QueueStats() (map[uuid.UUID]int, int)
+}
+
+type readinessConfig struct {
+	Timeout         time.Duration
+	QueueSaturation float64
+	Migrations      fs.FS
+	QueueCapacity   int
+}
+
+func newReadinessConfig(cfg *config.Config) readinessConfig {
+	return readinessConfig{
+		Timeout:         cfg.Health.Timeout,
+		QueueSaturation: cfg.Health.QueueSaturation,
+		Migrations:      repo.Migrations(cfg.DB.MigrationsDir),
+		QueueCapacity:   cfg.Queue.Capacity,
+	}
+}
+
+type checkResult struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+	Details   any     `json:"details,omitempty"`
+}
+
+type readinessCheck struct {
+	name string
+	run  func(ctx context.Context) (details any, err error)
+}
+
+// livez only reports that the process is serving HTTP. It stays 200 while
+// draining so the orchestrator does not kill a pod that is finishing work.
+func livez() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	}
+}
+
+func readyz(src readinessSource, isShuttingDown func() bool, cfg readinessConfig, logger *zap.Logger) gin.HandlerFunc {
+	checks := []readinessCheck{
+		{name: "shutdown", run: func(context.Context) (any, error) {
+			if isShuttingDown() {
+				return nil, fmt.Errorf("server is shutting down")
+			}
+			return nil, nil
+		}},
+		{name: "database", run: func(ctx context.Context) (any, error) {
+			return nil, src.Ping(ctx)
+		}},
+		{name: "migrations", run: func(ctx context.Context) (any, error) {
+			current, err := src.SchemaVersion(ctx)
+			if err != nil {
+				return nil, err
+			}
+			latest, err := repo.LatestMigration(cfg.Migrations)
+			if err != nil {
+				return nil, err
+			}
+			details := gin.H{"current": current, "latest": latest}
+			if current < latest {
+				return details, fmt.Errorf("schema version %d is behind %d", current, latest)
+			}
+			return details, nil
+		}},
+		{name: "queues", run: func(context.Context) (any, error) {
+			depths, workers := src.QueueStats()
+			maxDepth := 0
+			for _, d := range depths {
+				maxDepth = max(maxDepth, d)
+			}
+			details := gin.H{"maxDepth": maxDepth, "capacity": cfg.QueueCapacity, "workers": workers}
+			if float64(maxDepth) >= cfg.QueueSaturation*float64(cfg.QueueCapacity) {
+				return details, fmt.Errorf("wallet queue is %d/%d full", maxDepth, cfg.QueueCapacity)
+			}
+			return details, nil
+		}},
+	}
+
+	return func(c *gin.Context) {
+		status := http.StatusOK
+		results := make([]checkResult, 0, len(checks))
+		for _, check := range checks {
+			ctx, cancel := context.WithTimeout(c.Request.Context(), cfg.Timeout)
+			start := time.Now()
+			details, err := check.run(ctx)
+			cancel()
+
+			res := checkResult{
+				Name:      check.name,
+				Status:    "ok",
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+				Details:   details,
+			}
+			if err != nil {
+				res.Status = "fail"
+				res.Error = err.Error()
+				status = http.StatusServiceUnavailable
+			}
+			results = append(results, res)
+		}
+
+		overall := "ready"
+		if status != http.StatusOK {
+			overall = "not_ready"
+			logger.Warn("readiness check failed", zap.Any("checks", results))
+		}
+		c.JSON(status, gin.H{"status": overall, "checks": results})
+	}
+}
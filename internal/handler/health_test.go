@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type MockReadinessSource struct {
+	mock.Mock
+}
+
+func (m *MockReadinessSource) Ping(ctx context.Context) error {
+	return m.Called(ctx).Error(0)
+}
+
+func (m *MockReadinessSource) SchemaVersion(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockReadinessSource) QueueStats() (map[uuid.UUID]int, int) {
+	args := m.Called()
+	return args.Get(0).(map[uuid.UUID]int), args.Int(1)
+}
+
+type readyzResponse struct {
+	Status string        `json:"status"`
+	Checks []checkResult `json:"checks"`
+}
+
+func migrationsDir(t *testing.T) string {
+	dir := t.TempDir()
+	for _, name := range []string{"00001_first.sql", "00002_second.sql"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("-- +goose Up\nSELECT 1;\n"), 0o644))
+	}
+	return dir
+}
+
+const testQueueCapacity = 1000
+
+func doReadyz(t *testing.T, src readinessSource, shuttingDown bool, dir string) (int, readyzResponse) {
+	router := gin.New()
+	router.GET("/readyz", readyz(src, func() bool { return shuttingDown }, readinessConfig{
+		Timeout:         time.Second,
+		QueueSaturation: 0.8,
+		Migrations:      os.DirFS(dir),
+		QueueCapacity:   testQueueCapacity,
+	}, zap.NewNop()))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var resp readyzResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return w.Code, resp
+}
+
+func checkStatuses(resp readyzResponse) map[string]string {
+	statuses := map[string]string{}
+	for _, c := range resp.Checks {
+		statuses[c.Name] = c.Status
+	}
+	return statuses
+}
+
+func TestReadyz(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := migrationsDir(t)
+
+	t.Run("ready", func(t *testing.T) {
+		src := &MockReadinessSource{}
+		src.On("Ping", mock.Anything).Return(nil)
+		src.On("SchemaVersion", mock.Anything).Return(int64(2), nil)
+		src.On("QueueStats").Return(map[uuid.UUID]int{uuid.New(): 10}, 1)
+
+		code, resp := doReadyz(t, src, false, dir)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "ready", resp.Status)
+		assert.Equal(t, map[string]string{"shutdown": "ok", "database": "ok", "migrations": "ok", "queues": "ok"}, checkStatuses(resp))
+	})
+
+	t.Run("database down, pending migrations and saturated queue", func(t *testing.T) {
+		src := &MockReadinessSource{}
+		src.On("Ping", mock.Anything).Return(errors.New("connection refused"))
+		src.On("SchemaVersion", mock.Anything).Return(int64(1), nil)
+		src.On("QueueStats").Return(map[uuid.UUID]int{uuid.New(): testQueueCapacity}, 1)
+
+		code, resp := doReadyz(t, src, false, dir)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "not_ready", resp.Status)
+		assert.Equal(t, map[string]string{"shutdown": "ok", "database": "fail", "migrations": "fail", "queues": "fail"}, checkStatuses(resp))
+		assert.Equal(t, "connection refused", resp.Checks[1].Error)
+	})
+
+	t.Run("shutting down", func(t *testing.T) {
+		src := &MockReadinessSource{}
+		src.On("Ping", mock.Anything).Return(nil)
+		src.On("SchemaVersion", mock.Anything).Return(int64(2), nil)
+		src.On("QueueStats").Return(map[uuid.UUID]int{}, 0)
+
+		code, resp := doReadyz(t, src, true, dir)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "fail", checkStatuses(resp)["shutdown"])
+	})
+}
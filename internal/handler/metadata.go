@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yokitheyo/go_wallet_test/internal/logctx"
+	"github.com/yokitheyo/go_wallet_test/internal/middleware"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"github.com/yokitheyo/go_wallet_test/internal/repo"
+	"go.uber.org/zap"
+)
+
+type walletMetadataStore interface {
+	GetWallet(ctx context.Context, walletID uuid.UUID) (model.Wallet, error)
+	GetWalletByExternalRef(ctx context.Context, ownerID, externalRef string) (model.Wallet, error)
+	SetWalletMetadata(ctx context.Context, walletID uuid.UUID, meta model.WalletMetadata) (model.Wallet, error)
+	PatchWalletMetadata(ctx context.Context, walletID uuid.UUID, req model.PatchWalletMetadataRequest) (model.Wallet, error)
+}
+
+func respondWalletError(c *gin.Context, logger *zap.Logger, op string, err error) {
+	switch {
+	case middleware.AbortUnavailable(c, err):
+		logger.Warn("database unavailable on "+op, zap.Error(err))
+	case errors.Is(err, repo.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "wallet not found"})
+	case errors.Is(err, repo.ErrConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "conflict", "detail": err.Error()})
+	case errors.Is(err, repo.ErrWalletFrozen):
+		c.JSON(http.StatusConflict, gin.H{"error": "wallet is frozen"})
+	case errors.Is(err, repo.ErrInsufficientFunds):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "insufficient funds"})
+	case errors.Is(err, repo.ErrInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid metadata", "detail": err.Error()})
+	default:
+		logger.Error("internal error on "+op, zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error", "detail": err.Error()})
+	}
+}
+
+// allowedWalletParam parses :id and checks that the caller may access it.
+func allowedWalletParam(c *gin.Context, logger *zap.Logger) (uuid.UUID, bool) {
+	id, ok := parseWalletParam(c, logger)
+	if !ok {
+		return id, false
+	}
+	if !middleware.WalletAllowed(c, id) {
+		logger.Warn("api key not allowed for wallet", zap.String("wallet_id", id.String()))
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return id, false
+	}
+	return id, true
+}
+
+func getWalletMetadata(s walletMetadataStore, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := logctx.Logger(c.Request.Context(), logger)
+
+		id, ok := allowedWalletParam(c, log)
+		if !ok {
+			return
+		}
+		w, err := s.GetWallet(c.Request.Context(), id)
+		if err != nil {
+			respondWalletError(c, log, "GetWallet", err)
+			return
+		}
+		c.JSON(http.StatusOK, w)
+	}
+}
+
+func setWalletMetadata(s walletMetadataStore, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := logctx.Logger(c.Request.Context(), logger)
+
+		id, ok := allowedWalletParam(c, log)
+		if !ok {
+			return
+		}
+
+		var meta model.WalletMetadata
+		if err := c.ShouldBindJSON(&meta); err != nil {
+			log.Warn("invalid request payload in setWalletMetadata", zap.Error(err))
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload", "detail": err.Error()})
+			return
+		}
+		if err := meta.Validate(); err != nil {
+			log.Warn("invalid wallet metadata", zap.Error(err))
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid metadata", "detail": err.Error()})
+			return
+		}
+
+		w, err := s.SetWalletMetadata(c.Request.Context(), id, meta)
+		if err != nil {
+			respondWalletError(c, log, "SetWalletMetadata", err)
+			return
+		}
+		log.Info("wallet metadata set", zap.String("wallet_id", id.String()), zap.String("owner_id", w.OwnerID))
+		c.JSON(http.StatusOK, w)
+	}
+}
+
+func patchWalletMetadata(s walletMetadataStore, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := logctx.Logger(c.Request.Context(), logger)
+
+		id, ok := allowedWalletParam(c, log)
+		if !ok {
+			return
+		}
+
+		var req model.PatchWalletMetadataRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			log.Warn("invalid request payload in patchWalletMetadata", zap.Error(err))
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload", "detail": err.Error()})
+			return
+		}
+
+		w, err := s.PatchWalletMetadata(c.Request.Context(), id, req)
+		if err != nil {
+			respondWalletError(c, log, "PatchWalletMetadata", err)
+			return
+		}
+		log.Info("wallet metadata updated", zap.String("wallet_id", id.String()), zap.String("owner_id", w.OwnerID))
+		c.JSON(http.StatusOK, w)
+	}
+}
+
+// lookupWallet finds a wallet by owner and external reference. A wallet the
+// caller may not access is reported as not found, so the lookup does not
+// reveal which references exist.
+func lookupWallet(s walletMetadataStore, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := logctx.Logger(c.Request.Context(), logger)
+
+		ownerID, externalRef := c.Query("ownerId"), c.Query("externalRef")
+		if ownerID == "" || externalRef == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ownerId and externalRef are required"})
+			return
+		}
+
+		w, err := s.GetWalletByExternalRef(c.Request.Context(), ownerID, externalRef)
+		if err == nil && !middleware.WalletAllowed(c, w.ID) {
+			log.Warn("api key not allowed for wallet", zap.String("wallet_id", w.ID.String()))
+			err = repo.ErrNotFound
+		}
+		if err != nil {
+			respondWalletError(c, log, "GetWalletByExternalRef", err)
+			return
+		}
+		c.JSON(http.StatusOK, w)
+	}
+}
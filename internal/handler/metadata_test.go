@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"github.com/yokitheyo/go_wallet_test/internal/repo"
+	"go.uber.org/zap"
+)
+
+type MockWalletMetadataStore struct {
+	mock.Mock
+}
+
+func (m *MockWalletMetadataStore) GetWallet(ctx context.Context, walletID uuid.UUID) (model.Wallet, error) {
+	args := m.Called(ctx, walletID)
+	return args.Get(0).(model.Wallet), args.Error(1)
+}
+
+func (m *MockWalletMetadataStore) GetWalletByExternalRef(ctx context.Context, ownerID, externalRef string) (model.Wallet, error) {
+	args := m.Called(ctx, ownerID, externalRef)
+	return args.Get(0).(model.Wallet), args.Error(1)
+}
+
+func (m *MockWalletMetadataStore) SetWalletMetadata(ctx context.Context, walletID uuid.UUID, meta model.WalletMetadata) (model.Wallet, error) {
+	args := m.Called(ctx, walletID, meta)
+	return args.Get(0).(model.Wallet), args.Error(1)
+}
+
+func (m *MockWalletMetadataStore) PatchWalletMetadata(ctx context.Context, walletID uuid.UUID, req model.PatchWalletMetadataRequest) (model.Wallet, error) {
+	args := m.Called(ctx, walletID, req)
+	return args.Get(0).(model.Wallet), args.Error(1)
+}
+
+func setupMetadataRouter() (*gin.Engine, *MockWalletMetadataStore) {
+	gin.SetMode(gin.TestMode)
+	store := &MockWalletMetadataStore{}
+	router := gin.New()
+	router.GET("/api/v1/wallets/lookup", lookupWallet(store, zap.NewNop()))
+	router.GET("/api/v1/wallets/:id/metadata", getWalletMetadata(store, zap.NewNop()))
+	router.PUT("/api/v1/wallets/:id/metadata", setWalletMetadata(store, zap.NewNop()))
+	router.PATCH("/api/v1/wallets/:id/metadata", patchWalletMetadata(store, zap.NewNop()))
+	return router, store
+}
+
+func doMetadata(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestSetWalletMetadata(t *testing.T) {
+	router, store := setupMetadataRouter()
+
+	id := uuid.New()
+	meta := model.WalletMetadata{OwnerID: "cust-1", ExternalRef: "main", DisplayName: "Main", Labels: map[string]any{"tier": "gold"}}
+	store.On("SetWalletMetadata", mock.Anything, id, meta).
+		Return(model.Wallet{ID: id, OwnerID: "cust-1", ExternalRef: "main", DisplayName: "Main", Labels: meta.Labels}, nil)
+
+	w := doMetadata(router, http.MethodPut, "/api/v1/wallets/"+id.String()+"/metadata",
+		`{"ownerId":"cust-1","externalRef":"main","displayName":"Main","labels":{"tier":"gold"}}`)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp model.Wallet
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "cust-1", resp.OwnerID)
+	assert.Equal(t, "gold", resp.Labels["tier"])
+	store.AssertExpectations(t)
+}
+
+func TestSetWalletMetadata_Invalid(t *testing.T) {
+	router, store := setupMetadataRouter()
+	path := "/api/v1/wallets/" + uuid.New().String() + "/metadata"
+
+	manyLabels := make([]string, model.MaxLabels+1)
+	for i := range manyLabels {
+		manyLabels[i] = fmt.Sprintf(`"k%d":1`, i)
+	}
+
+	for name, body := range map[string]string{
+		"ref without owner":  `{"externalRef":"main"}`,
+		"bad label key":      `{"labels":{"has space":"x"}}`,
+		"too many labels":    `{"labels":{` + strings.Join(manyLabels, ",") + `}}`,
+		"labels too large":   `{"labels":{"blob":"` + strings.Repeat("x", model.MaxLabelsBytes) + `"}}`,
+		"display name limit": `{"displayName":"` + strings.Repeat("x", 201) + `"}`,
+		"labels not object":  `{"labels":["a"]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := doMetadata(router, http.MethodPut, path, body)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+	store.AssertNotCalled(t, "SetWalletMetadata", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPatchWalletMetadata_Errors(t *testing.T) {
+	router, store := setupMetadataRouter()
+
+	conflict, invalid, missing := uuid.New(), uuid.New(), uuid.New()
+	store.On("PatchWalletMetadata", mock.Anything, conflict, mock.Anything).
+		Return(model.Wallet{}, fmt.Errorf("%w: duplicate external reference", repo.ErrConflict))
+	store.On("PatchWalletMetadata", mock.Anything, invalid, mock.Anything).
+		Return(model.Wallet{}, fmt.Errorf("%w: too many labels", repo.ErrInvalid))
+	store.On("PatchWalletMetadata", mock.Anything, missing, mock.Anything).
+		Return(model.Wallet{}, repo.ErrNotFound)
+
+	assert.Equal(t, http.StatusConflict, doMetadata(router, http.MethodPatch, "/api/v1/wallets/"+conflict.String()+"/metadata", `{"externalRef":"main"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, doMetadata(router, http.MethodPatch, "/api/v1/wallets/"+invalid.String()+"/metadata", `{"labels":{"a":1}}`).Code)
+	assert.Equal(t, http.StatusNotFound, doMetadata(router, http.MethodPatch, "/api/v1/wallets/"+missing.String()+"/metadata", `{}`).Code)
+	store.AssertExpectations(t)
+}
+
+func TestLookupWallet(t *testing.T) {
+	router, store := setupMetadataRouter()
+
+	id := uuid.New()
+	store.On("GetWalletByExternalRef", mock.Anything, "cust-1", "main").Return(model.Wallet{ID: id, Balance: 500}, nil)
+	store.On("GetWalletByExternalRef", mock.Anything, "cust-1", "savings").Return(model.Wallet{}, repo.ErrNotFound)
+
+	w := doMetadata(router, http.MethodGet, "/api/v1/wallets/lookup?ownerId=cust-1&externalRef=main", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), id.String())
+
+	w = doMetadata(router, http.MethodGet, "/api/v1/wallets/lookup?ownerId=cust-1&externalRef=savings", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = doMetadata(router, http.MethodGet, "/api/v1/wallets/lookup?ownerId=cust-1", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	store.AssertExpectations(t)
+}
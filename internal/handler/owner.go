@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yokitheyo/go_wallet_test/internal/logctx"
+	"github.com/yokitheyo/go_wallet_test/internal/metrics"
+	"github.com/yokitheyo/go_wallet_test/internal/middleware"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"github.com/yokitheyo/go_wallet_test/internal/repo"
+	"go.uber.org/zap"
+)
+
+type ownerStore interface {
+	OwnerWallets(ctx context.Context, ownerID string) ([]model.Wallet, error)
+	Transfer(ctx context.Context, ownerID string, req model.TransferRequest) (model.Transfer, error)
+}
+
+// getOwnerWallets lists the wallets of an owner with their total balance. A
+// caller limited to some wallets sees only those, and an owner with none of
+// them visible is reported as not found.
+func getOwnerWallets(s ownerStore, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := logctx.Logger(c.Request.Context(), logger)
+		ownerID := c.Param("id")
+
+		wallets, err := s.OwnerWallets(c.Request.Context(), ownerID)
+		if err != nil {
+			respondWalletError(c, log, "OwnerWallets", err)
+			return
+		}
+		visible := wallets[:0]
+		for _, w := range wallets {
+			if middleware.WalletAllowed(c, w.ID) {
+				visible = append(visible, w)
+			}
+		}
+		if len(visible) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "owner not found"})
+			return
+		}
+		c.JSON(http.StatusOK, model.NewOwnerWallets(ownerID, visible))
+	}
+}
+
+// transferBetweenOwnWallets moves money between two wallets of one owner.
+// It is registered outside the per-wallet rate limit; the IP and client
+// limits still apply.
+func transferBetweenOwnWallets(s ownerStore, m *metrics.Metrics, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := logctx.Logger(c.Request.Context(), logger)
+		ownerID := c.Param("id")
+
+		var req model.TransferRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			log.Warn("invalid request payload in transfer", zap.Error(err))
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload", "detail": err.Error()})
+			return
+		}
+		if req.FromWalletID == req.ToWalletID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload", "detail": "fromWalletId and toWalletId must differ"})
+			return
+		}
+		for _, id := range []uuid.UUID{req.FromWalletID, req.ToWalletID} {
+			if !middleware.WalletAllowed(c, id) {
+				log.Warn("api key not allowed for wallet", zap.String("wallet_id", id.String()))
+				c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+				return
+			}
+		}
+
+		t, err := s.Transfer(c.Request.Context(), ownerID, req)
+		m.ObserveOperation(metrics.OperationTransfer, transferOutcome(err), req.Amount)
+		if err != nil {
+			respondWalletError(c, log, "Transfer", err)
+			return
+		}
+		log.Info("transfer completed",
+			zap.String("transfer_id", t.ID.String()),
+			zap.String("owner_id", ownerID),
+			zap.String("from_wallet_id", t.FromWalletID.String()),
+			zap.String("to_wallet_id", t.ToWalletID.String()),
+			zap.Int64("amount", t.Amount))
+		c.JSON(http.StatusOK, t)
+	}
+}
+
+func transferOutcome(err error) string {
+	switch {
+	case err == nil:
+		return metrics.OutcomeOK
+	case errors.Is(err, repo.ErrUnavailable):
+		return metrics.OutcomeUnavailable
+	case errors.Is(err, repo.ErrWalletFrozen):
+		return metrics.OutcomeFrozen
+	case errors.Is(err, repo.ErrInsufficientFunds):
+		return metrics.OutcomeInsufficient
+	default:
+		return metrics.OutcomeError
+	}
+}
@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yokitheyo/go_wallet_test/internal/metrics"
+	"github.com/yokitheyo/go_wallet_test/internal/middleware"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"github.com/yokitheyo/go_wallet_test/internal/repo"
+	"go.uber.org/zap"
+)
+
+type MockOwnerStore struct {
+	mock.Mock
+}
+
+func (m *MockOwnerStore) OwnerWallets(ctx context.Context, ownerID string) ([]model.Wallet, error) {
+	args := m.Called(ctx, ownerID)
+	return args.Get(0).([]model.Wallet), args.Error(1)
+}
+
+func (m *MockOwnerStore) Transfer(ctx context.Context, ownerID string, req model.TransferRequest) (model.Transfer, error) {
+	args := m.Called(ctx, ownerID, req)
+	return args.Get(0).(model.Transfer), args.Error(1)
+}
+
+func setupOwnerRouter(principal *model.Principal) (*gin.Engine, *MockOwnerStore) {
+	gin.SetMode(gin.TestMode)
+	store := &MockOwnerStore{}
+	router := gin.New()
+	if principal != nil {
+		router.Use(func(c *gin.Context) { middleware.SetPrincipal(c, *principal) })
+	}
+	router.GET("/api/v1/owners/:id/wallets", getOwnerWallets(store, zap.NewNop()))
+	router.POST("/api/v1/owners/:id/transfers", transferBetweenOwnWallets(store, metrics.New(), zap.NewNop()))
+	return router, store
+}
+
+func TestGetOwnerWallets(t *testing.T) {
+	main, savings := uuid.New(), uuid.New()
+	wallets := func() []model.Wallet {
+		return []model.Wallet{
+			{ID: main, OwnerID: "cust-1", ExternalRef: "main", Balance: 1000},
+			{ID: savings, OwnerID: "cust-1", ExternalRef: "savings", Balance: 250},
+		}
+	}
+
+	t.Run("totals", func(t *testing.T) {
+		router, store := setupOwnerRouter(nil)
+		store.On("OwnerWallets", mock.Anything, "cust-1").Return(wallets(), nil)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/owners/cust-1/wallets", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp model.OwnerWallets
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Len(t, resp.Wallets, 2)
+		assert.Equal(t, model.OwnerTotals{Wallets: 2, Balance: 1250}, resp.Total)
+	})
+
+	t.Run("restricted principal sees only its wallets", func(t *testing.T) {
+		router, store := setupOwnerRouter(&model.Principal{WalletIDs: []uuid.UUID{savings}})
+		store.On("OwnerWallets", mock.Anything, "cust-1").Return(wallets(), nil)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/owners/cust-1/wallets", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp model.OwnerWallets
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		require.Len(t, resp.Wallets, 1)
+		assert.Equal(t, savings, resp.Wallets[0].ID)
+		assert.Equal(t, int64(250), resp.Total.Balance)
+	})
+
+	t.Run("unknown owner", func(t *testing.T) {
+		router, store := setupOwnerRouter(nil)
+		store.On("OwnerWallets", mock.Anything, "nobody").Return([]model.Wallet{}, nil)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/owners/nobody/wallets", nil))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestTransferBetweenOwnWallets(t *testing.T) {
+	from, to := uuid.New(), uuid.New()
+	body := fmt.Sprintf(`{"fromWalletId":%q,"toWalletId":%q,"amount":300}`, from, to)
+	req := model.TransferRequest{FromWalletID: from, ToWalletID: to, Amount: 300}
+	post := func(router *gin.Engine, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/owners/cust-1/transfers", bytes.NewBufferString(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("success", func(t *testing.T) {
+		router, store := setupOwnerRouter(nil)
+		store.On("Transfer", mock.Anything, "cust-1", req).
+			Return(model.Transfer{ID: uuid.New(), OwnerID: "cust-1", FromWalletID: from, ToWalletID: to, Amount: 300, FromBalance: 700, ToBalance: 350}, nil)
+
+		w := post(router, body)
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp model.Transfer
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, int64(700), resp.FromBalance)
+		assert.Equal(t, int64(350), resp.ToBalance)
+	})
+
+	t.Run("same wallet", func(t *testing.T) {
+		router, store := setupOwnerRouter(nil)
+		w := post(router, fmt.Sprintf(`{"fromWalletId":%q,"toWalletId":%q,"amount":300}`, from, from))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		store.AssertNotCalled(t, "Transfer", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("destination not allowed", func(t *testing.T) {
+		router, store := setupOwnerRouter(&model.Principal{WalletIDs: []uuid.UUID{from}})
+		w := post(router, body)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		store.AssertNotCalled(t, "Transfer", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	for name, tc := range map[string]struct {
+		err  error
+		code int
+	}{
+		"insufficient funds": {repo.ErrInsufficientFunds, http.StatusBadRequest},
+		"frozen":             {repo.ErrWalletFrozen, http.StatusConflict},
+		"other owner":        {repo.ErrNotFound, http.StatusNotFound},
+	} {
+		t.Run(name, func(t *testing.T) {
+			router, store := setupOwnerRouter(nil)
+			store.On("Transfer", mock.Anything, "cust-1", req).Return(model.Transfer{}, tc.err)
+			assert.Equal(t, tc.code, post(router, body).Code)
+		})
+	}
+}
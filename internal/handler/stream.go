@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yokitheyo/go_wallet_test/internal/logctx"
+	"github.com/yokitheyo/go_wallet_test/internal/middleware"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"github.com/yokitheyo/go_wallet_test/internal/repo"
+	"github.com/yokitheyo/go_wallet_test/internal/stream"
+	"go.uber.org/zap"
+)
+
+const backfillPageSize = 500
+
+type ledgerReader interface {
+	GetBalance(ctx context.Context, walletID uuid.UUID) (int64, error)
+	LedgerSince(ctx context.Context, walletID uuid.UUID, afterID int64, limit int) ([]model.LedgerEntry, error)
+}
+
+type balanceUpdate struct {
+	WalletID    uuid.UUID           `json:"walletId"`
+	Balance     int64               `json:"balance"`
+	Transaction *transactionSummary `json:"transaction,omitempty"`
+}
+
+type transactionSummary struct {
+	ID            int64               `json:"id"`
+	OperationType model.OperationType `json:"operationType"`
+	Amount        int64               `json:"amount"`
+	CreatedAt     time.Time           `json:"createdAt"`
+}
+
+func newBalanceUpdate(e model.LedgerEntry) balanceUpdate {
+	return balanceUpdate{
+		WalletID: e.WalletID,
+		Balance:  e.BalanceAfter,
+		Transaction: &transactionSummary{
+			ID:            e.ID,
+			OperationType: e.OperationType,
+			Amount:        e.Amount,
+			CreatedAt:     e.CreatedAt,
+		},
+	}
+}
+
+func streamBalance(r ledgerReader, broker *stream.Broker, shutdown <-chan struct{}, heartbeat time.Duration, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := logctx.Logger(c.Request.Context(), logger)
+
+		idStr := c.Param("id")
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			log.Warn("invalid uuid in streamBalance", zap.String("id", idStr), zap.Error(err))
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid uuid"})
+			return
+		}
+		if !middleware.WalletAllowed(c, id) {
+			log.Warn("api key not allowed for wallet", zap.String("wallet_id", id.String()))
+			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+
+		var lastID int64
+		resume := c.GetHeader("Last-Event-ID")
+		if resume != "" {
+			lastID, err = strconv.ParseInt(resume, 10, 64)
+			if err != nil || lastID < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid Last-Event-ID"})
+				return
+			}
+		}
+
+		// Subscribe before reading history so nothing committed in between is lost.
+		sub := broker.Subscribe(id)
+		defer sub.Close()
+
+		// Replica lag could hide an update committed before Subscribe, which
+		// would then never reach the client.
+		ctx := repo.WithPrimary(c.Request.Context())
+		if resume == "" {
+			bal, err := r.GetBalance(ctx, id)
+			if middleware.AbortUnavailable(c, err) {
+				log.Warn("database unavailable on GetBalance", zap.Error(err))
+				return
+			}
+			if err != nil {
+				log.Error("internal error on GetBalance", zap.Error(err))
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error", "detail": err.Error()})
+				return
+			}
+			startStream(c)
+			c.Render(-1, sse.Event{Event: "balance", Data: balanceUpdate{WalletID: id, Balance: bal}})
+		} else {
+			startStream(c)
+			for {
+				entries, err := r.LedgerSince(ctx, id, lastID, backfillPageSize)
+				if err != nil {
+					log.Error("internal error on LedgerSince", zap.Error(err))
+					c.Render(-1, sse.Event{Event: "error", Data: gin.H{"error": "internal error"}})
+					return
+				}
+				for _, e := range entries {
+					sendEntry(c, e)
+					lastID = e.ID
+				}
+				if len(entries) < backfillPageSize {
+					break
+				}
+			}
+		}
+		c.Writer.Flush()
+
+		log.Debug("balance stream opened", zap.String("wallet_id", id.String()), zap.Int64("last_event_id", lastID))
+
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-shutdown:
+				c.Render(-1, sse.Event{Event: "shutdown", Data: gin.H{"reconnect": true}})
+				c.Writer.Flush()
+				return
+			case t := <-ticker.C:
+				c.Render(-1, sse.Event{Event: "heartbeat", Data: gin.H{"time": t.UTC().Format(time.RFC3339)}})
+				c.Writer.Flush()
+			case e, ok := <-sub.C:
+				if !ok {
+					return
+				}
+				if e.ID <= lastID {
+					continue
+				}
+				sendEntry(c, e)
+				lastID = e.ID
+				c.Writer.Flush()
+			}
+		}
+	}
+}
+
+func startStream(c *gin.Context) {
+	// Streams outlive the server's WriteTimeout, so lift the deadline for this response.
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+}
+
+func sendEntry(c *gin.Context, e model.LedgerEntry) {
+	c.Render(-1, sse.Event{
+		Id:    strconv.FormatInt(e.ID, 10),
+		Event: "balance",
+		Data:  newBalanceUpdate(e),
+	})
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"github.com/yokitheyo/go_wallet_test/internal/stream"
+	"go.uber.org/zap"
+)
+
+type MockLedgerReader struct {
+	mock.Mock
+}
+
+func (m *MockLedgerReader) GetBalance(ctx context.Context, walletID uuid.UUID) (int64, error) {
+	args := m.Called(ctx, walletID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockLedgerReader) LedgerSince(ctx context.Context, walletID uuid.UUID, afterID int64, limit int) ([]model.LedgerEntry, error) {
+	args := m.Called(ctx, walletID, afterID, limit)
+	return args.Get(0).([]model.LedgerEntry), args.Error(1)
+}
+
+func runStream(t *testing.T, reader ledgerReader, broker *stream.Broker, walletID uuid.UUID, lastEventID string, whileOpen func()) string {
+	gin.SetMode(gin.TestMode)
+	shutdown := make(chan struct{})
+	router := gin.New()
+	router.GET("/api/v1/wallets/:id/stream", streamBalance(reader, broker, shutdown, time.Hour, zap.NewNop()))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/wallets/"+walletID.String()+"/stream", nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		router.ServeHTTP(w, req)
+	}()
+
+	assert.Eventually(t, func() bool { return broker.Subscribers(walletID) == 1 }, time.Second, 5*time.Millisecond)
+	whileOpen()
+	close(shutdown)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("stream did not close on shutdown")
+	}
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	return w.Body.String()
+}
+
+func TestStreamBalance_SnapshotAndLiveUpdate(t *testing.T) {
+	walletID := uuid.New()
+	reader := &MockLedgerReader{}
+	reader.On("GetBalance", mock.Anything, walletID).Return(int64(100), nil)
+	broker := stream.NewBroker(8)
+
+	body := runStream(t, reader, broker, walletID, "", func() {
+		broker.Publish(model.LedgerEntry{ID: 5, WalletID: walletID, OperationType: model.Deposit, Amount: 20, BalanceAfter: 120})
+		time.Sleep(20 * time.Millisecond)
+	})
+
+	assert.Contains(t, body, `"balance":100`)
+	assert.Contains(t, body, "id:5\n")
+	assert.Contains(t, body, `"balance":120`)
+	assert.Contains(t, body, `"operationType":"DEPOSIT"`)
+	assert.Contains(t, body, "event:shutdown")
+	assert.Equal(t, 0, broker.Subscribers(walletID))
+}
+
+func TestStreamBalance_ResumeFromLastEventID(t *testing.T) {
+	walletID := uuid.New()
+	reader := &MockLedgerReader{}
+	reader.On("LedgerSince", mock.Anything, walletID, int64(3), backfillPageSize).Return([]model.LedgerEntry{
+		{ID: 4, WalletID: walletID, OperationType: model.Withdraw, Amount: 10, BalanceAfter: 90},
+	}, nil)
+	broker := stream.NewBroker(8)
+
+	body := runStream(t, reader, broker, walletID, "3", func() {
+		broker.Publish(model.LedgerEntry{ID: 4, WalletID: walletID, BalanceAfter: 90})
+		broker.Publish(model.LedgerEntry{ID: 6, WalletID: walletID, BalanceAfter: 70})
+		time.Sleep(20 * time.Millisecond)
+	})
+
+	assert.Equal(t, 1, strings.Count(body, "id:4\n"))
+	assert.Contains(t, body, "id:6\n")
+	reader.AssertNotCalled(t, "GetBalance", mock.Anything, walletID)
+}
+
+func TestStreamBalance_InvalidLastEventID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/wallets/:id/stream", streamBalance(&MockLedgerReader{}, stream.NewBroker(1), nil, time.Hour, zap.NewNop()))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/wallets/"+uuid.New().String()+"/stream", nil)
+	req.Header.Set("Last-Event-ID", "abc")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -1,36 +1,113 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"net/http"
-	"strings"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/yokitheyo/go_wallet_test/internal/config"
+	"github.com/yokitheyo/go_wallet_test/internal/logctx"
+	"github.com/yokitheyo/go_wallet_test/internal/metrics"
 	"github.com/yokitheyo/go_wallet_test/internal/middleware"
 	"github.com/yokitheyo/go_wallet_test/internal/model"
 	"github.com/yokitheyo/go_wallet_test/internal/repo"
+	"github.com/yokitheyo/go_wallet_test/internal/stream"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	"go.uber.org/zap"
 )
 
-func NewRouter(r *repo.Repo, logger *zap.Logger) (*gin.Engine, *middleware.GracefulShutdown) {
+type balancesGetter interface {
+	GetBalances(ctx context.Context, walletIDs []uuid.UUID) (map[uuid.UUID]model.WalletBalance, error)
+}
+
+func NewRouter(r *repo.Repo, broker *stream.Broker, tokens middleware.TokenVerifier, signatures *middleware.SignatureAuth, rateLimit *middleware.RateLimit, m *metrics.Metrics, settings *config.Watcher, logger *zap.Logger) (*gin.Engine, *middleware.GracefulShutdown) {
+	cfg := settings.Current()
 	router := gin.New()
+	trustProxies(router, cfg.HTTP.TrustedProxies, logger)
 	router.Use(gin.Recovery())
+	router.Use(otelgin.Middleware(cfg.Tracing.ServiceName, otelgin.WithFilter(func(req *http.Request) bool {
+		switch req.URL.Path {
+		case "/health", "/livez", "/readyz", "/metrics":
+			return false
+		}
+		return true
+	})))
+	router.Use(middleware.RequestID(logger))
 	router.Use(middleware.Logger(logger))
+	router.Use(m.Middleware())
+	router.Use(middleware.ReadConsistency())
 
 	gracefulShutdown := middleware.NewGracefulShutdown(logger)
+	// Probes are registered before the shutdown middleware so they keep
+	// answering with details while the server drains.
+	router.GET("/livez", livez())
+	router.GET("/readyz", readyz(r, gracefulShutdown.IsShuttingDown, newReadinessConfig(cfg), logger))
 	router.Use(gracefulShutdown.Middleware())
+	m.RegisterInFlight(gracefulShutdown.GetActiveRequests)
 
 	router.GET("/health", healthCheck(logger))
 
+	invalidateKeys := func() {}
 	v1 := router.Group("/api/v1")
+	if rateLimit != nil {
+		v1.Use(rateLimit.ByIP())
+	}
+	if cfg.Auth.Enabled {
+		if signatures != nil {
+			v1.Use(signatures.Middleware())
+		}
+		if tokens != nil {
+			v1.Use(middleware.JWTAuth(tokens, logger))
+		}
+		apiKeyAuth := middleware.NewAPIKeyAuth(r, cfg.Auth.CacheTTL, logger)
+		invalidateKeys = apiKeyAuth.Invalidate
+		v1.Use(apiKeyAuth.Middleware())
+	}
+	if rateLimit != nil {
+		v1.Use(rateLimit.ByClient())
+	}
+	// Groups copy the middleware chain when created, so this must follow v1.Use.
+	wallets := v1.Group("")
+	if rateLimit != nil {
+		wallets.Use(rateLimit.ByWallet())
+	}
+
+	read := middleware.RequireScope(model.ScopeWalletRead)
+	write := middleware.RequireScope(model.ScopeWalletWrite)
 	{
-		v1.POST("/wallet", depositWithdraw(r, logger))
-		v1.GET("/wallets/:id", getBalance(r, logger))
+		wallets.POST("/wallet", write, depositWithdraw(r, m, logger))
+		wallets.GET("/wallets/:id", read, getBalance(r, logger))
+		wallets.GET("/wallets/:id/stream", read, streamBalance(r, broker, gracefulShutdown.Done(), cfg.Stream.Heartbeat, logger))
+		wallets.GET("/wallets/:id/metadata", read, getWalletMetadata(r, logger))
+		wallets.PUT("/wallets/:id/metadata", write, setWalletMetadata(r, logger))
+		wallets.PATCH("/wallets/:id/metadata", write, patchWalletMetadata(r, logger))
+		v1.GET("/wallets", read, listWallets(r, logger))
+		v1.GET("/wallets/lookup", read, lookupWallet(r, logger))
+		v1.POST("/wallets/balances", read, getBalances(r, func() int { return settings.Current().Limits.MaxBatchSize }, logger))
+		v1.GET("/owners/:id/wallets", read, getOwnerWallets(r, logger))
+		v1.POST("/owners/:id/transfers", write, transferBetweenOwnWallets(r, m, logger))
+
+		admin := v1.Group("", middleware.RequireScope(model.ScopeAdmin))
+		registerWebhookRoutes(admin, r, logger)
+		registerAPIKeyRoutes(admin.Group("/admin"), r, invalidateKeys, logger)
 	}
 	return router, gracefulShutdown
 }
 
+// trustProxies makes c.ClientIP, and with it the per-IP rate limit, believe
+// forwarding headers only from the configured proxies. Gin trusts every
+// peer by default.
+func trustProxies(router *gin.Engine, proxies []string, logger *zap.Logger) {
+	if err := router.SetTrustedProxies(proxies); err != nil {
+		logger.Error("invalid trusted proxies, trusting none", zap.Strings("trusted_proxies", proxies), zap.Error(err))
+		router.SetTrustedProxies(nil)
+	}
+}
+
 func healthCheck(logger *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		logger.Debug("Health check request")
@@ -41,11 +118,13 @@ func healthCheck(logger *zap.Logger) gin.HandlerFunc {
 	}
 }
 
-func depositWithdraw(r *repo.Repo, logger *zap.Logger) gin.HandlerFunc {
+func depositWithdraw(r *repo.Repo, m *metrics.Metrics, logger *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		log := logctx.Logger(c.Request.Context(), logger)
+
 		var req model.WalletRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			logger.Error("invalid request payload", zap.Error(err))
+			log.Error("invalid request payload", zap.Error(err))
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error":  "invalid request payload",
 				"detail": err.Error(),
@@ -53,13 +132,29 @@ func depositWithdraw(r *repo.Repo, logger *zap.Logger) gin.HandlerFunc {
 			return
 		}
 
-		newBal, err := r.ChangeBalance(c.Request.Context(), req)
+		if !middleware.WalletAllowed(c, req.WalletID) {
+			log.Warn("api key not allowed for wallet", zap.String("wallet_id", req.WalletID.String()))
+			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+
+		bal, err := r.ChangeBalance(c.Request.Context(), req)
 		if err != nil {
-			if strings.Contains(strings.ToLower(err.Error()), "insufficient") {
-				logger.Warn("insufficient funds", zap.Any("request", req), zap.Error(err))
+			if errors.Is(err, repo.ErrUnavailable) {
+				m.ObserveOperation(req.OperationType, metrics.OutcomeUnavailable, req.Amount)
+				log.Warn("database unavailable on ChangeBalance", zap.Error(err))
+				middleware.AbortUnavailable(c, err)
+			} else if errors.Is(err, repo.ErrWalletFrozen) {
+				m.ObserveOperation(req.OperationType, metrics.OutcomeFrozen, req.Amount)
+				log.Warn("wallet is frozen", zap.String("wallet_id", req.WalletID.String()))
+				c.JSON(http.StatusConflict, gin.H{"error": "wallet is frozen"})
+			} else if errors.Is(err, repo.ErrInsufficientFunds) {
+				m.ObserveOperation(req.OperationType, metrics.OutcomeInsufficient, req.Amount)
+				log.Warn("insufficient funds", append(operationFields(req), zap.Error(err))...)
 				c.JSON(http.StatusBadRequest, gin.H{"error": "insufficient funds"})
 			} else {
-				logger.Error("internal error on ChangeBalance", zap.Error(err))
+				m.ObserveOperation(req.OperationType, metrics.OutcomeError, req.Amount)
+				log.Error("internal error on ChangeBalance", zap.Error(err))
 				c.JSON(http.StatusInternalServerError, gin.H{
 					"error":  "internal error",
 					"detail": err.Error(),
@@ -68,27 +163,113 @@ func depositWithdraw(r *repo.Repo, logger *zap.Logger) gin.HandlerFunc {
 			return
 		}
 
-		logger.Info("balance changed successfully", zap.Any("request", req), zap.Int64("new_balance", newBal))
-		c.JSON(http.StatusOK, gin.H{"balance": newBal})
+		m.ObserveOperation(req.OperationType, metrics.OutcomeOK, req.Amount)
+		// Debug: this runs on every successful operation.
+		log.Debug("balance changed successfully", append(operationFields(req), zap.Int64("new_balance", bal.Balance))...)
+		c.JSON(http.StatusOK, bal)
+	}
+}
+
+// operationFields describes a balance operation with typed fields, which
+// encode without reflection.
+func operationFields(req model.WalletRequest) []zap.Field {
+	return []zap.Field{
+		zap.String("wallet_id", req.WalletID.String()),
+		zap.String("operation_type", string(req.OperationType)),
+		zap.Int64("amount", req.Amount),
 	}
 }
 
 func getBalance(r *repo.Repo, logger *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		log := logctx.Logger(c.Request.Context(), logger)
+
 		idStr := c.Param("id")
 		id, err := uuid.Parse(idStr)
 		if err != nil {
-			logger.Warn("invalid uuid in getBalance", zap.String("id", idStr), zap.Error(err))
+			log.Warn("invalid uuid in getBalance", zap.String("id", idStr), zap.Error(err))
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid uuid"})
 			return
 		}
-		bal, err := r.GetBalance(id)
+		if !middleware.WalletAllowed(c, id) {
+			log.Warn("api key not allowed for wallet", zap.String("wallet_id", id.String()))
+			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+		bal, err := r.GetWalletBalance(c.Request.Context(), id)
+		if middleware.AbortUnavailable(c, err) {
+			log.Warn("database unavailable on GetWalletBalance", zap.Error(err))
+			return
+		}
 		if err != nil {
-			logger.Error("internal error on GetBalance", zap.Error(err))
+			log.Error("internal error on GetWalletBalance", zap.Error(err))
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error", "detail": err.Error()})
 			return
 		}
-		logger.Info("balance retrieved", zap.String("wallet_id", id.String()), zap.Int64("balance", bal))
-		c.JSON(http.StatusOK, gin.H{"balance": bal})
+		log.Debug("balance retrieved", zap.String("wallet_id", id.String()), zap.Int64("balance", bal.Balance))
+		c.JSON(http.StatusOK, bal)
+	}
+}
+
+func getBalances(r balancesGetter, maxBatchSize func() int, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := logctx.Logger(c.Request.Context(), logger)
+
+		var req model.BalancesRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			log.Warn("invalid request payload in getBalances", zap.Error(err))
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":  "invalid request payload",
+				"detail": err.Error(),
+			})
+			return
+		}
+
+		ids := make([]uuid.UUID, 0, len(req.WalletIDs))
+		seen := make(map[uuid.UUID]struct{}, len(req.WalletIDs))
+		for _, id := range req.WalletIDs {
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			if !middleware.WalletAllowed(c, id) {
+				log.Warn("api key not allowed for wallet", zap.String("wallet_id", id.String()))
+				c.JSON(http.StatusForbidden, gin.H{"error": "forbidden", "detail": "wallet " + id.String() + " is not allowed"})
+				return
+			}
+			seen[id] = struct{}{}
+			ids = append(ids, id)
+		}
+
+		if limit := maxBatchSize(); limit > 0 && len(ids) > limit {
+			log.Warn("batch size exceeded in getBalances", zap.Int("size", len(ids)), zap.Int("max", limit))
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":  "too many wallet ids",
+				"detail": "maximum batch size is " + strconv.Itoa(limit),
+			})
+			return
+		}
+
+		found, err := r.GetBalances(c.Request.Context(), ids)
+		if middleware.AbortUnavailable(c, err) {
+			log.Warn("database unavailable on GetBalances", zap.Error(err))
+			return
+		}
+		if err != nil {
+			log.Error("internal error on GetBalances", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error", "detail": err.Error()})
+			return
+		}
+
+		resp := model.BalancesResponse{Balances: make(map[uuid.UUID]*model.WalletBalance, len(ids))}
+		for _, id := range ids {
+			if bal, ok := found[id]; ok {
+				resp.Balances[id] = &bal
+			} else {
+				resp.Balances[id] = nil
+			}
+		}
+
+		log.Info("balances retrieved", zap.Int("requested", len(ids)), zap.Int("found", len(found)))
+		c.JSON(http.StatusOK, resp)
 	}
 }
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yokitheyo/go_wallet_test/internal/logctx"
+	"github.com/yokitheyo/go_wallet_test/internal/middleware"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"go.uber.org/zap"
+)
+
+type walletLister interface {
+	ListWallets(ctx context.Context, f model.WalletFilter) ([]model.Wallet, error)
+}
+
+const defaultWalletPageSize = 50
+
+func listWallets(s walletLister, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := logctx.Logger(c.Request.Context(), logger)
+
+		f, err := walletFilter(c)
+		if err != nil {
+			log.Warn("invalid query in listWallets", zap.Error(err))
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query", "detail": err.Error()})
+			return
+		}
+		// A caller limited to some wallets only ever sees those.
+		if p, ok := middleware.PrincipalFrom(c); ok && !p.AllWallets {
+			f.WalletIDs = p.WalletIDs
+			if f.WalletIDs == nil {
+				c.JSON(http.StatusOK, model.ListWalletsResponse{Wallets: []model.Wallet{}})
+				return
+			}
+		}
+
+		limit := f.Limit
+		f.Limit++
+		wallets, err := s.ListWallets(c.Request.Context(), f)
+		if err != nil {
+			respondWalletError(c, log, "ListWallets", err)
+			return
+		}
+
+		resp := model.ListWalletsResponse{Wallets: wallets}
+		if len(wallets) > limit {
+			resp.Wallets = wallets[:limit]
+			resp.NextCursor = model.NewWalletCursor(wallets[limit-1], f.Sort, f.Desc).Encode()
+		}
+		log.Info("wallets listed", zap.Int("count", len(resp.Wallets)), zap.Bool("more", resp.NextCursor != ""))
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+func walletFilter(c *gin.Context) (model.WalletFilter, error) {
+	var q model.ListWalletsQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		return model.WalletFilter{}, err
+	}
+	if q.Currency != "" {
+		return model.WalletFilter{}, fmt.Errorf("wallets have no currency, filtering by currency is not supported")
+	}
+	if q.MinBalance != nil && q.MaxBalance != nil && *q.MinBalance > *q.MaxBalance {
+		return model.WalletFilter{}, fmt.Errorf("minBalance must not exceed maxBalance")
+	}
+
+	f := model.WalletFilter{
+		Status:      q.Status,
+		OwnerID:     q.OwnerID,
+		MinBalance:  q.MinBalance,
+		MaxBalance:  q.MaxBalance,
+		CreatedFrom: q.CreatedFrom,
+		CreatedTo:   q.CreatedTo,
+		Sort:        q.Sort,
+		Desc:        q.Order != "asc",
+		Limit:       q.Limit,
+	}
+	if f.Sort == "" {
+		f.Sort = model.WalletSortCreatedAt
+	}
+	if f.Limit == 0 {
+		f.Limit = defaultWalletPageSize
+	}
+	for _, raw := range q.Labels {
+		sel, err := model.ParseLabelSelector(raw)
+		if err != nil {
+			return f, err
+		}
+		f.Labels = append(f.Labels, sel)
+	}
+	if q.Cursor != "" {
+		cursor, err := model.DecodeWalletCursor(q.Cursor)
+		if err != nil {
+			return f, err
+		}
+		if cursor.Sort != f.Sort || cursor.Desc != f.Desc {
+			return f, fmt.Errorf("cursor was issued for a different sort order")
+		}
+		f.After = &cursor
+	}
+	return f, nil
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/yokitheyo/go_wallet_test/internal/middleware"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"go.uber.org/zap"
+)
+
+type MockWalletLister struct {
+	mock.Mock
+}
+
+func (m *MockWalletLister) ListWallets(ctx context.Context, f model.WalletFilter) ([]model.Wallet, error) {
+	args := m.Called(ctx, f)
+	return args.Get(0).([]model.Wallet), args.Error(1)
+}
+
+func setupListRouter(principal *model.Principal) (*gin.Engine, *MockWalletLister) {
+	gin.SetMode(gin.TestMode)
+	store := &MockWalletLister{}
+	router := gin.New()
+	if principal != nil {
+		router.Use(func(c *gin.Context) { middleware.SetPrincipal(c, *principal) })
+	}
+	router.GET("/api/v1/wallets", listWallets(store, zap.NewNop()))
+	return router, store
+}
+
+func getList(router *gin.Engine, query string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/wallets"+query, nil))
+	return w
+}
+
+func TestListWallets_Pagination(t *testing.T) {
+	router, store := setupListRouter(nil)
+
+	now := time.Now().UTC()
+	page := []model.Wallet{
+		{ID: uuid.New(), Balance: 300, CreatedAt: now},
+		{ID: uuid.New(), Balance: 200, CreatedAt: now},
+		{ID: uuid.New(), Balance: 100, CreatedAt: now},
+	}
+	store.On("ListWallets", mock.Anything, mock.MatchedBy(func(f model.WalletFilter) bool {
+		return f.Sort == model.WalletSortBalance && f.Desc && f.Limit == 3 && f.After == nil &&
+			len(f.Labels) == 1 && f.Labels[0].Key == "tier" && *f.Labels[0].Value == "gold"
+	})).Return(page, nil)
+
+	w := getList(router, "?sort=balance&limit=2&label=tier=gold")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp model.ListWalletsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp.Wallets, 2)
+	require.NotEmpty(t, resp.NextCursor)
+
+	cursor, err := model.DecodeWalletCursor(resp.NextCursor)
+	require.NoError(t, err)
+	assert.Equal(t, page[1].ID, cursor.ID)
+	assert.Equal(t, int64(200), cursor.Balance)
+
+	store.On("ListWallets", mock.Anything, mock.MatchedBy(func(f model.WalletFilter) bool {
+		return f.After != nil && f.After.ID == page[1].ID
+	})).Return(page[2:], nil)
+
+	w = getList(router, "?sort=balance&limit=2&label=tier=gold&cursor="+resp.NextCursor)
+	require.Equal(t, http.StatusOK, w.Code)
+	resp = model.ListWalletsResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp.Wallets, 1)
+	assert.Empty(t, resp.NextCursor)
+
+	assert.Equal(t, http.StatusBadRequest, getList(router, "?sort=createdAt&cursor="+cursor.Encode()).Code,
+		"a cursor only works for the order it was issued for")
+}
+
+func TestListWallets_InvalidQuery(t *testing.T) {
+	router, store := setupListRouter(nil)
+
+	for _, query := range []string{
+		"?status=deleted",
+		"?sort=name",
+		"?limit=1000",
+		"?minBalance=10&maxBalance=5",
+		"?createdFrom=yesterday",
+		"?label=bad%20key",
+		"?currency=USD",
+		"?cursor=garbage",
+	} {
+		assert.Equal(t, http.StatusBadRequest, getList(router, query).Code, query)
+	}
+	store.AssertNotCalled(t, "ListWallets", mock.Anything, mock.Anything)
+}
+
+func TestListWallets_RestrictedPrincipal(t *testing.T) {
+	allowed := uuid.New()
+	router, store := setupListRouter(&model.Principal{Scopes: []model.Scope{model.ScopeWalletRead}, WalletIDs: []uuid.UUID{allowed}})
+
+	store.On("ListWallets", mock.Anything, mock.MatchedBy(func(f model.WalletFilter) bool {
+		return len(f.WalletIDs) == 1 && f.WalletIDs[0] == allowed
+	})).Return([]model.Wallet{{ID: allowed}}, nil)
+
+	w := getList(router, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	store.AssertExpectations(t)
+
+	router, store = setupListRouter(&model.Principal{Scopes: []model.Scope{model.ScopeWalletRead}})
+	w = getList(router, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"wallets":[]}`, w.Body.String())
+	store.AssertNotCalled(t, "ListWallets", mock.Anything, mock.Anything)
+}
@@ -5,23 +5,28 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
-	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/yokitheyo/go_wallet_test/internal/middleware"
 	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"github.com/yokitheyo/go_wallet_test/internal/ratelimit"
+	"github.com/yokitheyo/go_wallet_test/internal/repo"
 	"go.uber.org/zap"
 )
 
 type Repository interface {
 	ChangeBalance(ctx context.Context, req model.WalletRequest) (int64, error)
-	GetBalance(walletID uuid.UUID) (int64, error)
+	GetBalance(ctx context.Context, walletID uuid.UUID) (int64, error)
+	GetBalances(ctx context.Context, walletIDs []uuid.UUID) (map[uuid.UUID]model.WalletBalance, error)
 	Close() error
 	DB() *sql.DB
 }
@@ -43,11 +48,19 @@ func (m *MockRepo) ChangeBalance(ctx context.Context, req model.WalletRequest) (
 	return args.Get(0).(int64), args.Error(1)
 }
 
-func (m *MockRepo) GetBalance(walletID uuid.UUID) (int64, error) {
-	args := m.Called(walletID)
+func (m *MockRepo) GetBalance(ctx context.Context, walletID uuid.UUID) (int64, error) {
+	args := m.Called(ctx, walletID)
 	return args.Get(0).(int64), args.Error(1)
 }
 
+func (m *MockRepo) GetBalances(ctx context.Context, walletIDs []uuid.UUID) (map[uuid.UUID]model.WalletBalance, error) {
+	args := m.Called(ctx, walletIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[uuid.UUID]model.WalletBalance), args.Error(1)
+}
+
 func (m *MockRepo) Close() error {
 	args := m.Called()
 	return args.Error(0)
@@ -85,7 +98,7 @@ func (m *MockLogger) Fatal(msg string, fields ...zap.Field) {
 var _ Repository = (*MockRepo)(nil)
 var _ Logger = (*MockLogger)(nil)
 
-func newTestRouter(repo Repository, logger Logger) *gin.Engine {
+func newTestRouter(store Repository, logger Logger) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
 	router.Use(gin.Recovery())
@@ -111,9 +124,9 @@ func newTestRouter(repo Repository, logger Logger) *gin.Engine {
 				return
 			}
 
-			newBal, err := repo.ChangeBalance(c.Request.Context(), req)
+			newBal, err := store.ChangeBalance(c.Request.Context(), req)
 			if err != nil {
-				if strings.Contains(strings.ToLower(err.Error()), "insufficient") {
+				if errors.Is(err, repo.ErrInsufficientFunds) {
 					logger.Warn("insufficient funds", zap.Any("request", req), zap.Error(err))
 					c.JSON(http.StatusBadRequest, gin.H{"error": "insufficient funds"})
 				} else {
@@ -138,7 +151,7 @@ func newTestRouter(repo Repository, logger Logger) *gin.Engine {
 				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid uuid"})
 				return
 			}
-			bal, err := repo.GetBalance(id)
+			bal, err := store.GetBalance(c.Request.Context(), id)
 			if err != nil {
 				logger.Error("internal error on GetBalance", zap.Error(err))
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error", "detail": err.Error()})
@@ -221,7 +234,7 @@ func TestDepositWithdraw_InsufficientFunds(t *testing.T) {
 		Amount:        1000,
 	}
 
-	mockRepo.On("ChangeBalance", mock.Anything, req).Return(int64(0), fmt.Errorf("insufficient balance"))
+	mockRepo.On("ChangeBalance", mock.Anything, req).Return(int64(0), repo.ErrInsufficientFunds)
 	mockLogger.On("Warn", "insufficient funds", mock.Anything).Return()
 
 	body, _ := json.Marshal(req)
@@ -263,7 +276,7 @@ func TestGetBalance_Success(t *testing.T) {
 	walletID := uuid.New()
 	expectedBalance := int64(1000)
 
-	mockRepo.On("GetBalance", walletID).Return(expectedBalance, nil)
+	mockRepo.On("GetBalance", mock.Anything, walletID).Return(expectedBalance, nil)
 	mockLogger.On("Info", "balance retrieved", mock.Anything).Return()
 
 	w := httptest.NewRecorder()
@@ -300,3 +313,113 @@ func TestGetBalance_InvalidUUID(t *testing.T) {
 
 	mockLogger.AssertExpectations(t)
 }
+
+func setupBalancesRouter(maxBatchSize int) (*gin.Engine, *MockRepo) {
+	gin.SetMode(gin.TestMode)
+	mockRepo := &MockRepo{}
+	router := gin.New()
+	router.POST("/api/v1/wallets/balances", getBalances(mockRepo, func() int { return maxBatchSize }, zap.NewNop()))
+	return router, mockRepo
+}
+
+func TestGetBalances_Success(t *testing.T) {
+	router, mockRepo := setupBalancesRouter(10)
+
+	known := uuid.New()
+	unknown := uuid.New()
+
+	mockRepo.On("GetBalances", mock.Anything, []uuid.UUID{known, unknown}).
+		Return(map[uuid.UUID]model.WalletBalance{known: model.NewWalletBalance(-250, -1000)}, nil)
+
+	body, _ := json.Marshal(model.BalancesRequest{WalletIDs: []uuid.UUID{known, unknown, known}})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/wallets/balances", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Balances map[string]*model.WalletBalance `json:"balances"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Len(t, response.Balances, 2)
+	if assert.NotNil(t, response.Balances[known.String()]) {
+		assert.Equal(t, model.WalletBalance{Balance: -250, MinBalance: -1000, CreditUsed: 250, CreditAvailable: 750},
+			*response.Balances[known.String()])
+	}
+	assert.Contains(t, response.Balances, unknown.String())
+	assert.Nil(t, response.Balances[unknown.String()])
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetBalances_BatchTooLarge(t *testing.T) {
+	router, mockRepo := setupBalancesRouter(1)
+
+	body, _ := json.Marshal(model.BalancesRequest{WalletIDs: []uuid.UUID{uuid.New(), uuid.New()}})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/wallets/balances", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockRepo.AssertNotCalled(t, "GetBalances", mock.Anything, mock.Anything)
+}
+
+func TestGetBalances_EmptyList(t *testing.T) {
+	router, _ := setupBalancesRouter(10)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/wallets/balances", bytes.NewBufferString(`{"walletIds":[]}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetBalances_DatabaseUnavailable(t *testing.T) {
+	router, mockRepo := setupBalancesRouter(10)
+
+	id := uuid.New()
+	mockRepo.On("GetBalances", mock.Anything, []uuid.UUID{id}).
+		Return(nil, &repo.UnavailableError{RetryAfter: 1500 * time.Millisecond})
+
+	body, _ := json.Marshal(model.BalancesRequest{WalletIDs: []uuid.UUID{id}})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/wallets/balances", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTrustProxies_SpoofedForwardedForKeepsIPBucket(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	trustProxies(router, []string{"10.0.0.0/8"}, zap.NewNop())
+	l := middleware.NewRateLimit(ratelimit.NewMemoryLimiter(), middleware.RateLimits{
+		IP: ratelimit.Limit{Rate: 0.001, Burst: 2},
+	}, zap.NewNop())
+	router.GET("/ping", l.ByIP(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	do := func(peer, forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		req.RemoteAddr = peer + ":40000"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for i := range 2 {
+		assert.Equal(t, http.StatusOK, do("203.0.113.7", fmt.Sprintf("198.51.100.%d", i)))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, do("203.0.113.7", "198.51.100.99"),
+		"an untrusted peer cannot pick its own bucket")
+
+	assert.Equal(t, http.StatusOK, do("10.1.2.3", "198.51.100.1"), "a trusted proxy forwards the client IP")
+}
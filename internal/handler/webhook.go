@@ -0,0 +1,253 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yokitheyo/go_wallet_test/internal/logctx"
+	"github.com/yokitheyo/go_wallet_test/internal/middleware"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"github.com/yokitheyo/go_wallet_test/internal/repo"
+	"github.com/yokitheyo/go_wallet_test/internal/webhook"
+	"go.uber.org/zap"
+)
+
+type webhookStore interface {
+	CreateWebhook(ctx context.Context, sub model.WebhookSubscription) (model.WebhookSubscription, error)
+	ListWebhooks(ctx context.Context) ([]model.WebhookSubscription, error)
+	GetWebhook(ctx context.Context, id uuid.UUID) (model.WebhookSubscription, error)
+	UpdateWebhook(ctx context.Context, id uuid.UUID, req model.UpdateWebhookRequest) (model.WebhookSubscription, error)
+	DeleteWebhook(ctx context.Context, id uuid.UUID) error
+	ListWebhookDeliveries(ctx context.Context, subscriptionID uuid.UUID, limit int) ([]model.WebhookDelivery, error)
+	RedeliverWebhook(ctx context.Context, subscriptionID uuid.UUID, deliveryID int64) (model.WebhookDelivery, error)
+}
+
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 500
+)
+
+func registerWebhookRoutes(g *gin.RouterGroup, s webhookStore, logger *zap.Logger) {
+	g.POST("/webhooks", createWebhook(s, logger))
+	g.GET("/webhooks", listWebhooks(s, logger))
+	g.GET("/webhooks/:id", getWebhook(s, logger))
+	g.PATCH("/webhooks/:id", updateWebhook(s, logger))
+	g.DELETE("/webhooks/:id", deleteWebhook(s, logger))
+	g.GET("/webhooks/:id/deliveries", listWebhookDeliveries(s, logger))
+	g.POST("/webhooks/:id/deliveries/:deliveryId/redeliver", redeliverWebhook(s, logger))
+}
+
+func parseWebhookID(c *gin.Context, logger *zap.Logger) (uuid.UUID, bool) {
+	idStr := c.Param("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		logger.Warn("invalid webhook id", zap.String("id", idStr), zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid uuid"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func respondWebhookError(c *gin.Context, logger *zap.Logger, op string, err error) {
+	if middleware.AbortUnavailable(c, err) {
+		logger.Warn("database unavailable on "+op, zap.Error(err))
+		return
+	}
+	if errors.Is(err, repo.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	if errors.Is(err, repo.ErrConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "conflict", "detail": err.Error()})
+		return
+	}
+	logger.Error("internal error on "+op, zap.Error(err))
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error", "detail": err.Error()})
+}
+
+func createWebhook(s webhookStore, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := logctx.Logger(c.Request.Context(), logger)
+
+		var req model.CreateWebhookRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			log.Warn("invalid request payload in createWebhook", zap.Error(err))
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":  "invalid request payload",
+				"detail": err.Error(),
+			})
+			return
+		}
+
+		secret := req.Secret
+		if secret == "" {
+			var err error
+			if secret, err = webhook.GenerateSecret(); err != nil {
+				respondWebhookError(c, log, "CreateWebhook", err)
+				return
+			}
+		}
+
+		sub, err := s.CreateWebhook(c.Request.Context(), model.WebhookSubscription{
+			ID:         uuid.New(),
+			URL:        req.URL,
+			EventTypes: req.EventTypes,
+			WalletIDs:  req.WalletIDs,
+			Secret:     secret,
+		})
+		if err != nil {
+			respondWebhookError(c, log, "CreateWebhook", err)
+			return
+		}
+
+		log.Info("webhook created", zap.String("webhook_id", sub.ID.String()), zap.String("url", sub.URL))
+		c.JSON(http.StatusCreated, sub)
+	}
+}
+
+func listWebhooks(s webhookStore, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := logctx.Logger(c.Request.Context(), logger)
+
+		subs, err := s.ListWebhooks(c.Request.Context())
+		if err != nil {
+			respondWebhookError(c, log, "ListWebhooks", err)
+			return
+		}
+		for i := range subs {
+			subs[i].Secret = ""
+		}
+		c.JSON(http.StatusOK, gin.H{"webhooks": subs})
+	}
+}
+
+func getWebhook(s webhookStore, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := logctx.Logger(c.Request.Context(), logger)
+
+		id, ok := parseWebhookID(c, log)
+		if !ok {
+			return
+		}
+		sub, err := s.GetWebhook(c.Request.Context(), id)
+		if err != nil {
+			respondWebhookError(c, log, "GetWebhook", err)
+			return
+		}
+		sub.Secret = ""
+		c.JSON(http.StatusOK, sub)
+	}
+}
+
+func updateWebhook(s webhookStore, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := logctx.Logger(c.Request.Context(), logger)
+
+		id, ok := parseWebhookID(c, log)
+		if !ok {
+			return
+		}
+
+		var req model.UpdateWebhookRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			log.Warn("invalid request payload in updateWebhook", zap.Error(err))
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":  "invalid request payload",
+				"detail": err.Error(),
+			})
+			return
+		}
+
+		sub, err := s.UpdateWebhook(c.Request.Context(), id, req)
+		if err != nil {
+			respondWebhookError(c, log, "UpdateWebhook", err)
+			return
+		}
+
+		log.Info("webhook updated", zap.String("webhook_id", id.String()), zap.Bool("enabled", sub.Enabled))
+		sub.Secret = ""
+		c.JSON(http.StatusOK, sub)
+	}
+}
+
+func deleteWebhook(s webhookStore, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := logctx.Logger(c.Request.Context(), logger)
+
+		id, ok := parseWebhookID(c, log)
+		if !ok {
+			return
+		}
+		if err := s.DeleteWebhook(c.Request.Context(), id); err != nil {
+			respondWebhookError(c, log, "DeleteWebhook", err)
+			return
+		}
+		log.Info("webhook deleted", zap.String("webhook_id", id.String()))
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func listWebhookDeliveries(s webhookStore, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := logctx.Logger(c.Request.Context(), logger)
+
+		id, ok := parseWebhookID(c, log)
+		if !ok {
+			return
+		}
+
+		limit := defaultDeliveryLimit
+		if l := c.Query("limit"); l != "" {
+			n, err := strconv.Atoi(l)
+			if err != nil || n <= 0 || n > maxDeliveryLimit {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":  "invalid limit",
+					"detail": "limit must be between 1 and " + strconv.Itoa(maxDeliveryLimit),
+				})
+				return
+			}
+			limit = n
+		}
+
+		if _, err := s.GetWebhook(c.Request.Context(), id); err != nil {
+			respondWebhookError(c, log, "GetWebhook", err)
+			return
+		}
+
+		deliveries, err := s.ListWebhookDeliveries(c.Request.Context(), id, limit)
+		if err != nil {
+			respondWebhookError(c, log, "ListWebhookDeliveries", err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"deliveries": deliveries})
+	}
+}
+
+func redeliverWebhook(s webhookStore, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := logctx.Logger(c.Request.Context(), logger)
+
+		id, ok := parseWebhookID(c, log)
+		if !ok {
+			return
+		}
+		deliveryID, err := strconv.ParseInt(c.Param("deliveryId"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid delivery id"})
+			return
+		}
+
+		d, err := s.RedeliverWebhook(c.Request.Context(), id, deliveryID)
+		if err != nil {
+			respondWebhookError(c, log, "RedeliverWebhook", err)
+			return
+		}
+
+		log.Info("webhook redelivery scheduled", zap.String("webhook_id", id.String()), zap.Int64("delivery_id", deliveryID))
+		c.JSON(http.StatusAccepted, d)
+	}
+}
@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"github.com/yokitheyo/go_wallet_test/internal/repo"
+	"go.uber.org/zap"
+)
+
+type MockWebhookStore struct {
+	mock.Mock
+}
+
+func (m *MockWebhookStore) CreateWebhook(ctx context.Context, sub model.WebhookSubscription) (model.WebhookSubscription, error) {
+	args := m.Called(ctx, sub)
+	return args.Get(0).(model.WebhookSubscription), args.Error(1)
+}
+
+func (m *MockWebhookStore) ListWebhooks(ctx context.Context) ([]model.WebhookSubscription, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]model.WebhookSubscription), args.Error(1)
+}
+
+func (m *MockWebhookStore) GetWebhook(ctx context.Context, id uuid.UUID) (model.WebhookSubscription, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(model.WebhookSubscription), args.Error(1)
+}
+
+func (m *MockWebhookStore) UpdateWebhook(ctx context.Context, id uuid.UUID, req model.UpdateWebhookRequest) (model.WebhookSubscription, error) {
+	args := m.Called(ctx, id, req)
+	return args.Get(0).(model.WebhookSubscription), args.Error(1)
+}
+
+func (m *MockWebhookStore) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	return m.Called(ctx, id).Error(0)
+}
+
+func (m *MockWebhookStore) ListWebhookDeliveries(ctx context.Context, subscriptionID uuid.UUID, limit int) ([]model.WebhookDelivery, error) {
+	args := m.Called(ctx, subscriptionID, limit)
+	return args.Get(0).([]model.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockWebhookStore) RedeliverWebhook(ctx context.Context, subscriptionID uuid.UUID, deliveryID int64) (model.WebhookDelivery, error) {
+	args := m.Called(ctx, subscriptionID, deliveryID)
+	return args.Get(0).(model.WebhookDelivery), args.Error(1)
+}
+
+var _ webhookStore = (*MockWebhookStore)(nil)
+
+func setupWebhookRouter() (*gin.Engine, *MockWebhookStore) {
+	gin.SetMode(gin.TestMode)
+	store := &MockWebhookStore{}
+	router := gin.New()
+	registerWebhookRoutes(router.Group("/api/v1"), store, zap.NewNop())
+	return router, store
+}
+
+func TestCreateWebhook_GeneratesSecret(t *testing.T) {
+	router, store := setupWebhookRouter()
+
+	store.On("CreateWebhook", mock.Anything, mock.MatchedBy(func(sub model.WebhookSubscription) bool {
+		return sub.URL == "https://partner.example/hook" && strings.HasPrefix(sub.Secret, "whsec_")
+	})).Return(model.WebhookSubscription{ID: uuid.New(), URL: "https://partner.example/hook", Secret: "whsec_x", Enabled: true}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/webhooks", bytes.NewBufferString(`{"url":"https://partner.example/hook","eventTypes":["BalanceChanged"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "whsec_x", response["secret"])
+	store.AssertExpectations(t)
+}
+
+func TestCreateWebhook_InvalidURL(t *testing.T) {
+	router, store := setupWebhookRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/webhooks", bytes.NewBufferString(`{"url":"not a url"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	store.AssertNotCalled(t, "CreateWebhook", mock.Anything, mock.Anything)
+}
+
+func TestListWebhooks_HidesSecrets(t *testing.T) {
+	router, store := setupWebhookRouter()
+
+	store.On("ListWebhooks", mock.Anything).Return([]model.WebhookSubscription{{ID: uuid.New(), Secret: "whsec_hidden"}}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/webhooks", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "whsec_hidden")
+}
+
+func TestGetWebhook_NotFound(t *testing.T) {
+	router, store := setupWebhookRouter()
+
+	id := uuid.New()
+	store.On("GetWebhook", mock.Anything, id).Return(model.WebhookSubscription{}, repo.ErrNotFound)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/webhooks/"+id.String(), nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestUpdateWebhook_Disable(t *testing.T) {
+	router, store := setupWebhookRouter()
+
+	id := uuid.New()
+	disabled := false
+	store.On("UpdateWebhook", mock.Anything, id, model.UpdateWebhookRequest{Enabled: &disabled}).
+		Return(model.WebhookSubscription{ID: id, Enabled: false}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", "/api/v1/webhooks/"+id.String(), bytes.NewBufferString(`{"enabled":false}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	store.AssertExpectations(t)
+}
+
+func TestDeleteWebhook(t *testing.T) {
+	router, store := setupWebhookRouter()
+
+	id := uuid.New()
+	store.On("DeleteWebhook", mock.Anything, id).Return(nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/api/v1/webhooks/"+id.String(), nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	store.AssertExpectations(t)
+}
+
+func TestListWebhookDeliveries(t *testing.T) {
+	router, store := setupWebhookRouter()
+
+	id := uuid.New()
+	store.On("GetWebhook", mock.Anything, id).Return(model.WebhookSubscription{ID: id}, nil)
+	store.On("ListWebhookDeliveries", mock.Anything, id, 10).
+		Return([]model.WebhookDelivery{{ID: 3, SubscriptionID: id, Status: model.DeliveryFailed}}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/webhooks/"+id.String()+"/deliveries?limit=10", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"failed"`)
+	store.AssertExpectations(t)
+}
+
+func TestRedeliverWebhook(t *testing.T) {
+	router, store := setupWebhookRouter()
+
+	id := uuid.New()
+	store.On("RedeliverWebhook", mock.Anything, id, int64(3)).
+		Return(model.WebhookDelivery{ID: 3, SubscriptionID: id, Status: model.DeliveryPending}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/webhooks/"+id.String()+"/deliveries/3/redeliver", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	store.AssertExpectations(t)
+}
+
+func TestRedeliverWebhook_DisabledSubscription(t *testing.T) {
+	router, store := setupWebhookRouter()
+
+	id := uuid.New()
+	store.On("RedeliverWebhook", mock.Anything, id, int64(3)).
+		Return(model.WebhookDelivery{}, fmt.Errorf("%w: subscription is disabled", repo.ErrConflict))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/webhooks/"+id.String()+"/deliveries/3/redeliver", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "subscription is disabled")
+	store.AssertExpectations(t)
+}
+
+func TestListWebhooks_DatabaseUnavailable(t *testing.T) {
+	router, store := setupWebhookRouter()
+	store.On("ListWebhooks", mock.Anything).
+		Return([]model.WebhookSubscription(nil), &repo.UnavailableError{RetryAfter: 5 * time.Second})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/webhooks", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "5", w.Header().Get("Retry-After"))
+}
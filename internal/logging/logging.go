@@ -0,0 +1,69 @@
+// Package logging builds the service logger from configuration.
+package logging
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type Config struct {
+	Level       string
+	Encoding    string // json | console
+	OutputPaths []string
+	// Sampling keeps the first SamplingInitial entries with the same level and
+	// message every second, then every SamplingThereafter-th. Zero disables it.
+	SamplingInitial    int
+	SamplingThereafter int
+}
+
+// New returns a logger whose level is controlled by the returned AtomicLevel,
+// so it can be changed while the service runs.
+func New(cfg Config) (*zap.Logger, zap.AtomicLevel, error) {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, zap.AtomicLevel{}, err
+	}
+	atomicLevel := zap.NewAtomicLevelAt(level)
+
+	zapConfig := zap.NewProductionConfig()
+	zapConfig.Level = atomicLevel
+	switch cfg.Encoding {
+	case "json", "":
+	case "console":
+		zapConfig.Encoding = "console"
+		zapConfig.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+		zapConfig.EncoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+	default:
+		return nil, zap.AtomicLevel{}, fmt.Errorf("unknown log encoding %q", cfg.Encoding)
+	}
+	if len(cfg.OutputPaths) > 0 {
+		zapConfig.OutputPaths = cfg.OutputPaths
+	}
+	zapConfig.Sampling = nil
+	if cfg.SamplingInitial > 0 {
+		zapConfig.Sampling = &zap.SamplingConfig{
+			Initial:    cfg.SamplingInitial,
+			Thereafter: cfg.SamplingThereafter,
+		}
+	}
+
+	logger, err := zapConfig.Build()
+	if err != nil {
+		return nil, zap.AtomicLevel{}, err
+	}
+	return logger, atomicLevel, nil
+}
+
+// ParseLevel parses a level name such as "debug" or "warn"; "" means info.
+func ParseLevel(s string) (zapcore.Level, error) {
+	if s == "" {
+		return zapcore.InfoLevel, nil
+	}
+	level, err := zapcore.ParseLevel(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid log level %q: %w", s, err)
+	}
+	return level, nil
+}
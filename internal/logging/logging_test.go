@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestNew_LevelAndOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "service.log")
+	logger, level, err := New(Config{Level: "warn", Encoding: "json", OutputPaths: []string{path}})
+	require.NoError(t, err)
+
+	logger.Info("hidden")
+	logger.Warn("shown")
+	level.SetLevel(zapcore.DebugLevel)
+	logger.Debug("shown after level change")
+	require.NoError(t, logger.Sync())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	out := string(data)
+	assert.NotContains(t, out, "hidden")
+	assert.Contains(t, out, `"msg":"shown"`)
+	assert.Contains(t, out, "shown after level change")
+}
+
+func TestNew_Sampling(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "service.log")
+	logger, _, err := New(Config{OutputPaths: []string{path}, SamplingInitial: 2, SamplingThereafter: 0})
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		logger.Info("balance changed", zap.Int("i", i))
+	}
+	require.NoError(t, logger.Sync())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(data), "balance changed"))
+}
+
+func TestNew_ConsoleEncoding(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "service.log")
+	logger, _, err := New(Config{Encoding: "console", OutputPaths: []string{path}})
+	require.NoError(t, err)
+	logger.Info("started")
+	require.NoError(t, logger.Sync())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Regexp(t, `^\S+\tINFO\t\S+\tstarted\n$`, string(data))
+}
+
+func TestNew_Invalid(t *testing.T) {
+	_, _, err := New(Config{Level: "loud"})
+	assert.Error(t, err)
+	_, _, err = New(Config{Encoding: "xml"})
+	assert.Error(t, err)
+}
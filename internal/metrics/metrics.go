@@ -0,0 +1,157 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+)
+
+const namespace = "wallet"
+
+const (
+	OutcomeOK           = "ok"
+	OutcomeInsufficient = "insufficient"
+	OutcomeFrozen       = "frozen"
+	OutcomeError        = "error"
+	OutcomeUnavailable  = "unavailable"
+)
+
+// OperationTransfer labels transfers in the operation metrics; it is not an
+// operation type a wallet request can carry.
+const OperationTransfer model.OperationType = "TRANSFER"
+
+type QueueSource interface {
+	QueueStats() (depths map[uuid.UUID]int, workers int)
+}
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+	operations   *prometheus.CounterVec
+	amounts      *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route and status.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method, route and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "operations_total",
+			Help:      "Deposits and withdrawals by outcome.",
+		}, []string{"operation", "outcome"}),
+		amounts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "operation_amount_total",
+			Help:      "Sum of amounts of successful deposits and withdrawals.",
+		}, []string{"operation"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests, m.httpDuration, m.operations, m.amounts,
+	)
+	return m
+}
+
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// Middleware records every request under its route template rather than the
+// raw path, so wallet IDs do not end up in label values.
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		m.httpRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+		m.httpDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+func (m *Metrics) ObserveOperation(op model.OperationType, outcome string, amount int64) {
+	m.operations.WithLabelValues(string(op), outcome).Inc()
+	if outcome == OutcomeOK {
+		m.amounts.WithLabelValues(string(op)).Add(float64(amount))
+	}
+}
+
+func (m *Metrics) RegisterDBStats(db *sql.DB) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, namespace))
+}
+
+func (m *Metrics) RegisterQueues(src QueueSource) {
+	m.registry.MustRegister(&queueCollector{src: src})
+}
+
+func (m *Metrics) RegisterInFlight(fn func() int64) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "Requests currently being served.",
+	}, func() float64 { return float64(fn()) }))
+}
+
+// RegisterBreaker exports the database circuit breaker state: 0 closed,
+// 1 half-open, 2 open.
+func (m *Metrics) RegisterBreaker(fn func() int) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "db_circuit_breaker_state",
+		Help:      "Database circuit breaker state: 0 closed, 1 half-open, 2 open.",
+	}, func() float64 { return float64(fn()) }))
+}
+
+var (
+	queueDepthDesc = prometheus.NewDesc(namespace+"_queue_depth",
+		"Jobs waiting in a wallet's queue. Only wallets with pending jobs are reported.", []string{"wallet_id"}, nil)
+	queueWorkersDesc = prometheus.NewDesc(namespace+"_queue_workers",
+		"Worker goroutines serving wallet queues.", nil, nil)
+)
+
+// queueCollector reports only non-empty queues so the wallet_id label stays
+// bounded by the number of wallets busy at scrape time.
+type queueCollector struct {
+	src QueueSource
+}
+
+func (q *queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueDepthDesc
+	ch <- queueWorkersDesc
+}
+
+func (q *queueCollector) Collect(ch chan<- prometheus.Metric) {
+	depths, workers := q.src.QueueStats()
+	for id, depth := range depths {
+		if depth > 0 {
+			ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(depth), id.String())
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(queueWorkersDesc, prometheus.GaugeValue, float64(workers))
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+)
+
+type fakeQueues struct {
+	depths  map[uuid.UUID]int
+	workers int
+}
+
+func (f fakeQueues) QueueStats() (map[uuid.UUID]int, int) {
+	return f.depths, f.workers
+}
+
+func TestMetrics_Middleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := New()
+
+	router := gin.New()
+	router.Use(m.Middleware())
+	router.GET("/wallets/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for i := 0; i < 2; i++ {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/wallets/"+uuid.New().String(), nil))
+	}
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nope", nil))
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.httpRequests.WithLabelValues("GET", "/wallets/:id", "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.httpRequests.WithLabelValues("GET", "unmatched", "404")))
+	assert.Equal(t, 2, testutil.CollectAndCount(m.httpDuration))
+}
+
+func TestMetrics_ObserveOperation(t *testing.T) {
+	m := New()
+	m.ObserveOperation(model.Deposit, OutcomeOK, 100)
+	m.ObserveOperation(model.Deposit, OutcomeOK, 50)
+	m.ObserveOperation(model.Withdraw, OutcomeInsufficient, 1000)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.operations.WithLabelValues("DEPOSIT", "ok")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.operations.WithLabelValues("WITHDRAW", "insufficient")))
+	assert.Equal(t, 150.0, testutil.ToFloat64(m.amounts.WithLabelValues("DEPOSIT")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.amounts.WithLabelValues("WITHDRAW")))
+}
+
+func TestMetrics_Handler(t *testing.T) {
+	busy, idle := uuid.New(), uuid.New()
+	m := New()
+	m.RegisterQueues(fakeQueues{depths: map[uuid.UUID]int{busy: 3, idle: 0}, workers: 2})
+	m.RegisterInFlight(func() int64 { return 7 })
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(w.Body)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, string(body), `wallet_queue_depth{wallet_id="`+busy.String()+`"} 3`)
+	assert.NotContains(t, string(body), idle.String())
+	assert.Contains(t, string(body), "wallet_queue_workers 2")
+	assert.Contains(t, string(body), "wallet_http_requests_in_flight 7")
+	assert.Contains(t, string(body), "go_goroutines")
+}
@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yokitheyo/go_wallet_test/internal/auth"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"github.com/yokitheyo/go_wallet_test/internal/repo"
+	"go.uber.org/zap"
+)
+
+const principalContextKey = "principal"
+
+type APIKeyStore interface {
+	GetAPIKeyByHash(ctx context.Context, hash string) (model.APIKey, error)
+}
+
+type cachedKey struct {
+	key     model.APIKey
+	expires time.Time
+}
+
+// APIKeyAuth authenticates requests by the key in X-API-Key or an
+// "Authorization: Bearer" header. Successful lookups are cached for ttl, so a
+// revoked key may keep working for up to ttl.
+type APIKeyAuth struct {
+	store  APIKeyStore
+	ttl    time.Duration
+	logger *zap.Logger
+	now    func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cachedKey
+}
+
+func NewAPIKeyAuth(store APIKeyStore, ttl time.Duration, logger *zap.Logger) *APIKeyAuth {
+	return &APIKeyAuth{
+		store:  store,
+		ttl:    ttl,
+		logger: logger,
+		now:    time.Now,
+		cache:  make(map[string]cachedKey),
+	}
+}
+
+func (a *APIKeyAuth) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := PrincipalFrom(c); ok {
+			c.Next()
+			return
+		}
+
+		raw := extractAPIKey(c.Request)
+		if raw == "" {
+			c.Header("WWW-Authenticate", `Bearer realm="wallet"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing api key"})
+			return
+		}
+
+		key, err := a.lookup(c.Request.Context(), auth.HashAPIKey(raw))
+		if errors.Is(err, repo.ErrNotFound) {
+			a.logger.Warn("rejected unknown api key", zap.String("client_ip", c.ClientIP()))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid api key"})
+			return
+		}
+		if AbortUnavailable(c, err) {
+			a.logger.Warn("api key lookup skipped, database unavailable", zap.Error(err))
+			return
+		}
+		if err != nil {
+			a.logger.Error("api key lookup failed", zap.Error(err))
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			return
+		}
+
+		SetPrincipal(c, key.Principal())
+		c.Next()
+	}
+}
+
+// Invalidate drops every cached key, e.g. right after a rotation or revocation
+// made through this instance.
+func (a *APIKeyAuth) Invalidate() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.cache = make(map[string]cachedKey)
+}
+
+func (a *APIKeyAuth) lookup(ctx context.Context, hash string) (model.APIKey, error) {
+	now := a.now()
+
+	a.mu.Lock()
+	if cached, ok := a.cache[hash]; ok && now.Before(cached.expires) {
+		a.mu.Unlock()
+		return cached.key, nil
+	}
+	a.mu.Unlock()
+
+	key, err := a.store.GetAPIKeyByHash(ctx, hash)
+	if err != nil {
+		return key, err
+	}
+
+	if a.ttl > 0 {
+		a.mu.Lock()
+		a.cache[hash] = cachedKey{key: key, expires: now.Add(a.ttl)}
+		a.mu.Unlock()
+	}
+	return key, nil
+}
+
+func extractAPIKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	authz := r.Header.Get("Authorization")
+	if len(authz) > 7 && strings.EqualFold(authz[:7], "bearer ") {
+		return strings.TrimSpace(authz[7:])
+	}
+	return ""
+}
+
+// SetPrincipal records the authenticated caller of the request.
+func SetPrincipal(c *gin.Context, p model.Principal) {
+	c.Set(principalContextKey, p)
+}
+
+func PrincipalFrom(c *gin.Context) (model.Principal, bool) {
+	v, ok := c.Get(principalContextKey)
+	if !ok {
+		return model.Principal{}, false
+	}
+	p, ok := v.(model.Principal)
+	return p, ok
+}
+
+// RequireScope rejects requests whose principal lacks scope. Requests that
+// were not authenticated at all (auth disabled) pass through.
+func RequireScope(scope model.Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := PrincipalFrom(c)
+		if ok && !p.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":  "forbidden",
+				"detail": "missing scope " + string(scope),
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+func WalletAllowed(c *gin.Context, walletID uuid.UUID) bool {
+	p, ok := PrincipalFrom(c)
+	return !ok || p.AllowsWallet(walletID)
+}
@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/yokitheyo/go_wallet_test/internal/auth"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"github.com/yokitheyo/go_wallet_test/internal/repo"
+	"go.uber.org/zap"
+)
+
+type MockAPIKeyStore struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyStore) GetAPIKeyByHash(ctx context.Context, hash string) (model.APIKey, error) {
+	args := m.Called(ctx, hash)
+	return args.Get(0).(model.APIKey), args.Error(1)
+}
+
+func setupAuthRouter(store APIKeyStore, ttl time.Duration) (*gin.Engine, *APIKeyAuth) {
+	gin.SetMode(gin.TestMode)
+	a := NewAPIKeyAuth(store, ttl, zap.NewNop())
+
+	router := gin.New()
+	router.Use(a.Middleware())
+	router.GET("/read", RequireScope(model.ScopeWalletRead), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.GET("/admin", RequireScope(model.ScopeAdmin), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.GET("/wallets/:id", func(c *gin.Context) {
+		if !WalletAllowed(c, uuid.MustParse(c.Param("id"))) {
+			c.Status(http.StatusForbidden)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+	return router, a
+}
+
+func doAuthRequest(router *gin.Engine, path string, header, value string) int {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	if header != "" {
+		req.Header.Set(header, value)
+	}
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestAPIKeyAuth_Middleware(t *testing.T) {
+	store := &MockAPIKeyStore{}
+	router, _ := setupAuthRouter(store, time.Minute)
+
+	allowedWallet := uuid.New()
+	store.On("GetAPIKeyByHash", mock.Anything, auth.HashAPIKey("wk_reader")).
+		Return(model.APIKey{Scopes: []model.Scope{model.ScopeWalletRead}, WalletIDs: []uuid.UUID{allowedWallet}}, nil)
+	store.On("GetAPIKeyByHash", mock.Anything, auth.HashAPIKey("wk_unknown")).
+		Return(model.APIKey{}, repo.ErrNotFound)
+
+	t.Run("missing key", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, doAuthRequest(router, "/read", "", ""))
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, doAuthRequest(router, "/read", "X-API-Key", "wk_unknown"))
+	})
+
+	t.Run("valid key via X-API-Key", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, doAuthRequest(router, "/read", "X-API-Key", "wk_reader"))
+	})
+
+	t.Run("valid key via bearer", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, doAuthRequest(router, "/read", "Authorization", "Bearer wk_reader"))
+	})
+
+	t.Run("missing scope", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, doAuthRequest(router, "/admin", "X-API-Key", "wk_reader"))
+	})
+
+	t.Run("wallet restriction", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, doAuthRequest(router, "/wallets/"+allowedWallet.String(), "X-API-Key", "wk_reader"))
+		assert.Equal(t, http.StatusForbidden, doAuthRequest(router, "/wallets/"+uuid.New().String(), "X-API-Key", "wk_reader"))
+	})
+
+	store.AssertNumberOfCalls(t, "GetAPIKeyByHash", 2)
+}
+
+func TestAPIKeyAuth_CacheInvalidate(t *testing.T) {
+	store := &MockAPIKeyStore{}
+	router, a := setupAuthRouter(store, time.Minute)
+
+	store.On("GetAPIKeyByHash", mock.Anything, auth.HashAPIKey("wk_admin")).
+		Return(model.APIKey{Scopes: []model.Scope{model.ScopeAdmin}}, nil).Once()
+	store.On("GetAPIKeyByHash", mock.Anything, auth.HashAPIKey("wk_admin")).
+		Return(model.APIKey{}, repo.ErrNotFound).Once()
+
+	assert.Equal(t, http.StatusOK, doAuthRequest(router, "/admin", "X-API-Key", "wk_admin"))
+	assert.Equal(t, http.StatusOK, doAuthRequest(router, "/admin", "X-API-Key", "wk_admin"))
+
+	a.Invalidate()
+	assert.Equal(t, http.StatusUnauthorized, doAuthRequest(router, "/admin", "X-API-Key", "wk_admin"))
+	store.AssertExpectations(t)
+}
+
+func TestRequireScope_WithoutAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/admin", RequireScope(model.ScopeAdmin), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	assert.Equal(t, http.StatusOK, doAuthRequest(router, "/admin", "", ""))
+}
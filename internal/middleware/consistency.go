@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yokitheyo/go_wallet_test/internal/repo"
+)
+
+const HeaderReadConsistency = "X-Read-Consistency"
+
+// ReadConsistency sends the reads of requests carrying
+// "X-Read-Consistency: strong" to the primary database, so a client can read
+// its own writes, e.g. the balance right after a deposit.
+func ReadConsistency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if strings.EqualFold(c.GetHeader(HeaderReadConsistency), "strong") {
+			c.Request = c.Request.WithContext(repo.WithPrimary(c.Request.Context()))
+		}
+		c.Next()
+	}
+}
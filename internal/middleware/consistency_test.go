@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/yokitheyo/go_wallet_test/internal/repo"
+)
+
+func TestReadConsistency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var primary bool
+	router := gin.New()
+	router.Use(ReadConsistency())
+	router.GET("/", func(c *gin.Context) {
+		primary = repo.UsesPrimary(c.Request.Context())
+		c.Status(http.StatusOK)
+	})
+
+	for header, want := range map[string]bool{"": false, "eventual": false, "strong": true, "Strong": true} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(HeaderReadConsistency, header)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+		assert.Equal(t, want, primary, "header %q", header)
+	}
+}
@@ -3,10 +3,12 @@ package middleware
 import (
 	"context"
 	"net/http"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/yokitheyo/go_wallet_test/internal/logctx"
 	"go.uber.org/zap"
 )
 
@@ -14,11 +16,14 @@ type GracefulShutdown struct {
 	activeRequests int64
 	shutdown       int32
 	logger         *zap.Logger
+	done           chan struct{}
+	closeDone      sync.Once
 }
 
 func NewGracefulShutdown(logger *zap.Logger) *GracefulShutdown {
 	return &GracefulShutdown{
 		logger: logger,
+		done:   make(chan struct{}),
 	}
 }
 
@@ -39,7 +44,7 @@ func (gs *GracefulShutdown) Middleware() gin.HandlerFunc {
 		c.Next()
 		duration := time.Since(start)
 
-		gs.logger.Debug("Request completed",
+		logctx.Logger(c.Request.Context(), gs.logger).Debug("Request completed",
 			zap.String("method", c.Request.Method),
 			zap.String("path", c.Request.URL.Path),
 			zap.Int("status", c.Writer.Status()),
@@ -52,6 +57,7 @@ func (gs *GracefulShutdown) Shutdown(ctx context.Context) error {
 	gs.logger.Info("Initiating graceful shutdown...")
 
 	atomic.StoreInt32(&gs.shutdown, 1)
+	gs.closeDone.Do(func() { close(gs.done) })
 
 	ticker := time.NewTicker(100 * time.Millisecond)
 	defer ticker.Stop()
@@ -80,3 +86,9 @@ func (gs *GracefulShutdown) GetActiveRequests() int64 {
 func (gs *GracefulShutdown) IsShuttingDown() bool {
 	return atomic.LoadInt32(&gs.shutdown) == 1
 }
+
+// Done is closed when shutdown starts. Long-lived handlers such as event
+// streams select on it so they finish instead of holding shutdown open.
+func (gs *GracefulShutdown) Done() <-chan struct{} {
+	return gs.done
+}
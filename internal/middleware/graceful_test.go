@@ -57,6 +57,12 @@ func TestGracefulShutdown_Shutdown(t *testing.T) {
 	err := gs.Shutdown(ctx)
 	assert.NoError(t, err)
 	assert.True(t, gs.IsShuttingDown())
+
+	select {
+	case <-gs.Done():
+	default:
+		t.Fatal("Done channel should be closed after shutdown")
+	}
 }
 
 func TestGracefulShutdown_ShutdownTimeout(t *testing.T) {
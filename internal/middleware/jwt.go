@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"go.uber.org/zap"
+)
+
+type TokenVerifier interface {
+	Verify(token string) (model.Principal, error)
+}
+
+// JWTAuth authenticates "Authorization: Bearer <jwt>" requests. Requests
+// without a JWT are passed on untouched so API-key auth can handle them.
+func JWTAuth(verifier TokenVerifier, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := PrincipalFrom(c); ok {
+			c.Next()
+			return
+		}
+
+		token := bearerJWT(c.Request)
+		if token == "" {
+			c.Next()
+			return
+		}
+
+		p, err := verifier.Verify(token)
+		if err != nil {
+			logger.Warn("rejected jwt", zap.String("client_ip", c.ClientIP()), zap.Error(err))
+			c.Header("WWW-Authenticate", `Bearer realm="wallet", error="invalid_token"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
+		}
+
+		SetPrincipal(c, p)
+		c.Next()
+	}
+}
+
+func bearerJWT(r *http.Request) string {
+	authz := r.Header.Get("Authorization")
+	if len(authz) <= 7 || !strings.EqualFold(authz[:7], "bearer ") {
+		return ""
+	}
+	token := strings.TrimSpace(authz[7:])
+	if strings.Count(token, ".") != 2 {
+		return ""
+	}
+	return token
+}
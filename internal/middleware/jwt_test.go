@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/yokitheyo/go_wallet_test/internal/auth"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"go.uber.org/zap"
+)
+
+type MockTokenVerifier struct {
+	mock.Mock
+}
+
+func (m *MockTokenVerifier) Verify(token string) (model.Principal, error) {
+	args := m.Called(token)
+	return args.Get(0).(model.Principal), args.Error(1)
+}
+
+func TestJWTAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	walletID := uuid.New()
+
+	verifier := &MockTokenVerifier{}
+	verifier.On("Verify", "a.b.c").Return(model.Principal{
+		Kind: model.PrincipalJWT, Scopes: []model.Scope{model.ScopeWalletRead}, WalletIDs: []uuid.UUID{walletID},
+	}, nil)
+	verifier.On("Verify", "x.y.z").Return(model.Principal{}, errors.New("token is expired"))
+
+	keys := &MockAPIKeyStore{}
+	keys.On("GetAPIKeyByHash", mock.Anything, auth.HashAPIKey("wk_admin")).
+		Return(model.APIKey{Scopes: []model.Scope{model.ScopeAdmin}}, nil)
+
+	router := gin.New()
+	router.Use(JWTAuth(verifier, zap.NewNop()))
+	router.Use(NewAPIKeyAuth(keys, time.Minute, zap.NewNop()).Middleware())
+	router.GET("/wallets/:id", RequireScope(model.ScopeWalletRead), func(c *gin.Context) {
+		if !WalletAllowed(c, uuid.MustParse(c.Param("id"))) {
+			c.Status(http.StatusForbidden)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	own := "/wallets/" + walletID.String()
+	foreign := "/wallets/" + uuid.New().String()
+
+	assert.Equal(t, http.StatusOK, doAuthRequest(router, own, "Authorization", "Bearer a.b.c"))
+	assert.Equal(t, http.StatusForbidden, doAuthRequest(router, foreign, "Authorization", "Bearer a.b.c"))
+	assert.Equal(t, http.StatusUnauthorized, doAuthRequest(router, own, "Authorization", "Bearer x.y.z"))
+	assert.Equal(t, http.StatusOK, doAuthRequest(router, foreign, "Authorization", "Bearer wk_admin"))
+	assert.Equal(t, http.StatusUnauthorized, doAuthRequest(router, own, "", ""))
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/yokitheyo/go_wallet_test/internal/logctx"
 	"go.uber.org/zap"
 )
 
@@ -15,7 +16,7 @@ func Logger(logger *zap.Logger) gin.HandlerFunc {
 
 		duration := time.Since(start)
 
-		logger.Info("HTTP request",
+		logctx.Logger(c.Request.Context(), logger).Info("HTTP request",
 			zap.String("method", c.Request.Method),
 			zap.String("path", c.Request.URL.Path),
 			zap.Int("status", c.Writer.Status()),
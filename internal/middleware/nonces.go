@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// NonceStore remembers the nonces of signed requests. Remember reports false
+// if key was already remembered less than ttl ago.
+type NonceStore interface {
+	Remember(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+// MemoryNonces keeps nonces in the process. A request accepted by one
+// replica can be replayed against another, so it only suits a single
+// instance.
+type MemoryNonces struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	nonces    map[string]time.Time
+	lastSweep time.Time
+}
+
+func NewMemoryNonces() *MemoryNonces {
+	return &MemoryNonces{now: time.Now, nonces: make(map[string]time.Time)}
+}
+
+func (m *MemoryNonces) Remember(_ context.Context, key string, ttl time.Duration) (bool, error) {
+	now := m.now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.lastSweep) > ttl {
+		for k, expires := range m.nonces {
+			if now.After(expires) {
+				delete(m.nonces, k)
+			}
+		}
+		m.lastSweep = now
+	}
+
+	if expires, ok := m.nonces[key]; ok && now.Before(expires) {
+		return false, nil
+	}
+	m.nonces[key] = now.Add(ttl)
+	return true, nil
+}
+
+type PostgresNonceStore interface {
+	RememberNonce(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	PruneNonces(ctx context.Context) (int64, error)
+}
+
+// PostgresNonces shares nonces between replicas, so a signed request is
+// accepted at most once by the whole deployment.
+type PostgresNonces struct {
+	store  PostgresNonceStore
+	logger *zap.Logger
+}
+
+func NewPostgresNonces(store PostgresNonceStore, logger *zap.Logger) *PostgresNonces {
+	return &PostgresNonces{store: store, logger: logger}
+}
+
+func (p *PostgresNonces) Remember(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return p.store.RememberNonce(ctx, key, ttl)
+}
+
+// Run periodically deletes expired nonces.
+func (p *PostgresNonces) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := p.store.PruneNonces(ctx)
+			if err != nil {
+				p.logger.Error("failed to prune signature nonces", zap.Error(err))
+				continue
+			}
+			if n > 0 {
+				p.logger.Debug("pruned signature nonces", zap.Int64("count", n))
+			}
+		}
+	}
+}
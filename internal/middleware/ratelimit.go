@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yokitheyo/go_wallet_test/internal/ratelimit"
+	"go.uber.org/zap"
+)
+
+const rateLimitRemainingKey = "ratelimit_remaining"
+
+type RateLimits struct {
+	IP     ratelimit.Limit
+	Client ratelimit.Limit
+	Wallet ratelimit.Limit
+}
+
+// RateLimit enforces token-bucket limits per client IP, per authenticated
+// client and per wallet. When a request is checked against several buckets
+// the RateLimit-* headers describe the one closest to its limit. If the
+// limiter itself fails the request is let through.
+type RateLimit struct {
+	limiter ratelimit.Limiter
+	limits  atomic.Pointer[RateLimits]
+	logger  *zap.Logger
+}
+
+func NewRateLimit(limiter ratelimit.Limiter, limits RateLimits, logger *zap.Logger) *RateLimit {
+	l := &RateLimit{limiter: limiter, logger: logger}
+	l.SetLimits(limits)
+	return l
+}
+
+// SetLimits replaces the limits for subsequent requests. Buckets keep their
+// current token count.
+func (l *RateLimit) SetLimits(limits RateLimits) {
+	l.limits.Store(&limits)
+}
+
+func (l *RateLimit) Limits() RateLimits {
+	return *l.limits.Load()
+}
+
+func (l *RateLimit) ByIP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if l.check(c, "ip:"+c.ClientIP(), l.Limits().IP) {
+			c.Next()
+		}
+	}
+}
+
+// ByClient limits the authenticated principal and must run after the auth
+// middleware. Anonymous requests are skipped.
+func (l *RateLimit) ByClient() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := PrincipalFrom(c)
+		if ok && !l.check(c, "client:"+p.Kind+":"+p.ID, l.Limits().Client) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// ByWallet limits the wallet named by the :id path parameter or, failing
+// that, by the walletId field of a JSON body.
+func (l *RateLimit) ByWallet() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		walletID, ok := requestWalletID(c)
+		if ok && !l.check(c, "wallet:"+walletID.String(), l.Limits().Wallet) {
+			return
+		}
+		c.Next()
+	}
+}
+
+func (l *RateLimit) check(c *gin.Context, key string, limit ratelimit.Limit) bool {
+	if !limit.Enabled() {
+		return true
+	}
+
+	res, err := l.limiter.Allow(c.Request.Context(), key, limit)
+	if err != nil {
+		l.logger.Warn("rate limiter unavailable, allowing request", zap.String("key", key), zap.Error(err))
+		return true
+	}
+
+	if remaining, ok := c.Get(rateLimitRemainingKey); !ok || res.Remaining <= remaining.(int) || !res.Allowed {
+		c.Set(rateLimitRemainingKey, res.Remaining)
+		c.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("RateLimit-Reset", ceilSeconds(res.Reset.Seconds()))
+	}
+
+	if !res.Allowed {
+		l.logger.Warn("rate limit exceeded", zap.String("key", key), zap.String("path", c.FullPath()))
+		c.Header("Retry-After", ceilSeconds(res.RetryAfter.Seconds()))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+		return false
+	}
+	return true
+}
+
+func ceilSeconds(s float64) string {
+	return strconv.Itoa(int(math.Ceil(s)))
+}
+
+func requestWalletID(c *gin.Context) (uuid.UUID, bool) {
+	if raw := c.Param("id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		return id, err == nil
+	}
+	if c.Request.Body == nil || c.ContentType() != "application/json" {
+		return uuid.Nil, false
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBufferedBodyBytes))
+	c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+	if err != nil {
+		return uuid.Nil, false
+	}
+
+	var req struct {
+		WalletID uuid.UUID `json:"walletId"`
+	}
+	if json.Unmarshal(body, &req) != nil || req.WalletID == uuid.Nil {
+		return uuid.Nil, false
+	}
+	return req.WalletID, true
+}
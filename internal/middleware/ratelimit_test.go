@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/yokitheyo/go_wallet_test/internal/ratelimit"
+	"go.uber.org/zap"
+)
+
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(context.Context, string, ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("db down")
+}
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	l := NewRateLimit(ratelimit.NewMemoryLimiter(), RateLimits{
+		IP:     ratelimit.Limit{Rate: 1, Burst: 5},
+		Wallet: ratelimit.Limit{Rate: 1, Burst: 2},
+	}, zap.NewNop())
+
+	router := gin.New()
+	router.Use(l.ByIP(), l.ByClient())
+	wallets := router.Group("", l.ByWallet())
+	wallets.POST("/wallet", func(c *gin.Context) {
+		var req struct {
+			WalletID uuid.UUID `json:"walletId"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+	wallets.GET("/wallets/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	body := `{"walletId":"` + uuid.New().String() + `"}`
+	w := do(http.MethodPost, "/wallet", body)
+	assert.Equal(t, http.StatusOK, w.Code, "body is still readable by the handler")
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"), "headers describe the tightest bucket")
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/wallet", body).Code)
+	w = do(http.MethodPost, "/wallet", body)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/wallets/"+uuid.New().String(), "").Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/wallets/"+uuid.New().String(), "").Code)
+	w = do(http.MethodGet, "/wallets/"+uuid.New().String(), "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code, "ip bucket is exhausted")
+	assert.Equal(t, "5", w.Header().Get("RateLimit-Limit"))
+}
+
+func TestRateLimit_FailsOpen(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	l := NewRateLimit(failingLimiter{}, RateLimits{IP: ratelimit.Limit{Rate: 1, Burst: 1}}, zap.NewNop())
+	router := gin.New()
+	router.GET("/", l.ByIP(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+}
+
+func TestRateLimit_SetLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	l := NewRateLimit(ratelimit.NewMemoryLimiter(), RateLimits{IP: ratelimit.Limit{Rate: 1, Burst: 1}}, zap.NewNop())
+	router := gin.New()
+	router.GET("/", l.ByIP(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	do := func() int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, do())
+	assert.Equal(t, http.StatusTooManyRequests, do())
+
+	l.SetLimits(RateLimits{})
+	assert.Equal(t, http.StatusOK, do(), "a zero rate disables the limit")
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yokitheyo/go_wallet_test/internal/logctx"
+	"github.com/yokitheyo/go_wallet_test/internal/tracing"
+	"go.uber.org/zap"
+)
+
+const (
+	HeaderRequestID    = "X-Request-ID"
+	maxRequestIDLength = 128
+)
+
+// RequestID accepts the caller's X-Request-ID or generates one, echoes it in
+// the response and stores it in the request context together with a logger
+// that carries request_id and, when tracing is active, trace_id and span_id.
+// It must run after the tracing middleware.
+func RequestID(logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(HeaderRequestID)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		c.Header(HeaderRequestID, id)
+
+		ctx := logctx.WithRequestID(c.Request.Context(), id)
+		fields := append([]zap.Field{zap.String("request_id", id)}, tracing.LogFields(ctx)...)
+		ctx = logctx.WithLogger(ctx, logger.With(fields...))
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
+
+// validRequestID accepts printable ASCII without spaces so a caller cannot
+// inject arbitrary bytes into logs and headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
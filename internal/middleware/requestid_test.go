@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/yokitheyo/go_wallet_test/internal/logctx"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	core, logs := observer.New(zap.InfoLevel)
+
+	router := gin.New()
+	router.Use(RequestID(zap.New(core)))
+	router.GET("/", func(c *gin.Context) {
+		logctx.Logger(c.Request.Context(), zap.NewNop()).Info("handled")
+		c.String(http.StatusOK, logctx.RequestID(c.Request.Context()))
+	})
+
+	do := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if id != "" {
+			req.Header.Set(HeaderRequestID, id)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("support-ticket-123")
+	assert.Equal(t, "support-ticket-123", w.Header().Get(HeaderRequestID))
+	assert.Equal(t, "support-ticket-123", w.Body.String())
+	if assert.Equal(t, 1, logs.Len()) {
+		assert.Equal(t, "support-ticket-123", logs.All()[0].ContextMap()["request_id"])
+	}
+
+	w = do("")
+	_, err := uuid.Parse(w.Header().Get(HeaderRequestID))
+	assert.NoError(t, err, "a request id is generated when none is sent")
+
+	for _, bad := range []string{"has space", "new\nline", strings.Repeat("a", maxRequestIDLength+1)} {
+		w = do(bad)
+		assert.NotEqual(t, bad, w.Header().Get(HeaderRequestID))
+		_, err := uuid.Parse(w.Header().Get(HeaderRequestID))
+		assert.NoError(t, err)
+	}
+}
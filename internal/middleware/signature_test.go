@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/yokitheyo/go_wallet_test/internal/auth"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"go.uber.org/zap"
+)
+
+func TestSignatureAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	now := time.Unix(1700000000, 0)
+
+	s := NewSignatureAuth(map[string]SignedClient{
+		"processor": {
+			Secret:    "secret",
+			Principal: model.Principal{Kind: model.PrincipalHMAC, ID: "processor", Scopes: []model.Scope{model.ScopeWalletWrite}, AllWallets: true},
+		},
+	}, 5*time.Minute, NewMemoryNonces(), zap.NewNop())
+	s.now = func() time.Time { return now }
+
+	router := gin.New()
+	router.Use(s.Middleware())
+	router.POST("/api/v1/wallet", func(c *gin.Context) {
+		p, ok := PrincipalFrom(c)
+		if !ok {
+			c.Status(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, p.ID+":"+string(body))
+	})
+
+	body := []byte(`{"amount":100}`)
+	do := func(clientID, secret string, ts time.Time, nonce string, sent []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/wallet", bytes.NewReader(sent))
+		req.Header.Set(auth.HeaderClientID, clientID)
+		req.Header.Set(auth.HeaderTimestamp, strconv.FormatInt(ts.Unix(), 10))
+		req.Header.Set(auth.HeaderNonce, nonce)
+		req.Header.Set(auth.HeaderSignature, auth.SignRequest(secret, http.MethodPost, "/api/v1/wallet", ts, nonce, body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("processor", "secret", now, "n1", body)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `processor:{"amount":100}`, w.Body.String())
+
+	w = do("processor", "secret", now, "n1", body)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "replayed request")
+
+	assert.Equal(t, http.StatusOK, do("processor", "secret", now.Add(-4*time.Minute), "n2", body).Code)
+	assert.Equal(t, http.StatusUnauthorized, do("processor", "secret", now.Add(-6*time.Minute), "n3", body).Code)
+	assert.Equal(t, http.StatusUnauthorized, do("processor", "secret", now.Add(6*time.Minute), "n4", body).Code)
+	assert.Equal(t, http.StatusUnauthorized, do("processor", "secret", now, "n5", []byte(`{"amount":900}`)).Code)
+	assert.Equal(t, http.StatusUnauthorized, do("processor", "wrong", now, "n6", body).Code)
+	assert.Equal(t, http.StatusUnauthorized, do("unknown", "secret", now, "n7", body).Code)
+
+	// Unsigned requests are left for the other authenticators.
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/wallet", bytes.NewReader(body))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Empty(t, w.Body.String())
+}
+
+func TestMemoryNonces_Expire(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	m := NewMemoryNonces()
+	m.now = func() time.Time { return now }
+	remember := func(at time.Time) bool {
+		now = at
+		fresh, err := m.Remember(context.Background(), "c:n", 2*time.Minute)
+		assert.NoError(t, err)
+		return fresh
+	}
+
+	start := now
+	assert.True(t, remember(start))
+	assert.False(t, remember(start.Add(time.Minute)))
+	assert.True(t, remember(start.Add(3*time.Minute)))
+	assert.Len(t, m.nonces, 1)
+}
+
+type failingNonces struct{}
+
+func (failingNonces) Remember(context.Context, string, time.Duration) (bool, error) {
+	return false, errors.New("db down")
+}
+
+func TestSignatureAuth_FailsClosedWhenNoncesUnavailable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	now := time.Now()
+	s := NewSignatureAuth(map[string]SignedClient{"processor": {Secret: "secret"}}, time.Minute, failingNonces{}, zap.NewNop())
+
+	router := gin.New()
+	router.Use(s.Middleware())
+	router.GET("/api/v1/wallets/x", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/wallets/x", nil)
+	req.Header.Set(auth.HeaderClientID, "processor")
+	req.Header.Set(auth.HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(auth.HeaderNonce, "n1")
+	req.Header.Set(auth.HeaderSignature, auth.SignRequest("secret", http.MethodGet, "/api/v1/wallets/x", now, "n1", nil))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yokitheyo/go_wallet_test/internal/repo"
+)
+
+// AbortUnavailable answers 503 with a Retry-After header when err says the
+// database circuit breaker is open, and reports whether it did.
+func AbortUnavailable(c *gin.Context, err error) bool {
+	var unavailable *repo.UnavailableError
+	if !errors.As(err, &unavailable) {
+		return false
+	}
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(unavailable.RetryAfter.Seconds()))))
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "service unavailable", "detail": err.Error()})
+	return true
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type FreezeWalletRequest struct {
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// SetMinBalanceRequest sets how far below zero a wallet may go; 0 removes
+// the credit line.
+type SetMinBalanceRequest struct {
+	MinBalance *int64 `json:"minBalance" binding:"required,max=0"`
+	Reason     string `json:"reason" binding:"max=500"`
+}
+
+type MinBalanceChange struct {
+	ID            int64     `json:"id"`
+	WalletID      uuid.UUID `json:"walletId"`
+	OldMinBalance int64     `json:"oldMinBalance"`
+	NewMinBalance int64     `json:"newMinBalance"`
+	Reason        string    `json:"reason,omitempty"`
+	RequestID     string    `json:"requestId,omitempty"`
+	ChangedAt     time.Time `json:"changedAt"`
+}
+
+type ReconciliationMismatch struct {
+	WalletID      uuid.UUID `json:"walletId"`
+	Balance       int64     `json:"balance"`
+	LedgerBalance int64     `json:"ledgerBalance"`
+	LedgerEntryID int64     `json:"ledgerEntryId"`
+}
+
+type QueueInfo struct {
+	WalletID uuid.UUID `json:"walletId"`
+	Depth    int       `json:"depth"`
+}
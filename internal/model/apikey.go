@@ -0,0 +1,51 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Scope string
+
+const (
+	ScopeWalletRead  Scope = "wallet:read"
+	ScopeWalletWrite Scope = "wallet:write"
+	ScopeAdmin       Scope = "admin"
+)
+
+func (s Scope) Valid() bool {
+	return s == ScopeWalletRead || s == ScopeWalletWrite || s == ScopeAdmin
+}
+
+type APIKey struct {
+	ID        uuid.UUID   `json:"id"`
+	Name      string      `json:"name"`
+	Prefix    string      `json:"prefix"`
+	Scopes    []Scope     `json:"scopes"`
+	WalletIDs []uuid.UUID `json:"walletIds"`
+	CreatedAt time.Time   `json:"createdAt"`
+	RotatedAt *time.Time  `json:"rotatedAt,omitempty"`
+	RevokedAt *time.Time  `json:"revokedAt,omitempty"`
+}
+
+func (k APIKey) Principal() Principal {
+	return Principal{
+		Kind:       PrincipalAPIKey,
+		ID:         k.ID.String(),
+		Scopes:     k.Scopes,
+		WalletIDs:  k.WalletIDs,
+		AllWallets: len(k.WalletIDs) == 0,
+	}
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string      `json:"name" binding:"required,max=100"`
+	Scopes    []Scope     `json:"scopes" binding:"required,min=1,dive,oneof=wallet:read wallet:write admin"`
+	WalletIDs []uuid.UUID `json:"walletIds"`
+}
+
+type APIKeyWithSecret struct {
+	APIKey
+	Key string `json:"key"`
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const EventBalanceChanged = "BalanceChanged"
+
+type BalanceChanged struct {
+	TransactionID int64         `json:"transactionId"`
+	WalletID      uuid.UUID     `json:"walletId"`
+	OperationType OperationType `json:"operationType"`
+	Amount        int64         `json:"amount"`
+	Balance       int64         `json:"balance"`
+	TransferID    *uuid.UUID    `json:"transferId,omitempty"`
+	OccurredAt    time.Time     `json:"occurredAt"`
+}
+
+type Event struct {
+	ID        int64           `json:"id"`
+	WalletID  uuid.UUID       `json:"walletId"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Attempts  int             `json:"-"`
+}
@@ -0,0 +1,18 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type LedgerEntry struct {
+	ID            int64         `json:"id"`
+	WalletID      uuid.UUID     `json:"walletId"`
+	OperationType OperationType `json:"operationType"`
+	Amount        int64         `json:"amount"`
+	BalanceAfter  int64         `json:"balanceAfter"`
+	RequestID     string        `json:"requestId,omitempty"`
+	TransferID    *uuid.UUID    `json:"transferId,omitempty"`
+	CreatedAt     time.Time     `json:"createdAt"`
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OwnerWallets lists the wallets of an owner. Wallets have no currency, so
+// the total is a single sum over all of them.
+type OwnerWallets struct {
+	OwnerID string      `json:"ownerId"`
+	Wallets []Wallet    `json:"wallets"`
+	Total   OwnerTotals `json:"total"`
+}
+
+type OwnerTotals struct {
+	Wallets int   `json:"wallets"`
+	Balance int64 `json:"balance"`
+}
+
+func NewOwnerWallets(ownerID string, wallets []Wallet) OwnerWallets {
+	ow := OwnerWallets{OwnerID: ownerID, Wallets: wallets}
+	for _, w := range wallets {
+		ow.Total.Wallets++
+		ow.Total.Balance += w.Balance
+	}
+	return ow
+}
+
+// TransferRequest moves money between two wallets of the same owner.
+type TransferRequest struct {
+	FromWalletID uuid.UUID `json:"fromWalletId" binding:"required"`
+	ToWalletID   uuid.UUID `json:"toWalletId" binding:"required"`
+	Amount       int64     `json:"amount" binding:"required,gt=0"`
+}
+
+type Transfer struct {
+	ID           uuid.UUID `json:"transferId"`
+	OwnerID      string    `json:"ownerId"`
+	FromWalletID uuid.UUID `json:"fromWalletId"`
+	ToWalletID   uuid.UUID `json:"toWalletId"`
+	Amount       int64     `json:"amount"`
+	FromBalance  int64     `json:"fromBalance"`
+	ToBalance    int64     `json:"toBalance"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
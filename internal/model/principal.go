@@ -0,0 +1,41 @@
+package model
+
+import "github.com/google/uuid"
+
+const (
+	PrincipalAPIKey = "api_key"
+	PrincipalJWT    = "jwt"
+	PrincipalHMAC   = "hmac"
+)
+
+// Principal is the authenticated caller of a request, whatever mechanism
+// authenticated it.
+type Principal struct {
+	Kind       string
+	ID         string
+	Scopes     []Scope
+	WalletIDs  []uuid.UUID
+	AllWallets bool
+}
+
+// HasScope reports whether the principal was granted scope. Admin grants every scope.
+func (p Principal) HasScope(scope Scope) bool {
+	for _, s := range p.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Principal) AllowsWallet(walletID uuid.UUID) bool {
+	if p.AllWallets {
+		return true
+	}
+	for _, id := range p.WalletIDs {
+		if id == walletID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrincipal_HasScope(t *testing.T) {
+	reader := APIKey{Scopes: []Scope{ScopeWalletRead}}.Principal()
+	admin := APIKey{Scopes: []Scope{ScopeAdmin}}.Principal()
+
+	assert.True(t, reader.HasScope(ScopeWalletRead))
+	assert.False(t, reader.HasScope(ScopeWalletWrite))
+	assert.False(t, reader.HasScope(ScopeAdmin))
+	assert.True(t, admin.HasScope(ScopeWalletWrite))
+	assert.True(t, admin.HasScope(ScopeAdmin))
+}
+
+func TestPrincipal_AllowsWallet(t *testing.T) {
+	allowed := uuid.New()
+	restricted := APIKey{WalletIDs: []uuid.UUID{allowed}}.Principal()
+
+	assert.True(t, restricted.AllowsWallet(allowed))
+	assert.False(t, restricted.AllowsWallet(uuid.New()))
+	assert.True(t, APIKey{}.Principal().AllowsWallet(uuid.New()))
+	assert.False(t, Principal{Kind: PrincipalJWT}.AllowsWallet(uuid.New()))
+}
+
+func TestScope_Valid(t *testing.T) {
+	assert.True(t, ScopeWalletRead.Valid())
+	assert.True(t, ScopeAdmin.Valid())
+	assert.False(t, Scope("wallet:delete").Valid())
+}
@@ -1,6 +1,13 @@
 package model
 
-import "github.com/google/uuid"
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type OperationType string
 
@@ -14,3 +21,127 @@ type WalletRequest struct {
 	OperationType OperationType `json:"operationType" binding:"required,oneof=DEPOSIT WITHDRAW"`
 	Amount        int64         `json:"amount" binding:"required,gt=0"`
 }
+
+type BalancesRequest struct {
+	WalletIDs []uuid.UUID `json:"walletIds" binding:"required,min=1"`
+}
+
+type BalancesResponse struct {
+	Balances map[uuid.UUID]*WalletBalance `json:"balances"`
+}
+
+// WalletBalance is a balance together with the wallet's credit line. A
+// wallet may go down to MinBalance, which is 0 unless a credit limit is set.
+type WalletBalance struct {
+	Balance         int64 `json:"balance"`
+	MinBalance      int64 `json:"minBalance"`
+	CreditUsed      int64 `json:"creditUsed"`
+	CreditAvailable int64 `json:"creditAvailable"`
+}
+
+func NewWalletBalance(balance, minBalance int64) WalletBalance {
+	b := WalletBalance{Balance: balance, MinBalance: minBalance}
+	if balance < 0 {
+		b.CreditUsed = -balance
+	}
+	// Above the limit only after a limit was lowered below the balance.
+	b.CreditAvailable = max(0, -minBalance-b.CreditUsed)
+	return b
+}
+
+type Wallet struct {
+	ID              uuid.UUID      `json:"walletId"`
+	Balance         int64          `json:"balance"`
+	MinBalance      int64          `json:"minBalance"`
+	CreditUsed      int64          `json:"creditUsed"`
+	CreditAvailable int64          `json:"creditAvailable"`
+	OwnerID         string         `json:"ownerId,omitempty"`
+	ExternalRef     string         `json:"externalRef,omitempty"`
+	DisplayName     string         `json:"displayName,omitempty"`
+	Labels          map[string]any `json:"labels"`
+	Frozen          bool           `json:"frozen"`
+	CreatedAt       time.Time      `json:"createdAt"`
+	UpdatedAt       time.Time      `json:"updatedAt"`
+}
+
+// WalletMetadata replaces all metadata of a wallet. An external reference is
+// unique per owner and needs an owner.
+type WalletMetadata struct {
+	OwnerID     string         `json:"ownerId" binding:"max=128"`
+	ExternalRef string         `json:"externalRef" binding:"max=128"`
+	DisplayName string         `json:"displayName" binding:"max=200"`
+	Labels      map[string]any `json:"labels"`
+}
+
+// PatchWalletMetadataRequest changes only the fields it sets; an empty
+// string clears a field. Labels are merged into the existing ones and a
+// label set to null is removed.
+type PatchWalletMetadataRequest struct {
+	OwnerID     *string        `json:"ownerId" binding:"omitempty,max=128"`
+	ExternalRef *string        `json:"externalRef" binding:"omitempty,max=128"`
+	DisplayName *string        `json:"displayName" binding:"omitempty,max=200"`
+	Labels      map[string]any `json:"labels"`
+}
+
+// Apply returns meta with the changes of the patch.
+func (p PatchWalletMetadataRequest) Apply(meta WalletMetadata) WalletMetadata {
+	if p.OwnerID != nil {
+		meta.OwnerID = *p.OwnerID
+	}
+	if p.ExternalRef != nil {
+		meta.ExternalRef = *p.ExternalRef
+	}
+	if p.DisplayName != nil {
+		meta.DisplayName = *p.DisplayName
+	}
+	if len(p.Labels) > 0 {
+		labels := make(map[string]any, len(meta.Labels)+len(p.Labels))
+		for k, v := range meta.Labels {
+			labels[k] = v
+		}
+		for k, v := range p.Labels {
+			if v == nil {
+				delete(labels, k)
+			} else {
+				labels[k] = v
+			}
+		}
+		meta.Labels = labels
+	}
+	return meta
+}
+
+const (
+	MaxLabels      = 64
+	MaxLabelsBytes = 4096
+)
+
+var labelKeyPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/-]{0,61}[A-Za-z0-9])?$`)
+
+// Validate checks what binding tags cannot: the external reference needs an
+// owner, and label keys, count and encoded size are limited.
+func (m WalletMetadata) Validate() error {
+	if m.ExternalRef != "" && m.OwnerID == "" {
+		return fmt.Errorf("externalRef requires ownerId")
+	}
+	if len(m.Labels) > MaxLabels {
+		return fmt.Errorf("at most %d labels are allowed", MaxLabels)
+	}
+	for k := range m.Labels {
+		if !labelKeyPattern.MatchString(k) {
+			return fmt.Errorf("invalid label key %q: up to 63 letters, digits, '.', '_', '/' or '-', starting and ending with a letter or digit", k)
+		}
+	}
+	encoded, err := json.Marshal(m.Labels)
+	if err != nil {
+		return fmt.Errorf("invalid labels: %w", err)
+	}
+	if len(encoded) > MaxLabelsBytes {
+		return fmt.Errorf("labels must not exceed %d bytes of JSON, got %d", MaxLabelsBytes, len(encoded))
+	}
+	return nil
+}
+
+func (w Wallet) Metadata() WalletMetadata {
+	return WalletMetadata{OwnerID: w.OwnerID, ExternalRef: w.ExternalRef, DisplayName: w.DisplayName, Labels: w.Labels}
+}
@@ -0,0 +1,115 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	WalletStatusActive = "active"
+	WalletStatusFrozen = "frozen"
+
+	WalletSortCreatedAt = "createdAt"
+	WalletSortBalance   = "balance"
+)
+
+// ListWalletsQuery is the query string of GET /wallets. Results are sorted
+// in descending order unless order=asc.
+type ListWalletsQuery struct {
+	Status      string     `form:"status" binding:"omitempty,oneof=active frozen"`
+	OwnerID     string     `form:"ownerId" binding:"max=128"`
+	Currency    string     `form:"currency"`
+	MinBalance  *int64     `form:"minBalance"`
+	MaxBalance  *int64     `form:"maxBalance"`
+	CreatedFrom *time.Time `form:"createdFrom" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedTo   *time.Time `form:"createdTo" time_format:"2006-01-02T15:04:05Z07:00"`
+	Labels      []string   `form:"label" binding:"max=16"`
+	Sort        string     `form:"sort" binding:"omitempty,oneof=createdAt balance"`
+	Order       string     `form:"order" binding:"omitempty,oneof=asc desc"`
+	Cursor      string     `form:"cursor"`
+	Limit       int        `form:"limit" binding:"omitempty,min=1,max=500"`
+}
+
+// LabelSelector matches wallets whose label Key exists or, when Value is
+// set, equals the string Value.
+type LabelSelector struct {
+	Key   string
+	Value *string
+}
+
+// ParseLabelSelector parses "key" or "key=value".
+func ParseLabelSelector(s string) (LabelSelector, error) {
+	key, value, hasValue := strings.Cut(s, "=")
+	if !labelKeyPattern.MatchString(key) {
+		return LabelSelector{}, fmt.Errorf("invalid label selector %q: want key or key=value", s)
+	}
+	sel := LabelSelector{Key: key}
+	if hasValue {
+		sel.Value = &value
+	}
+	return sel, nil
+}
+
+type WalletFilter struct {
+	Status      string
+	OwnerID     string
+	MinBalance  *int64
+	MaxBalance  *int64
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Labels      []LabelSelector
+	// WalletIDs, when not nil, limits the result to these wallets.
+	WalletIDs []uuid.UUID
+
+	Sort  string
+	Desc  bool
+	After *WalletCursor
+	Limit int
+}
+
+// WalletCursor points right after a wallet in a listing. It remembers the
+// order it was made for, so it cannot be replayed against another one.
+type WalletCursor struct {
+	Sort      string    `json:"s"`
+	Desc      bool      `json:"d,omitempty"`
+	Balance   int64     `json:"b,omitempty"`
+	CreatedAt time.Time `json:"c,omitempty"`
+	ID        uuid.UUID `json:"i"`
+}
+
+func NewWalletCursor(w Wallet, sort string, desc bool) WalletCursor {
+	c := WalletCursor{Sort: sort, Desc: desc, ID: w.ID}
+	if sort == WalletSortBalance {
+		c.Balance = w.Balance
+	} else {
+		c.CreatedAt = w.CreatedAt
+	}
+	return c
+}
+
+func (c WalletCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeWalletCursor(s string) (WalletCursor, error) {
+	var c WalletCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil || c.ID == uuid.Nil {
+		return c, fmt.Errorf("invalid cursor")
+	}
+	return c, nil
+}
+
+type ListWalletsResponse struct {
+	Wallets    []Wallet `json:"wallets"`
+	NextCursor string   `json:"nextCursor,omitempty"`
+}
@@ -75,3 +75,34 @@ func TestOperationType_Constants(t *testing.T) {
 	assert.Equal(t, OperationType("DEPOSIT"), Deposit)
 	assert.Equal(t, OperationType("WITHDRAW"), Withdraw)
 }
+
+func TestPatchWalletMetadataRequest_Apply(t *testing.T) {
+	empty := ""
+	name := "Savings"
+	meta := WalletMetadata{
+		OwnerID:     "cust-1",
+		ExternalRef: "main",
+		DisplayName: "Main",
+		Labels:      map[string]any{"tier": "gold", "region": "eu"},
+	}
+
+	patched := PatchWalletMetadataRequest{
+		ExternalRef: &empty,
+		DisplayName: &name,
+		Labels:      map[string]any{"region": nil, "vip": true},
+	}.Apply(meta)
+
+	assert.Equal(t, "cust-1", patched.OwnerID)
+	assert.Equal(t, "", patched.ExternalRef)
+	assert.Equal(t, "Savings", patched.DisplayName)
+	assert.Equal(t, map[string]any{"tier": "gold", "vip": true}, patched.Labels)
+	assert.Equal(t, map[string]any{"tier": "gold", "region": "eu"}, meta.Labels, "the original labels are left untouched")
+}
+
+func TestNewWalletBalance(t *testing.T) {
+	assert.Equal(t, WalletBalance{Balance: 500}, NewWalletBalance(500, 0))
+	assert.Equal(t, WalletBalance{Balance: 500, MinBalance: -1000, CreditAvailable: 1000}, NewWalletBalance(500, -1000))
+	assert.Equal(t, WalletBalance{Balance: -400, MinBalance: -1000, CreditUsed: 400, CreditAvailable: 600}, NewWalletBalance(-400, -1000))
+	// The limit was lowered below a balance already in credit.
+	assert.Equal(t, WalletBalance{Balance: -400, MinBalance: -100, CreditUsed: 400}, NewWalletBalance(-400, -100))
+}
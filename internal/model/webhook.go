@@ -0,0 +1,62 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+type WebhookSubscription struct {
+	ID           uuid.UUID   `json:"id"`
+	URL          string      `json:"url"`
+	EventTypes   []string    `json:"eventTypes"`
+	WalletIDs    []uuid.UUID `json:"walletIds"`
+	Secret       string      `json:"secret,omitempty"`
+	Enabled      bool        `json:"enabled"`
+	FailureCount int         `json:"failureCount"`
+	CreatedAt    time.Time   `json:"createdAt"`
+	UpdatedAt    time.Time   `json:"updatedAt"`
+	DisabledAt   *time.Time  `json:"disabledAt,omitempty"`
+}
+
+type CreateWebhookRequest struct {
+	URL        string      `json:"url" binding:"required,url"`
+	EventTypes []string    `json:"eventTypes"`
+	WalletIDs  []uuid.UUID `json:"walletIds"`
+	Secret     string      `json:"secret" binding:"omitempty,min=16"`
+}
+
+type UpdateWebhookRequest struct {
+	URL        *string      `json:"url" binding:"omitempty,url"`
+	EventTypes *[]string    `json:"eventTypes"`
+	WalletIDs  *[]uuid.UUID `json:"walletIds"`
+	Enabled    *bool        `json:"enabled"`
+}
+
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	SubscriptionID uuid.UUID       `json:"subscriptionId"`
+	EventID        int64           `json:"eventId"`
+	EventType      string          `json:"eventType"`
+	WalletID       uuid.UUID       `json:"walletId"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         DeliveryStatus  `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"nextAttemptAt"`
+	LastStatusCode *int            `json:"lastStatusCode,omitempty"`
+	LastError      *string         `json:"lastError,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	DeliveredAt    *time.Time      `json:"deliveredAt,omitempty"`
+
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
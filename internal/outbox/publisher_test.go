@@ -0,0 +1,69 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+)
+
+func TestWriterPublisher_Publish(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewWriterPublisher(&buf)
+
+	event := model.Event{ID: 42, WalletID: uuid.New(), Type: model.EventBalanceChanged, Payload: json.RawMessage(`{"balance":1}`)}
+	assert.NoError(t, p.Publish(context.Background(), event))
+
+	var got model.Event
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(t, event.ID, got.ID)
+	assert.Equal(t, event.WalletID, got.WalletID)
+	assert.Equal(t, byte('\n'), buf.Bytes()[buf.Len()-1])
+}
+
+func TestHTTPPublisher_Publish(t *testing.T) {
+	var received model.Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "7", r.Header.Get("X-Event-ID"))
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	p := NewHTTPPublisher(server.URL, 0)
+	event := model.Event{ID: 7, WalletID: uuid.New(), Type: model.EventBalanceChanged, Payload: json.RawMessage(`{}`)}
+
+	assert.NoError(t, p.Publish(context.Background(), event))
+	assert.Equal(t, event.WalletID, received.WalletID)
+}
+
+func TestHTTPPublisher_PublishErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	p := NewHTTPPublisher(server.URL, 0)
+	err := p.Publish(context.Background(), model.Event{ID: 1, Payload: json.RawMessage(`{}`)})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "502")
+}
+
+func TestMultiPublisher_PublishesToAllDespiteFailure(t *testing.T) {
+	event := model.Event{ID: 7, WalletID: uuid.New()}
+	primary, webhooks := &MockPublisher{}, &MockPublisher{}
+	primary.On("Publish", mock.Anything, event).Return(errors.New("broker down"))
+	webhooks.On("Publish", mock.Anything, event).Return(nil)
+
+	err := MultiPublisher{primary, webhooks}.Publish(context.Background(), event)
+	assert.EqualError(t, err, "broker down")
+	webhooks.AssertExpectations(t)
+}
@@ -0,0 +1,168 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"go.uber.org/zap"
+)
+
+type Store interface {
+	WithRelayLock(ctx context.Context, fn func(ctx context.Context) error) (bool, error)
+	PendingEvents(ctx context.Context, limit int) ([]model.Event, error)
+	MarkPublished(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, nextAttempt time.Time, cause error) error
+	PrunePublishedEvents(ctx context.Context, olderThan time.Duration) (int64, error)
+}
+
+// RelayConfig tunes the relay. Published events are kept for Retention and
+// pruned every PruneInterval; a zero Retention keeps them forever.
+type RelayConfig struct {
+	PollInterval  time.Duration
+	BatchSize     int
+	MinBackoff    time.Duration
+	MaxBackoff    time.Duration
+	Retention     time.Duration
+	PruneInterval time.Duration
+}
+
+type Relay struct {
+	store     Store
+	publisher Publisher
+	cfg       RelayConfig
+	logger    *zap.Logger
+	now       func() time.Time
+}
+
+func NewRelay(store Store, publisher Publisher, cfg RelayConfig, logger *zap.Logger) *Relay {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = time.Second
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = cfg.MinBackoff
+	}
+	if cfg.PruneInterval <= 0 {
+		cfg.PruneInterval = time.Hour
+	}
+	return &Relay{
+		store:     store,
+		publisher: publisher,
+		cfg:       cfg,
+		logger:    logger,
+		now:       time.Now,
+	}
+}
+
+func (r *Relay) Run(ctx context.Context) {
+	r.logger.Info("outbox relay started", zap.Duration("poll_interval", r.cfg.PollInterval))
+
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+	prune := time.NewTicker(r.cfg.PruneInterval)
+	defer prune.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.logger.Info("outbox relay stopped")
+			return
+		case <-ticker.C:
+			if _, err := r.RelayOnce(ctx); err != nil && ctx.Err() == nil {
+				r.logger.Error("outbox relay iteration failed", zap.Error(err))
+			}
+		case <-prune.C:
+			n, err := r.PruneOnce(ctx)
+			if err != nil && ctx.Err() == nil {
+				r.logger.Error("failed to prune outbox", zap.Error(err))
+				continue
+			}
+			if n > 0 {
+				r.logger.Debug("pruned outbox", zap.Int64("count", n))
+			}
+		}
+	}
+}
+
+// PruneOnce deletes events published longer than Retention ago. It runs
+// under the relay lock, so replicas do not prune concurrently.
+func (r *Relay) PruneOnce(ctx context.Context) (int64, error) {
+	if r.cfg.Retention <= 0 {
+		return 0, nil
+	}
+	var pruned int64
+	_, err := r.store.WithRelayLock(ctx, func(ctx context.Context) error {
+		var err error
+		pruned, err = r.store.PrunePublishedEvents(ctx, r.cfg.Retention)
+		return err
+	})
+	return pruned, err
+}
+
+// RelayOnce delivers one batch of pending events and returns how many were
+// published. A failed event stops delivery of the rest of its wallet's events
+// until its backoff expires, which keeps per-wallet ordering intact. Only one
+// replica relays at a time; the others publish nothing until it lets go.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	published := 0
+	_, err := r.store.WithRelayLock(ctx, func(ctx context.Context) error {
+		var err error
+		published, err = r.relayBatch(ctx)
+		return err
+	})
+	return published, err
+}
+
+func (r *Relay) relayBatch(ctx context.Context) (int, error) {
+	events, err := r.store.PendingEvents(ctx, r.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	blocked := make(map[uuid.UUID]struct{})
+	for _, event := range events {
+		if _, ok := blocked[event.WalletID]; ok {
+			continue
+		}
+
+		if err := r.publisher.Publish(ctx, event); err != nil {
+			blocked[event.WalletID] = struct{}{}
+			next := r.now().Add(r.backoff(event.Attempts))
+			r.logger.Warn("failed to publish outbox event",
+				zap.Int64("event_id", event.ID),
+				zap.String("wallet_id", event.WalletID.String()),
+				zap.Int("attempts", event.Attempts+1),
+				zap.Time("next_attempt", next),
+				zap.Error(err))
+			if err := r.store.MarkFailed(ctx, event.ID, next, err); err != nil {
+				return published, err
+			}
+			continue
+		}
+
+		if err := r.store.MarkPublished(ctx, event.ID); err != nil {
+			return published, err
+		}
+		published++
+	}
+	return published, nil
+}
+
+func (r *Relay) backoff(attempts int) time.Duration {
+	d := r.cfg.MinBackoff
+	for i := 0; i < attempts; i++ {
+		d *= 2
+		if d >= r.cfg.MaxBackoff {
+			return r.cfg.MaxBackoff
+		}
+	}
+	return d
+}
@@ -0,0 +1,125 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"go.uber.org/zap"
+)
+
+type MockStore struct {
+	mock.Mock
+}
+
+func (m *MockStore) WithRelayLock(ctx context.Context, fn func(ctx context.Context) error) (bool, error) {
+	args := m.Called(ctx)
+	if !args.Bool(0) {
+		return false, args.Error(1)
+	}
+	return true, fn(ctx)
+}
+
+func (m *MockStore) PendingEvents(ctx context.Context, limit int) ([]model.Event, error) {
+	args := m.Called(ctx, limit)
+	return args.Get(0).([]model.Event), args.Error(1)
+}
+
+func (m *MockStore) MarkPublished(ctx context.Context, id int64) error {
+	return m.Called(ctx, id).Error(0)
+}
+
+func (m *MockStore) MarkFailed(ctx context.Context, id int64, nextAttempt time.Time, cause error) error {
+	return m.Called(ctx, id, nextAttempt, cause).Error(0)
+}
+
+func (m *MockStore) PrunePublishedEvents(ctx context.Context, olderThan time.Duration) (int64, error) {
+	args := m.Called(ctx, olderThan)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+type MockPublisher struct {
+	mock.Mock
+}
+
+func (m *MockPublisher) Publish(ctx context.Context, event model.Event) error {
+	return m.Called(ctx, event).Error(0)
+}
+
+func TestRelay_RelayOnce(t *testing.T) {
+	walletA := uuid.New()
+	walletB := uuid.New()
+	events := []model.Event{
+		{ID: 1, WalletID: walletA},
+		{ID: 2, WalletID: walletB, Attempts: 2},
+		{ID: 3, WalletID: walletA},
+		{ID: 4, WalletID: walletB},
+	}
+
+	store := &MockStore{}
+	publisher := &MockPublisher{}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	store.On("WithRelayLock", mock.Anything).Return(true, nil)
+	store.On("PendingEvents", mock.Anything, 10).Return(events, nil)
+	publisher.On("Publish", mock.Anything, events[0]).Return(nil)
+	publisher.On("Publish", mock.Anything, events[1]).Return(errors.New("unavailable"))
+	publisher.On("Publish", mock.Anything, events[2]).Return(nil)
+	store.On("MarkPublished", mock.Anything, int64(1)).Return(nil)
+	store.On("MarkPublished", mock.Anything, int64(3)).Return(nil)
+	store.On("MarkFailed", mock.Anything, int64(2), now.Add(4*time.Second), mock.Anything).Return(nil)
+
+	relay := NewRelay(store, publisher, RelayConfig{BatchSize: 10, MinBackoff: time.Second, MaxBackoff: time.Minute}, zap.NewNop())
+	relay.now = func() time.Time { return now }
+
+	published, err := relay.RelayOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, published)
+
+	publisher.AssertNotCalled(t, "Publish", mock.Anything, events[3])
+	store.AssertExpectations(t)
+	publisher.AssertExpectations(t)
+}
+
+func TestRelay_RelayOnceSkipsWhileAnotherReplicaRelays(t *testing.T) {
+	store := &MockStore{}
+	publisher := &MockPublisher{}
+	store.On("WithRelayLock", mock.Anything).Return(false, nil)
+
+	published, err := NewRelay(store, publisher, RelayConfig{}, zap.NewNop()).RelayOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, published)
+	store.AssertNotCalled(t, "PendingEvents", mock.Anything, mock.Anything)
+}
+
+func TestRelay_PruneOnce(t *testing.T) {
+	store := &MockStore{}
+	store.On("WithRelayLock", mock.Anything).Return(true, nil)
+	store.On("PrunePublishedEvents", mock.Anything, 24*time.Hour).Return(int64(7), nil)
+
+	pruned, err := NewRelay(store, &MockPublisher{}, RelayConfig{Retention: 24 * time.Hour}, zap.NewNop()).PruneOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), pruned)
+	store.AssertExpectations(t)
+
+	keep := &MockStore{}
+	pruned, err = NewRelay(keep, &MockPublisher{}, RelayConfig{}, zap.NewNop()).PruneOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, pruned)
+	keep.AssertNotCalled(t, "PrunePublishedEvents", mock.Anything, mock.Anything)
+}
+
+func TestRelay_Backoff(t *testing.T) {
+	relay := NewRelay(&MockStore{}, &MockPublisher{}, RelayConfig{MinBackoff: time.Second, MaxBackoff: 10 * time.Second}, zap.NewNop())
+
+	assert.Equal(t, time.Second, relay.backoff(0))
+	assert.Equal(t, 2*time.Second, relay.backoff(1))
+	assert.Equal(t, 8*time.Second, relay.backoff(3))
+	assert.Equal(t, 10*time.Second, relay.backoff(4))
+	assert.Equal(t, 10*time.Second, relay.backoff(50))
+}
@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+func TestMemoryLimiter_Allow(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	m := NewMemoryLimiter()
+	m.now = func() time.Time { return now }
+	limit := Limit{Rate: 2, Burst: 3}
+	ctx := context.Background()
+
+	for i := 2; i >= 0; i-- {
+		res, err := m.Allow(ctx, "k", limit)
+		assert.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, i, res.Remaining)
+		assert.Equal(t, 3, res.Limit)
+	}
+
+	res, _ := m.Allow(ctx, "k", limit)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+	assert.Equal(t, 1500*time.Millisecond, res.Reset)
+
+	res, _ = m.Allow(ctx, "other", limit)
+	assert.True(t, res.Allowed, "buckets are independent")
+
+	now = now.Add(500 * time.Millisecond)
+	res, _ = m.Allow(ctx, "k", limit)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+
+	now = now.Add(time.Hour)
+	res, _ = m.Allow(ctx, "k", limit)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 2, res.Remaining, "refill is capped at burst")
+}
+
+func TestMemoryLimiter_SweepsFullBuckets(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	m := NewMemoryLimiter()
+	m.now = func() time.Time { return now }
+
+	m.Allow(context.Background(), "slow", Limit{Rate: 0.001, Burst: 10})
+	m.Allow(context.Background(), "fast", Limit{Rate: 100, Burst: 10})
+
+	now = now.Add(2 * sweepInterval)
+	m.Allow(context.Background(), "new", Limit{Rate: 1, Burst: 1})
+
+	assert.Contains(t, m.buckets, "slow")
+	assert.NotContains(t, m.buckets, "fast")
+	assert.Contains(t, m.buckets, "new")
+}
+
+type MockStore struct {
+	mock.Mock
+}
+
+func (m *MockStore) TakeRateLimitToken(ctx context.Context, key string, rate float64, burst int) (float64, bool, error) {
+	args := m.Called(ctx, key, rate, burst)
+	return args.Get(0).(float64), args.Bool(1), args.Error(2)
+}
+
+func (m *MockStore) PruneRateLimitBuckets(ctx context.Context, idle time.Duration) (int64, error) {
+	args := m.Called(ctx, idle)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func TestPostgresLimiter_Allow(t *testing.T) {
+	store := &MockStore{}
+	store.On("TakeRateLimitToken", mock.Anything, "k", 10.0, 20).Return(0.5, false, nil).Once()
+	store.On("TakeRateLimitToken", mock.Anything, "k", 10.0, 20).Return(0.0, false, errors.New("db down")).Once()
+
+	p := NewPostgresLimiter(store, zap.NewNop())
+	res, err := p.Allow(context.Background(), "k", Limit{Rate: 10, Burst: 20})
+	assert.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 50*time.Millisecond, res.RetryAfter)
+
+	_, err = p.Allow(context.Background(), "k", Limit{Rate: 10, Burst: 20})
+	assert.Error(t, err)
+}
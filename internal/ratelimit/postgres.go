@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type Store interface {
+	TakeRateLimitToken(ctx context.Context, key string, rate float64, burst int) (tokens float64, allowed bool, err error)
+	PruneRateLimitBuckets(ctx context.Context, idle time.Duration) (int64, error)
+}
+
+// PostgresLimiter keeps buckets in Postgres so all replicas share the same
+// limits. Each check is a single upsert.
+type PostgresLimiter struct {
+	store  Store
+	logger *zap.Logger
+}
+
+func NewPostgresLimiter(store Store, logger *zap.Logger) *PostgresLimiter {
+	return &PostgresLimiter{store: store, logger: logger}
+}
+
+func (p *PostgresLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	tokens, allowed, err := p.store.TakeRateLimitToken(ctx, key, limit.Rate, limit.Burst)
+	if err != nil {
+		return Result{}, err
+	}
+	return newResult(limit, tokens, allowed), nil
+}
+
+// Run periodically deletes buckets idle for longer than idle, which should
+// be at least the longest Limit.FillTime in use.
+func (p *PostgresLimiter) Run(ctx context.Context, interval, idle time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := p.store.PruneRateLimitBuckets(ctx, idle)
+			if err != nil {
+				p.logger.Error("failed to prune rate limit buckets", zap.Error(err))
+				continue
+			}
+			if n > 0 {
+				p.logger.Debug("pruned rate limit buckets", zap.Int64("count", n))
+			}
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/yokitheyo/go_wallet_test/internal/logctx"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+)
+
+func (r *Repo) FreezeWallet(ctx context.Context, walletID uuid.UUID, reason string) error {
+	res, err := r.exec(ctx,
+		`UPDATE wallets SET frozen_at = COALESCE(frozen_at, now()), frozen_reason = $2 WHERE wallet_id = $1`,
+		walletID, reason)
+	if err != nil {
+		return fmt.Errorf("failed to freeze wallet: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *Repo) UnfreezeWallet(ctx context.Context, walletID uuid.UUID) error {
+	res, err := r.exec(ctx,
+		`UPDATE wallets SET frozen_at = NULL, frozen_reason = NULL WHERE wallet_id = $1`, walletID)
+	if err != nil {
+		return fmt.Errorf("failed to unfreeze wallet: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetMinBalance sets how far below zero a wallet may go and records the
+// change in wallet_min_balance_changes. Lowering the limit below the current
+// balance is allowed; the wallet then only accepts deposits until it is back
+// above the limit.
+func (r *Repo) SetMinBalance(ctx context.Context, walletID uuid.UUID, minBalance int64, reason string) (model.WalletBalance, error) {
+	var b model.WalletBalance
+	err := r.guard(func() error {
+		var err error
+		b, err = r.setMinBalance(ctx, walletID, minBalance, reason)
+		return err
+	})
+	return b, err
+}
+
+func (r *Repo) setMinBalance(ctx context.Context, walletID uuid.UUID, minBalance int64, reason string) (model.WalletBalance, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.WalletBalance{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var balance, old int64
+	err = tx.QueryRowContext(ctx,
+		`SELECT balance, min_balance FROM wallets WHERE wallet_id = $1 FOR UPDATE`, walletID).Scan(&balance, &old)
+	if err == sql.ErrNoRows {
+		return model.WalletBalance{}, ErrNotFound
+	}
+	if err != nil {
+		return model.WalletBalance{}, fmt.Errorf("failed to get wallet: %w", err)
+	}
+
+	if old != minBalance {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE wallets SET min_balance = $2 WHERE wallet_id = $1`, walletID, minBalance); err != nil {
+			return model.WalletBalance{}, fmt.Errorf("failed to set min balance: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO wallet_min_balance_changes(wallet_id, old_min_balance, new_min_balance, reason, request_id)
+			VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''))
+		`, walletID, old, minBalance, reason, logctx.RequestID(ctx)); err != nil {
+			return model.WalletBalance{}, fmt.Errorf("failed to record min balance change: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return model.WalletBalance{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return model.NewWalletBalance(balance, minBalance), nil
+}
+
+// MinBalanceChanges returns the credit limit history of a wallet, newest
+// first.
+func (r *Repo) MinBalanceChanges(ctx context.Context, walletID uuid.UUID, limit int) ([]model.MinBalanceChange, error) {
+	rows, err := r.query(ctx, `
+		SELECT id, wallet_id, old_min_balance, new_min_balance, COALESCE(reason, ''), COALESCE(request_id, ''), changed_at
+		FROM wallet_min_balance_changes
+		WHERE wallet_id = $1
+		ORDER BY id DESC
+		LIMIT $2
+	`, walletID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get min balance changes: %w", err)
+	}
+	defer rows.Close()
+
+	changes := []model.MinBalanceChange{}
+	for rows.Next() {
+		var ch model.MinBalanceChange
+		if err := rows.Scan(&ch.ID, &ch.WalletID, &ch.OldMinBalance, &ch.NewMinBalance, &ch.Reason, &ch.RequestID, &ch.ChangedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan min balance change: %w", err)
+		}
+		changes = append(changes, ch)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate min balance changes: %w", err)
+	}
+	return changes, nil
+}
+
+// Reconcile compares each wallet's balance with the balance_after of its
+// latest ledger entry and returns the wallets that disagree. Wallets without
+// ledger entries predate the ledger and are skipped. A nil walletID checks
+// every wallet. Nothing is corrected automatically.
+func (r *Repo) Reconcile(ctx context.Context, walletID *uuid.UUID) ([]model.ReconciliationMismatch, error) {
+	rows, err := r.query(ctx, `
+		SELECT w.wallet_id, w.balance, l.balance_after, l.id
+		FROM wallets w
+		JOIN LATERAL (
+			SELECT id, balance_after FROM ledger_entries
+			WHERE wallet_id = w.wallet_id
+			ORDER BY id DESC
+			LIMIT 1
+		) l ON true
+		WHERE w.balance <> l.balance_after AND ($1::uuid IS NULL OR w.wallet_id = $1)
+		ORDER BY w.wallet_id
+	`, walletID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reconcile wallets: %w", err)
+	}
+	defer rows.Close()
+
+	mismatches := []model.ReconciliationMismatch{}
+	for rows.Next() {
+		var m model.ReconciliationMismatch
+		if err := rows.Scan(&m.WalletID, &m.Balance, &m.LedgerBalance, &m.LedgerEntryID); err != nil {
+			return nil, fmt.Errorf("failed to scan reconciliation row: %w", err)
+		}
+		mismatches = append(mismatches, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate reconciliation rows: %w", err)
+	}
+	return mismatches, nil
+}
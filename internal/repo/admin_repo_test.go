@@ -0,0 +1,114 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/yokitheyo/go_wallet_test/internal/logctx"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+)
+
+func TestRepo_FreezeWallet(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+
+	id := uuid.New()
+	mock.ExpectExec("UPDATE wallets SET frozen_at = COALESCE\\(frozen_at, now\\(\\)\\), frozen_reason = \\$2 WHERE wallet_id = \\$1").
+		WithArgs(id, "chargeback").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE wallets SET frozen_at = NULL").
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, repo.FreezeWallet(context.Background(), id, "chargeback"))
+	assert.ErrorIs(t, repo.UnfreezeWallet(context.Background(), id), ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_SetMinBalance(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+
+	id := uuid.New()
+	ctx := logctx.WithRequestID(context.Background(), "req-7")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT balance, min_balance FROM wallets WHERE wallet_id = \\$1 FOR UPDATE").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"balance", "min_balance"}).AddRow(int64(100), int64(0)))
+	mock.ExpectExec("UPDATE wallets SET min_balance = \\$2").
+		WithArgs(id, int64(-1000)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO wallet_min_balance_changes").
+		WithArgs(id, int64(0), int64(-1000), "contract 17", "req-7").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT balance, min_balance FROM wallets").
+		WithArgs(id).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	bal, err := repo.SetMinBalance(ctx, id, -1000, "contract 17")
+	assert.NoError(t, err)
+	assert.Equal(t, model.WalletBalance{Balance: 100, MinBalance: -1000, CreditAvailable: 1000}, bal)
+
+	_, err = repo.SetMinBalance(ctx, id, -1000, "")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_ChangeBalance_FrozenWallet(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+
+	id := uuid.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO wallets(.+) WHERE wallets.frozen_at IS NULL").
+		WithArgs(id, int64(10)).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE wallets (.+) AND frozen_at IS NULL").
+		WithArgs(int64(10), id).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT frozen_at IS NOT NULL FROM wallets").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"frozen"}).AddRow(true))
+	mock.ExpectRollback()
+
+	_, err := repo.ChangeBalance(context.Background(), model.WalletRequest{WalletID: id, OperationType: model.Deposit, Amount: 10})
+	assert.ErrorIs(t, err, ErrWalletFrozen)
+	_, err = repo.ChangeBalance(context.Background(), model.WalletRequest{WalletID: id, OperationType: model.Withdraw, Amount: 10})
+	assert.ErrorIs(t, err, ErrWalletFrozen)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_Reconcile(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+
+	id := uuid.New()
+	mock.ExpectQuery("SELECT w.wallet_id, w.balance, l.balance_after, l.id FROM wallets w JOIN LATERAL").
+		WithArgs(nil).
+		WillReturnRows(sqlmock.NewRows([]string{"wallet_id", "balance", "balance_after", "id"}).
+			AddRow(id.String(), int64(100), int64(90), int64(7)))
+	mock.ExpectQuery("SELECT w.wallet_id").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"wallet_id", "balance", "balance_after", "id"}))
+
+	mismatches, err := repo.Reconcile(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.ReconciliationMismatch{{WalletID: id, Balance: 100, LedgerBalance: 90, LedgerEntryID: 7}}, mismatches)
+
+	mismatches, err = repo.Reconcile(context.Background(), &id)
+	assert.NoError(t, err)
+	assert.Empty(t, mismatches)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
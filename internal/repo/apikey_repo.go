@@ -0,0 +1,140 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+)
+
+const apiKeyColumns = `id, name, prefix, scopes, wallet_ids, created_at, rotated_at, revoked_at`
+
+func scanAPIKey(row rowScanner) (model.APIKey, error) {
+	var (
+		key       model.APIKey
+		scopes    []string
+		walletIDs []string
+	)
+	err := row.Scan(&key.ID, &key.Name, &key.Prefix, pq.Array(&scopes), pq.Array(&walletIDs),
+		&key.CreatedAt, &key.RotatedAt, &key.RevokedAt)
+	if err != nil {
+		return key, err
+	}
+	key.Scopes = make([]model.Scope, len(scopes))
+	for i, s := range scopes {
+		key.Scopes[i] = model.Scope(s)
+	}
+	key.WalletIDs, err = parseUUIDs(walletIDs)
+	return key, err
+}
+
+func scopeArray(scopes []model.Scope) any {
+	strs := make([]string, len(scopes))
+	for i, s := range scopes {
+		strs[i] = string(s)
+	}
+	return pq.Array(strs)
+}
+
+func (r *Repo) CreateAPIKey(ctx context.Context, key model.APIKey, hash string) (model.APIKey, error) {
+	var created model.APIKey
+	err := r.queryRow(ctx, func(row *sql.Row) error {
+		var err error
+		created, err = scanAPIKey(row)
+		return err
+	}, `
+		INSERT INTO api_keys(id, name, prefix, key_hash, scopes, wallet_ids)
+		VALUES ($1, $2, $3, $4, $5, $6::uuid[])
+		RETURNING `+apiKeyColumns,
+		key.ID, key.Name, key.Prefix, hash, scopeArray(key.Scopes), uuidArray(key.WalletIDs))
+	if err != nil {
+		return created, fmt.Errorf("failed to create api key: %w", err)
+	}
+	return created, nil
+}
+
+func (r *Repo) GetAPIKeyByHash(ctx context.Context, hash string) (model.APIKey, error) {
+	var key model.APIKey
+	err := r.guard(func() error {
+		var err error
+		row := r.db.QueryRowContext(ctx,
+			`SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL`, hash)
+		key, err = scanAPIKey(row)
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return key, ErrNotFound
+	}
+	if err != nil {
+		return key, fmt.Errorf("failed to get api key: %w", err)
+	}
+	return key, nil
+}
+
+func (r *Repo) ListAPIKeys(ctx context.Context) ([]model.APIKey, error) {
+	rows, err := r.query(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY created_at`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []model.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate api keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (r *Repo) RotateAPIKey(ctx context.Context, id uuid.UUID, hash, prefix string) (model.APIKey, error) {
+	var key model.APIKey
+	err := r.queryRow(ctx, func(row *sql.Row) error {
+		var err error
+		key, err = scanAPIKey(row)
+		return err
+	}, `
+		UPDATE api_keys
+		SET key_hash = $2, prefix = $3, rotated_at = now()
+		WHERE id = $1 AND revoked_at IS NULL
+		RETURNING `+apiKeyColumns,
+		id, hash, prefix)
+	if err == sql.ErrNoRows {
+		return key, ErrNotFound
+	}
+	if err != nil {
+		return key, fmt.Errorf("failed to rotate api key: %w", err)
+	}
+	return key, nil
+}
+
+func (r *Repo) RevokeAPIKey(ctx context.Context, id uuid.UUID) error {
+	res, err := r.exec(ctx,
+		`UPDATE api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *Repo) HasActiveAdminKey(ctx context.Context) (bool, error) {
+	var exists bool
+	err := r.queryRow(ctx, func(row *sql.Row) error { return row.Scan(&exists) },
+		`SELECT EXISTS (SELECT 1 FROM api_keys WHERE revoked_at IS NULL AND $1 = ANY(scopes))`,
+		string(model.ScopeAdmin))
+	if err != nil {
+		return false, fmt.Errorf("failed to check admin keys: %w", err)
+	}
+	return exists, nil
+}
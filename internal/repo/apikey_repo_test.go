@@ -0,0 +1,76 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+)
+
+func TestRepo_GetAPIKeyByHash(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+
+	id := uuid.New()
+	walletID := uuid.New()
+
+	t.Run("active key", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "name", "prefix", "scopes", "wallet_ids", "created_at", "rotated_at", "revoked_at"}).
+			AddRow(id.String(), "ops", "wk_abcdef12", "{wallet:read,wallet:write}", "{"+walletID.String()+"}", time.Now(), nil, nil)
+		mock.ExpectQuery("SELECT (.+) FROM api_keys WHERE key_hash = \\$1 AND revoked_at IS NULL").
+			WithArgs("hash").
+			WillReturnRows(rows)
+
+		key, err := repo.GetAPIKeyByHash(context.Background(), "hash")
+		assert.NoError(t, err)
+		assert.Equal(t, []model.Scope{model.ScopeWalletRead, model.ScopeWalletWrite}, key.Scopes)
+		assert.Equal(t, []uuid.UUID{walletID}, key.WalletIDs)
+	})
+
+	t.Run("unknown or revoked key", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM api_keys").
+			WithArgs("missing").
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.GetAPIKeyByHash(context.Background(), "missing")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_RevokeAPIKey(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+
+	id := uuid.New()
+	mock.ExpectExec("UPDATE api_keys SET revoked_at = now\\(\\)").
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE api_keys SET revoked_at = now\\(\\)").
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, repo.RevokeAPIKey(context.Background(), id))
+	assert.ErrorIs(t, repo.RevokeAPIKey(context.Background(), id), ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_HasActiveAdminKey(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs("admin").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	exists, err := repo.HasActiveAdminKey(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,259 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// ErrUnavailable is matched by errors.Is for every *UnavailableError.
+var ErrUnavailable = errors.New("database unavailable")
+
+// UnavailableError is returned without touching the database while the
+// circuit breaker is open.
+type UnavailableError struct {
+	RetryAfter time.Duration
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("database unavailable, retry after %s", e.RetryAfter.Round(time.Second))
+}
+
+func (e *UnavailableError) Is(target error) bool {
+	return target == ErrUnavailable
+}
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerHalfOpen
+	BreakerOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerHalfOpen:
+		return "half-open"
+	case BreakerOpen:
+		return "open"
+	default:
+		return "closed"
+	}
+}
+
+// breaker opens after threshold consecutive connection failures. While open,
+// calls fail with *UnavailableError; after openTimeout a single call is let
+// through, and its outcome closes or reopens the breaker. A nil breaker or a
+// threshold of 0 lets everything through.
+type breaker struct {
+	threshold   int
+	openTimeout time.Duration
+	logger      *zap.Logger
+	now         func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+}
+
+func newBreaker(threshold int, openTimeout time.Duration, logger *zap.Logger) *breaker {
+	return &breaker{threshold: threshold, openTimeout: openTimeout, logger: logger, now: time.Now}
+}
+
+func (b *breaker) allow() error {
+	if b == nil || b.threshold <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		elapsed := b.now().Sub(b.openedAt)
+		if elapsed < b.openTimeout {
+			return &UnavailableError{RetryAfter: b.openTimeout - elapsed}
+		}
+		b.state = BreakerHalfOpen
+		return nil
+	case BreakerHalfOpen:
+		// A probe is in flight; everyone else waits for its verdict.
+		return &UnavailableError{RetryAfter: b.openTimeout}
+	}
+	return nil
+}
+
+// open reports the error allow would return without changing state, so a
+// caller can fail fast and leave the probe to whoever calls allow next.
+func (b *breaker) open() error {
+	if b == nil || b.threshold <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if elapsed := b.now().Sub(b.openedAt); elapsed < b.openTimeout {
+			return &UnavailableError{RetryAfter: b.openTimeout - elapsed}
+		}
+	case BreakerHalfOpen:
+		return &UnavailableError{RetryAfter: b.openTimeout}
+	}
+	return nil
+}
+
+func (b *breaker) record(err error) {
+	if b == nil || b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case isConnectionError(err):
+		b.failures++
+		if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+			if b.state != BreakerOpen {
+				b.logger.Error("database circuit breaker opened",
+					zap.Int("failures", b.failures), zap.Duration("open_timeout", b.openTimeout), zap.Error(err))
+			}
+			b.state = BreakerOpen
+			b.openedAt = b.now()
+		}
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		// A cancelled call or a client deadline says nothing about the
+		// database. A probe that ends this way hands the next call the
+		// chance to probe instead.
+		if b.state == BreakerHalfOpen {
+			b.state = BreakerOpen
+		}
+	default:
+		if b.state != BreakerClosed {
+			b.logger.Info("database circuit breaker closed")
+		}
+		b.state = BreakerClosed
+		b.failures = 0
+	}
+}
+
+func (b *breaker) State() BreakerState {
+	if b == nil {
+		return BreakerClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// isConnectionError reports whether err means the database could not be
+// reached, as opposed to a query that the database rejected. A context
+// deadline is the caller's budget running out, not a driver or network
+// failure, and does not count even though it satisfies net.Error.
+func isConnectionError(err error) bool {
+	if err == nil || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "57P01", "57P02", "57P03": // admin_shutdown, crash_shutdown, cannot_connect_now
+			return true
+		}
+		// connection_exception, insufficient_resources
+		return pqErr.Code.Class() == "08" || pqErr.Code.Class() == "53"
+	}
+	return false
+}
+
+// guard runs fn against the primary unless the breaker is open, and feeds
+// the outcome back to the breaker.
+func (r *Repo) guard(fn func() error) error {
+	if err := r.breaker.allow(); err != nil {
+		return err
+	}
+	err := fn()
+	r.breaker.record(err)
+	return err
+}
+
+// exec, query and queryRow run a single statement on the primary through
+// guard. queryRow hands the row to scan so that errors from Scan, where
+// QueryRow reports them, reach the breaker too.
+func (r *Repo) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	var res sql.Result
+	err := r.guard(func() error {
+		var err error
+		res, err = r.db.ExecContext(ctx, query, args...)
+		return err
+	})
+	return res, err
+}
+
+func (r *Repo) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	var rows *sql.Rows
+	err := r.guard(func() error {
+		var err error
+		rows, err = r.db.QueryContext(ctx, query, args...)
+		return err
+	})
+	return rows, err
+}
+
+func (r *Repo) queryRow(ctx context.Context, scan func(row *sql.Row) error, query string, args ...any) error {
+	return r.guard(func() error { return scan(r.db.QueryRowContext(ctx, query, args...)) })
+}
+
+// BreakerState reports the state of the circuit breaker around the primary.
+func (r *Repo) BreakerState() BreakerState {
+	return r.breaker.State()
+}
+
+// connect pings db until it answers or timeout passes, backing off between
+// attempts, so the service can start before the database is up.
+func connect(ctx context.Context, db *sql.DB, timeout time.Duration, logger *zap.Logger) error {
+	if timeout <= 0 {
+		if err := db.PingContext(ctx); err != nil {
+			return fmt.Errorf("failed to ping db: %w", err)
+		}
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	backoff := 250 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("failed to ping db after %d attempts: %w", attempt, err)
+		}
+		logger.Warn("database not reachable yet, retrying",
+			zap.Int("attempt", attempt), zap.Duration("backoff", backoff), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to ping db after %d attempts: %w", attempt, err)
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, 5*time.Second)
+	}
+}
@@ -0,0 +1,196 @@
+package repo
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"go.uber.org/zap"
+)
+
+func TestBreaker_OpensAndRecovers(t *testing.T) {
+	now := time.Now()
+	b := newBreaker(2, 10*time.Second, zap.NewNop())
+	b.now = func() time.Time { return now }
+
+	require.NoError(t, b.allow())
+	b.record(driver.ErrBadConn)
+	require.NoError(t, b.allow())
+	b.record(errors.New("insufficient balance"))
+	assert.Equal(t, BreakerClosed, b.State(), "query errors reset the count")
+
+	b.record(driver.ErrBadConn)
+	b.record(driver.ErrBadConn)
+	assert.Equal(t, BreakerOpen, b.State())
+
+	now = now.Add(4 * time.Second)
+	err := b.allow()
+	var unavailable *UnavailableError
+	require.ErrorAs(t, err, &unavailable)
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.Equal(t, 6*time.Second, unavailable.RetryAfter)
+
+	now = now.Add(6 * time.Second)
+	require.NoError(t, b.allow(), "the first call after open_timeout probes")
+	assert.Equal(t, BreakerHalfOpen, b.State())
+	assert.ErrorIs(t, b.allow(), ErrUnavailable, "only one probe at a time")
+
+	b.record(driver.ErrBadConn)
+	assert.Equal(t, BreakerOpen, b.State(), "a failed probe reopens")
+
+	now = now.Add(10 * time.Second)
+	require.NoError(t, b.allow())
+	b.record(nil)
+	assert.Equal(t, BreakerClosed, b.State())
+	assert.NoError(t, b.allow())
+}
+
+func TestBreaker_OpenDoesNotProbe(t *testing.T) {
+	now := time.Now()
+	b := newBreaker(1, 10*time.Second, zap.NewNop())
+	b.now = func() time.Time { return now }
+
+	b.record(driver.ErrBadConn)
+	assert.ErrorIs(t, b.open(), ErrUnavailable)
+
+	now = now.Add(10 * time.Second)
+	assert.NoError(t, b.open())
+	assert.Equal(t, BreakerOpen, b.State(), "peeking leaves the probe slot free")
+	require.NoError(t, b.allow())
+	assert.ErrorIs(t, b.open(), ErrUnavailable, "a probe is in flight")
+}
+
+func TestBreaker_ClientDeadlinesDoNotOpen(t *testing.T) {
+	b := newBreaker(2, 10*time.Second, zap.NewNop())
+
+	b.record(driver.ErrBadConn)
+	for range 5 {
+		b.record(context.DeadlineExceeded)
+	}
+	assert.Equal(t, BreakerClosed, b.State())
+	b.record(driver.ErrBadConn)
+	assert.Equal(t, BreakerOpen, b.State(), "deadlines neither count nor reset connection failures")
+}
+
+func TestRepo_AuthCallsFailFastWhenOpen(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+	repo.breaker = newBreaker(1, time.Minute, zap.NewNop())
+	repo.breaker.record(driver.ErrBadConn)
+
+	_, _, err := repo.TakeRateLimitToken(context.Background(), "ip:10.0.0.1", 1, 5)
+	assert.ErrorIs(t, err, ErrUnavailable)
+	_, err = repo.RememberNonce(context.Background(), "client:nonce", time.Minute)
+	assert.ErrorIs(t, err, ErrUnavailable)
+	_, err = repo.SetWalletMetadata(context.Background(), uuid.New(), model.WalletMetadata{})
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.ErrorIs(t, repo.FreezeWallet(context.Background(), uuid.New(), "fraud"), ErrUnavailable)
+	_, err = repo.ListWebhooks(context.Background())
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.NoError(t, mock.ExpectationsWereMet(), "nothing reaches the database")
+}
+
+func TestBreaker_Disabled(t *testing.T) {
+	b := newBreaker(0, time.Second, zap.NewNop())
+	for range 10 {
+		b.record(driver.ErrBadConn)
+	}
+	assert.NoError(t, b.allow())
+
+	var nilBreaker *breaker
+	assert.NoError(t, nilBreaker.allow())
+}
+
+func TestIsConnectionError(t *testing.T) {
+	assert.True(t, isConnectionError(driver.ErrBadConn))
+	assert.True(t, isConnectionError(&pq.Error{Code: "08006"}))
+	assert.True(t, isConnectionError(&pq.Error{Code: "57P03"}))
+	assert.False(t, isConnectionError(&pq.Error{Code: "23505"}))
+	assert.False(t, isConnectionError(ErrWalletFrozen))
+	assert.False(t, isConnectionError(context.Canceled))
+	assert.False(t, isConnectionError(context.DeadlineExceeded))
+	assert.False(t, isConnectionError(fmt.Errorf("failed to get wallet: %w", context.DeadlineExceeded)))
+	assert.False(t, isConnectionError(nil))
+}
+
+func TestRepo_ChangeBalanceFailsFastWhenOpen(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+	repo.breaker = newBreaker(1, time.Minute, zap.NewNop())
+
+	mock.ExpectBegin().WillReturnError(&pq.Error{Code: "57P03", Message: "the database system is starting up"})
+
+	req := model.WalletRequest{WalletID: uuid.New(), OperationType: model.Deposit, Amount: 100}
+	_, err := repo.ChangeBalance(context.Background(), req)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrUnavailable)
+
+	_, err = repo.ChangeBalance(context.Background(), req)
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_ChangeBalanceProbesAfterOpenTimeout(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+	now := time.Now()
+	repo.breaker = newBreaker(1, time.Minute, zap.NewNop())
+	repo.breaker.now = func() time.Time { return now }
+
+	walletID := uuid.New()
+	req := model.WalletRequest{WalletID: walletID, OperationType: model.Deposit, Amount: 100}
+	mock.ExpectBegin().WillReturnError(&pq.Error{Code: "57P03", Message: "the database system is starting up"})
+	_, err := repo.ChangeBalance(context.Background(), req)
+	require.Error(t, err)
+	require.Equal(t, BreakerOpen, repo.BreakerState())
+
+	now = now.Add(time.Minute)
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO wallets").
+		WithArgs(walletID, req.Amount).
+		WillReturnRows(sqlmock.NewRows([]string{"balance", "min_balance"}).AddRow(int64(100), int64(0)))
+	mock.ExpectQuery("INSERT INTO ledger_entries").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(int64(1), time.Now()))
+	mock.ExpectExec("INSERT INTO outbox").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("SELECT pg_notify").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	_, err = repo.ChangeBalance(context.Background(), req)
+	require.NoError(t, err, "the queued job probes instead of finding the breaker half-open")
+	assert.Equal(t, BreakerClosed, repo.BreakerState())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConnect_RetriesUntilReachable(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	mock.ExpectPing()
+
+	require.NoError(t, connect(context.Background(), db, 5*time.Second, zap.NewNop()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConnect_GivesUpAfterTimeout(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	require.NoError(t, err)
+	defer db.Close()
+
+	for range 5 {
+		mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	}
+
+	err = connect(context.Background(), db, 100*time.Millisecond, zap.NewNop())
+	assert.ErrorContains(t, err, "failed to ping db after 1 attempts")
+}
@@ -0,0 +1,51 @@
+package repo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/yokitheyo/go_wallet_test/internal/config"
+)
+
+var dsnEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// DSN builds a lib/pq connection string from the db section. When db.url is
+// set, parameters present in the URL override the individual fields.
+func DSN(cfg *config.Config) (string, error) {
+	db := cfg.DB
+
+	var parts []string
+	add := func(key, value string) {
+		if value != "" {
+			parts = append(parts, key+"='"+dsnEscaper.Replace(value)+"'")
+		}
+	}
+	if db.URL == "" {
+		add("host", db.Host)
+		add("port", strconv.Itoa(db.Port))
+		add("user", db.User)
+		add("password", db.Pass)
+		add("dbname", db.Name)
+	}
+	add("sslmode", db.SSLMode)
+	add("sslrootcert", db.SSLRootCert)
+	add("sslcert", db.SSLCert)
+	add("sslkey", db.SSLKey)
+	add("application_name", db.ApplicationName)
+	if db.StatementTimeout > 0 {
+		// Unknown keys are sent to the server as run-time parameters.
+		add("statement_timeout", strconv.FormatInt(db.StatementTimeout.Milliseconds(), 10))
+	}
+
+	if db.URL != "" {
+		fromURL, err := pq.ParseURL(db.URL)
+		if err != nil {
+			return "", fmt.Errorf("invalid db.url: %w", err)
+		}
+		// lib/pq keeps the last value of a repeated key.
+		parts = append(parts, fromURL)
+	}
+	return strings.Join(parts, " "), nil
+}
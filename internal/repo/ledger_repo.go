@@ -0,0 +1,81 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+)
+
+const ledgerColumns = `id, wallet_id, operation_type, amount, balance_after, COALESCE(request_id, ''), transfer_id, created_at`
+
+// notifyBalanceChanged queues a NOTIFY that Postgres delivers to every
+// listening instance once the surrounding transaction commits.
+func notifyBalanceChanged(ctx context.Context, tx *sql.Tx, entry model.LedgerEntry) error {
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ledger entry: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `SELECT pg_notify($1, $2)`, BalanceChannel, string(payload)); err != nil {
+		return fmt.Errorf("failed to notify balance change: %w", err)
+	}
+	return nil
+}
+
+func (r *Repo) LedgerSince(ctx context.Context, walletID uuid.UUID, afterID int64, limit int) ([]model.LedgerEntry, error) {
+	return r.queryLedger(ctx, `
+		SELECT `+ledgerColumns+`
+		FROM ledger_entries
+		WHERE wallet_id = $1 AND id > $2
+		ORDER BY id
+		LIMIT $3
+	`, walletID, afterID, limit)
+}
+
+func (r *Repo) LedgerAfter(ctx context.Context, afterID int64, limit int) ([]model.LedgerEntry, error) {
+	return r.queryLedger(ctx, `
+		SELECT `+ledgerColumns+`
+		FROM ledger_entries
+		WHERE id > $1
+		ORDER BY id
+		LIMIT $2
+	`, afterID, limit)
+}
+
+func (r *Repo) LatestLedgerID(ctx context.Context) (int64, error) {
+	var id int64
+	if err := r.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM ledger_entries`).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to get latest ledger id: %w", err)
+	}
+	return id, nil
+}
+
+func (r *Repo) queryLedger(ctx context.Context, query string, args ...any) ([]model.LedgerEntry, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ledger entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []model.LedgerEntry
+	for rows.Next() {
+		var (
+			e          model.LedgerEntry
+			transferID uuid.NullUUID
+		)
+		if err := rows.Scan(&e.ID, &e.WalletID, &e.OperationType, &e.Amount, &e.BalanceAfter, &e.RequestID, &transferID, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan ledger entry: %w", err)
+		}
+		if transferID.Valid {
+			e.TransferID = &transferID.UUID
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate ledger entries: %w", err)
+	}
+	return entries, nil
+}
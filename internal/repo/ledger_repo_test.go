@@ -0,0 +1,60 @@
+package repo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+)
+
+func TestRepo_LedgerSince(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+
+	walletID, transferID := uuid.New(), uuid.New()
+	rows := sqlmock.NewRows([]string{"id", "wallet_id", "operation_type", "amount", "balance_after", "request_id", "transfer_id", "created_at"}).
+		AddRow(int64(11), walletID.String(), "DEPOSIT", int64(10), int64(110), "", nil, time.Now()).
+		AddRow(int64(12), walletID.String(), "WITHDRAW", int64(5), int64(105), "req-12", transferID.String(), time.Now())
+	mock.ExpectQuery("SELECT (.+) FROM ledger_entries").
+		WithArgs(walletID, int64(10), 100).
+		WillReturnRows(rows)
+
+	entries, err := repo.LedgerSince(context.Background(), walletID, 10, 100)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, model.Withdraw, entries[1].OperationType)
+		assert.Equal(t, int64(105), entries[1].BalanceAfter)
+		assert.Equal(t, "req-12", entries[1].RequestID)
+		assert.Nil(t, entries[0].TransferID)
+		assert.Equal(t, &transferID, entries[1].TransferID)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_LedgerAfterAndLatestID(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+
+	walletID := uuid.New()
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(id\\), 0\\) FROM ledger_entries").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(int64(42)))
+	mock.ExpectQuery("SELECT (.+) FROM ledger_entries WHERE id > \\$1").
+		WithArgs(int64(42), 500).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "wallet_id", "operation_type", "amount", "balance_after", "request_id", "transfer_id", "created_at"}).
+			AddRow(int64(43), walletID.String(), "DEPOSIT", int64(1), int64(1), "", nil, time.Now()))
+
+	latest, err := repo.LatestLedgerID(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), latest)
+
+	entries, err := repo.LedgerAfter(context.Background(), latest, 500)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, walletID, entries[0].WalletID)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
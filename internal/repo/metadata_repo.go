@@ -0,0 +1,173 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+)
+
+const walletColumns = `wallet_id, balance, min_balance, COALESCE(owner_id, ''), COALESCE(external_ref, ''),
+	COALESCE(display_name, ''), labels, frozen_at IS NOT NULL, created_at, updated_at`
+
+func scanWallet(row rowScanner) (model.Wallet, error) {
+	var (
+		w      model.Wallet
+		labels []byte
+	)
+	err := row.Scan(&w.ID, &w.Balance, &w.MinBalance, &w.OwnerID, &w.ExternalRef, &w.DisplayName, &labels,
+		&w.Frozen, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		return w, err
+	}
+	if err := json.Unmarshal(labels, &w.Labels); err != nil {
+		return w, fmt.Errorf("failed to decode labels: %w", err)
+	}
+	if w.Labels == nil {
+		w.Labels = map[string]any{}
+	}
+	credit := model.NewWalletBalance(w.Balance, w.MinBalance)
+	w.CreditUsed, w.CreditAvailable = credit.CreditUsed, credit.CreditAvailable
+	return w, nil
+}
+
+func labelsJSON(labels map[string]any) ([]byte, error) {
+	if labels == nil {
+		labels = map[string]any{}
+	}
+	return json.Marshal(labels)
+}
+
+// metadataError turns a clash on the (owner_id, external_ref) index into
+// ErrConflict.
+func metadataError(meta model.WalletMetadata, err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return fmt.Errorf("%w: owner %q already has a wallet with external reference %q", ErrConflict, meta.OwnerID, meta.ExternalRef)
+	}
+	return fmt.Errorf("failed to update wallet metadata: %w", err)
+}
+
+func (r *Repo) GetWallet(ctx context.Context, walletID uuid.UUID) (model.Wallet, error) {
+	var w model.Wallet
+	err := r.read(ctx, func(db *sql.DB) error {
+		var err error
+		w, err = scanWallet(db.QueryRowContext(ctx, `SELECT `+walletColumns+` FROM wallets WHERE wallet_id = $1`, walletID))
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return w, ErrNotFound
+	}
+	if err != nil {
+		return w, fmt.Errorf("failed to get wallet: %w", err)
+	}
+	return w, nil
+}
+
+func (r *Repo) GetWalletByExternalRef(ctx context.Context, ownerID, externalRef string) (model.Wallet, error) {
+	var w model.Wallet
+	err := r.read(ctx, func(db *sql.DB) error {
+		var err error
+		w, err = scanWallet(db.QueryRowContext(ctx,
+			`SELECT `+walletColumns+` FROM wallets WHERE owner_id = $1 AND external_ref = $2`, ownerID, externalRef))
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return w, ErrNotFound
+	}
+	if err != nil {
+		return w, fmt.Errorf("failed to get wallet: %w", err)
+	}
+	return w, nil
+}
+
+// SetWalletMetadata replaces the metadata of a wallet, creating the wallet
+// with a zero balance if it does not exist yet.
+func (r *Repo) SetWalletMetadata(ctx context.Context, walletID uuid.UUID, meta model.WalletMetadata) (model.Wallet, error) {
+	labels, err := labelsJSON(meta.Labels)
+	if err != nil {
+		return model.Wallet{}, fmt.Errorf("failed to encode labels: %w", err)
+	}
+	var w model.Wallet
+	err = r.queryRow(ctx, func(row *sql.Row) error {
+		var err error
+		w, err = scanWallet(row)
+		return err
+	}, `
+		INSERT INTO wallets(wallet_id, balance, owner_id, external_ref, display_name, labels)
+		VALUES ($1, 0, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, ''), $5)
+		ON CONFLICT (wallet_id) DO UPDATE SET
+			owner_id = EXCLUDED.owner_id,
+			external_ref = EXCLUDED.external_ref,
+			display_name = EXCLUDED.display_name,
+			labels = EXCLUDED.labels,
+			updated_at = now()
+		RETURNING `+walletColumns,
+		walletID, meta.OwnerID, meta.ExternalRef, meta.DisplayName, labels)
+	if err != nil {
+		return w, metadataError(meta, err)
+	}
+	return w, nil
+}
+
+// PatchWalletMetadata applies req to the current metadata of a wallet. The
+// result is validated as a whole, so a patch that pushes the labels over
+// their limits fails with ErrInvalid.
+func (r *Repo) PatchWalletMetadata(ctx context.Context, walletID uuid.UUID, req model.PatchWalletMetadataRequest) (model.Wallet, error) {
+	var w model.Wallet
+	err := r.guard(func() error {
+		var err error
+		w, err = r.patchWalletMetadata(ctx, walletID, req)
+		return err
+	})
+	return w, err
+}
+
+func (r *Repo) patchWalletMetadata(ctx context.Context, walletID uuid.UUID, req model.PatchWalletMetadataRequest) (model.Wallet, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Wallet{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := scanWallet(tx.QueryRowContext(ctx,
+		`SELECT `+walletColumns+` FROM wallets WHERE wallet_id = $1 FOR UPDATE`, walletID))
+	if err == sql.ErrNoRows {
+		return current, ErrNotFound
+	}
+	if err != nil {
+		return current, fmt.Errorf("failed to get wallet: %w", err)
+	}
+
+	meta := req.Apply(current.Metadata())
+	if err := meta.Validate(); err != nil {
+		return current, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	labels, err := labelsJSON(meta.Labels)
+	if err != nil {
+		return current, fmt.Errorf("failed to encode labels: %w", err)
+	}
+
+	w, err := scanWallet(tx.QueryRowContext(ctx, `
+		UPDATE wallets SET
+			owner_id = NULLIF($2, ''),
+			external_ref = NULLIF($3, ''),
+			display_name = NULLIF($4, ''),
+			labels = $5,
+			updated_at = now()
+		WHERE wallet_id = $1
+		RETURNING `+walletColumns,
+		walletID, meta.OwnerID, meta.ExternalRef, meta.DisplayName, labels))
+	if err != nil {
+		return w, metadataError(meta, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return w, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return w, nil
+}
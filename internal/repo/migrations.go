@@ -0,0 +1,125 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+	"github.com/yokitheyo/go_wallet_test/migrations"
+)
+
+var ErrPendingMigrations = errors.New("pending migrations")
+
+// Migrations returns the migrations embedded in the binary, or the ones in
+// dir when it is set.
+func Migrations(dir string) fs.FS {
+	if dir == "" {
+		return migrations.FS
+	}
+	return os.DirFS(dir)
+}
+
+// Migrator applies migrations while holding a Postgres advisory lock, so
+// instances starting at the same time run them one after another.
+type Migrator struct {
+	provider *goose.Provider
+}
+
+func NewMigrator(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migration lock: %w", err)
+	}
+	provider, err := goose.NewProvider(goose.DialectPostgres, db, fsys, goose.WithSessionLocker(locker))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	return &Migrator{provider: provider}, nil
+}
+
+func (m *Migrator) Up(ctx context.Context) ([]*goose.MigrationResult, error) {
+	return m.provider.Up(ctx)
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) (*goose.MigrationResult, error) {
+	return m.provider.Down(ctx)
+}
+
+// Redo rolls back the most recently applied migration and applies it again.
+func (m *Migrator) Redo(ctx context.Context) ([]*goose.MigrationResult, error) {
+	down, err := m.provider.Down(ctx)
+	if err != nil {
+		return nil, err
+	}
+	up, err := m.provider.ApplyVersion(ctx, down.Source.Version, true)
+	if err != nil {
+		return []*goose.MigrationResult{down}, err
+	}
+	return []*goose.MigrationResult{down, up}, nil
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]*goose.MigrationStatus, error) {
+	return m.provider.Status(ctx)
+}
+
+// Version returns the version of the last migration applied to the database
+// and of the latest migration available.
+func (m *Migrator) Version(ctx context.Context) (current, latest int64, err error) {
+	return m.provider.GetVersions(ctx)
+}
+
+// Verify fails with ErrPendingMigrations unless every migration is applied.
+func (m *Migrator) Verify(ctx context.Context) error {
+	pending, err := m.provider.HasPending(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check migrations: %w", err)
+	}
+	if !pending {
+		return nil
+	}
+	current, latest, err := m.provider.GetVersions(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check migrations: %w", err)
+	}
+	return fmt.Errorf("%w: schema version %d, latest migration %d", ErrPendingMigrations, current, latest)
+}
+
+// SchemaVersion returns the version of the last migration applied to the
+// database.
+func (r *Repo) SchemaVersion(ctx context.Context) (int64, error) {
+	v, err := goose.GetDBVersionContext(ctx, r.db)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get schema version: %w", err)
+	}
+	return v, nil
+}
+
+// LatestMigration returns the highest migration version found in fsys.
+func LatestMigration(fsys fs.FS) (int64, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return 0, fmt.Errorf("failed to collect migrations: %w", err)
+	}
+	var latest int64
+	for _, name := range names {
+		v, err := goose.NumericComponent(name)
+		if err != nil {
+			return 0, fmt.Errorf("failed to collect migrations: %w", err)
+		}
+		latest = max(latest, v)
+	}
+	if latest == 0 {
+		return 0, fmt.Errorf("failed to find latest migration: no migrations")
+	}
+	return latest, nil
+}
+
+func (r *Repo) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
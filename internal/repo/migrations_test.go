@@ -0,0 +1,33 @@
+package repo
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatestMigration(t *testing.T) {
+	fsys := fstest.MapFS{
+		"00001_first.sql":  {Data: []byte("-- +goose Up\nSELECT 1;\n")},
+		"00010_tenth.sql":  {Data: []byte("-- +goose Up\nSELECT 1;\n")},
+		"00002_second.sql": {Data: []byte("-- +goose Up\nSELECT 1;\n")},
+		"README.md":        {Data: []byte("not a migration")},
+	}
+	latest, err := LatestMigration(fsys)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), latest)
+
+	_, err = LatestMigration(fstest.MapFS{})
+	assert.Error(t, err)
+}
+
+func TestMigrations_Embedded(t *testing.T) {
+	latest, err := LatestMigration(Migrations(""))
+	require.NoError(t, err)
+	assert.Positive(t, latest)
+
+	_, err = LatestMigration(Migrations(t.TempDir()))
+	assert.Error(t, err, "an empty override dir has no migrations")
+}
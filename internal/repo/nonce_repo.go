@@ -0,0 +1,36 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// RememberNonce stores key until ttl from now and reports false if it is
+// already stored and not expired. An expired row is taken over in place.
+func (r *Repo) RememberNonce(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	var stored bool
+	err := r.queryRow(ctx, func(row *sql.Row) error { return row.Scan(&stored) }, `
+		INSERT INTO signature_nonces AS n (key, expires_at)
+		VALUES ($1, now() + $2 * interval '1 second')
+		ON CONFLICT (key) DO UPDATE SET expires_at = EXCLUDED.expires_at
+		WHERE n.expires_at <= now()
+		RETURNING true`,
+		key, ttl.Seconds())
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to remember nonce: %w", err)
+	}
+	return stored, nil
+}
+
+func (r *Repo) PruneNonces(ctx context.Context) (int64, error) {
+	res, err := r.exec(ctx, `DELETE FROM signature_nonces WHERE expires_at <= now()`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune nonces: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,32 @@
+package repo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepo_RememberNonce(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+
+	query := "INSERT INTO signature_nonces AS n (.+) ON CONFLICT \\(key\\) DO UPDATE SET (.+) WHERE n.expires_at <= now\\(\\) RETURNING true"
+	mock.ExpectQuery(query).
+		WithArgs("processor:n1", 600.0).
+		WillReturnRows(sqlmock.NewRows([]string{"bool"}).AddRow(true))
+	mock.ExpectQuery(query).
+		WithArgs("processor:n1", 600.0).
+		WillReturnRows(sqlmock.NewRows([]string{"bool"}))
+
+	fresh, err := repo.RememberNonce(context.Background(), "processor:n1", 10*time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, fresh)
+
+	fresh, err = repo.RememberNonce(context.Background(), "processor:n1", 10*time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, fresh, "another replica already accepted the nonce")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
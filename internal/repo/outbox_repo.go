@@ -0,0 +1,156 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+)
+
+func insertBalanceChanged(ctx context.Context, tx *sql.Tx, entry model.LedgerEntry) error {
+	payload, err := json.Marshal(model.BalanceChanged{
+		TransactionID: entry.ID,
+		WalletID:      entry.WalletID,
+		OperationType: entry.OperationType,
+		Amount:        entry.Amount,
+		Balance:       entry.BalanceAfter,
+		TransferID:    entry.TransferID,
+		OccurredAt:    entry.CreatedAt.UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO outbox(wallet_id, event_type, payload)
+		VALUES ($1, $2, $3)
+	`, entry.WalletID, model.EventBalanceChanged, payload); err != nil {
+		return fmt.Errorf("failed to write outbox event: %w", err)
+	}
+	return nil
+}
+
+// outboxRelayLock is the advisory lock key of the outbox relay.
+const outboxRelayLock int64 = 0x6f7574626f78 // "outbox"
+
+// outboxPruneBatch caps how many published events one DELETE removes.
+const outboxPruneBatch = 1000
+
+// WithRelayLock runs fn while holding a session advisory lock, so only one
+// replica relays the outbox at a time and events are neither published once
+// per replica nor reordered between relays. The lock lives on a dedicated
+// connection with no open transaction, so publishing a slow batch does not
+// leave a session idle in transaction. It returns false without running fn
+// when another replica holds the lock.
+func (r *Repo) WithRelayLock(ctx context.Context, fn func(ctx context.Context) error) (bool, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get connection for outbox relay lock: %w", err)
+	}
+	defer conn.Close()
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, outboxRelayLock).Scan(&locked); err != nil {
+		return false, fmt.Errorf("failed to take outbox relay lock: %w", err)
+	}
+	if !locked {
+		return false, nil
+	}
+	defer releaseRelayLock(conn)
+	return true, fn(ctx)
+}
+
+// releaseRelayLock unlocks even after ctx is cancelled. If that fails, the
+// connection is discarded instead of going back to the pool, which ends the
+// session and the lock with it.
+func releaseRelayLock(conn *sql.Conn) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, outboxRelayLock); err != nil {
+		conn.Raw(func(any) error { return driver.ErrBadConn })
+	}
+}
+
+// PendingEvents returns undelivered events in id order. Events queued behind an
+// earlier event of the same wallet that is still backing off are skipped, so
+// callers can deliver each wallet's events strictly in order.
+func (r *Repo) PendingEvents(ctx context.Context, limit int) ([]model.Event, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT o.id, o.wallet_id, o.event_type, o.payload, o.created_at, o.attempts
+		FROM outbox o
+		WHERE o.published_at IS NULL
+		  AND o.next_attempt_at <= now()
+		  AND NOT EXISTS (
+			SELECT 1 FROM outbox p
+			WHERE p.wallet_id = o.wallet_id
+			  AND p.published_at IS NULL
+			  AND p.id < o.id
+			  AND p.next_attempt_at > now()
+		  )
+		ORDER BY o.id
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []model.Event
+	for rows.Next() {
+		var e model.Event
+		if err := rows.Scan(&e.ID, &e.WalletID, &e.Type, &e.Payload, &e.CreatedAt, &e.Attempts); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate outbox events: %w", err)
+	}
+	return events, nil
+}
+
+func (r *Repo) MarkPublished(ctx context.Context, id int64) error {
+	if _, err := r.db.ExecContext(ctx,
+		`UPDATE outbox SET published_at = now(), last_error = NULL WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to mark event published: %w", err)
+	}
+	return nil
+}
+
+// PrunePublishedEvents deletes events published more than olderThan ago. It
+// deletes in batches so no single statement holds many row locks.
+func (r *Repo) PrunePublishedEvents(ctx context.Context, olderThan time.Duration) (int64, error) {
+	var total int64
+	for {
+		res, err := r.exec(ctx, `
+			DELETE FROM outbox
+			WHERE id IN (
+				SELECT id FROM outbox
+				WHERE published_at < now() - $1 * interval '1 second'
+				LIMIT $2
+			)`, olderThan.Seconds(), outboxPruneBatch)
+		if err != nil {
+			return total, fmt.Errorf("failed to prune outbox: %w", err)
+		}
+		n, _ := res.RowsAffected()
+		total += n
+		if n < outboxPruneBatch {
+			return total, nil
+		}
+	}
+}
+
+func (r *Repo) MarkFailed(ctx context.Context, id int64, nextAttempt time.Time, cause error) error {
+	if _, err := r.db.ExecContext(ctx, `
+		UPDATE outbox
+		SET attempts = attempts + 1, next_attempt_at = $2, last_error = $3
+		WHERE id = $1
+	`, id, nextAttempt, cause.Error()); err != nil {
+		return fmt.Errorf("failed to mark event failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+)
+
+func TestRepo_PendingEvents(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+
+	walletID := uuid.New()
+	createdAt := time.Now().UTC()
+
+	rows := sqlmock.NewRows([]string{"id", "wallet_id", "event_type", "payload", "created_at", "attempts"}).
+		AddRow(int64(1), walletID.String(), model.EventBalanceChanged, []byte(`{"balance":10}`), createdAt, 0).
+		AddRow(int64(2), walletID.String(), model.EventBalanceChanged, []byte(`{"balance":5}`), createdAt, 2)
+	mock.ExpectQuery("SELECT o.id, o.wallet_id, o.event_type, o.payload, o.created_at, o.attempts FROM outbox o").
+		WithArgs(50).
+		WillReturnRows(rows)
+
+	events, err := repo.PendingEvents(context.Background(), 50)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, int64(1), events[0].ID)
+		assert.Equal(t, walletID, events[0].WalletID)
+		assert.JSONEq(t, `{"balance":10}`, string(events[0].Payload))
+		assert.Equal(t, 2, events[1].Attempts)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_MarkPublishedAndFailed(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	next := time.Now().Add(time.Minute)
+
+	mock.ExpectExec("UPDATE outbox SET published_at = now\\(\\)").
+		WithArgs(int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE outbox SET attempts = attempts \\+ 1").
+		WithArgs(int64(8), next, "boom").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, repo.MarkPublished(ctx, 7))
+	assert.NoError(t, repo.MarkFailed(ctx, 8, next, errors.New("boom")))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_WithRelayLock(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT pg_try_advisory_lock\\(\\$1\\)").
+		WithArgs(outboxRelayLock).
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(true))
+	mock.ExpectExec("SELECT pg_advisory_unlock\\(\\$1\\)").
+		WithArgs(outboxRelayLock).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT pg_try_advisory_lock").
+		WithArgs(outboxRelayLock).
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(false))
+
+	calls := 0
+	fn := func(context.Context) error { calls++; return nil }
+
+	ok, err := repo.WithRelayLock(context.Background(), fn)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = repo.WithRelayLock(context.Background(), fn)
+	assert.NoError(t, err)
+	assert.False(t, ok, "another replica holds the lock")
+	assert.Equal(t, 1, calls)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_WithRelayLockReleasesAfterCancel(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT pg_try_advisory_lock").
+		WithArgs(outboxRelayLock).
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(true))
+	mock.ExpectExec("SELECT pg_advisory_unlock").
+		WithArgs(outboxRelayLock).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ok, err := repo.WithRelayLock(ctx, func(ctx context.Context) error {
+		cancel()
+		return ctx.Err()
+	})
+	assert.True(t, ok)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_PrunePublishedEvents(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+
+	mock.ExpectExec("DELETE FROM outbox WHERE id IN \\( SELECT id FROM outbox WHERE published_at < (.+) LIMIT \\$2 \\)").
+		WithArgs(float64(3600), outboxPruneBatch).
+		WillReturnResult(sqlmock.NewResult(0, outboxPruneBatch))
+	mock.ExpectExec("DELETE FROM outbox").
+		WithArgs(float64(3600), outboxPruneBatch).
+		WillReturnResult(sqlmock.NewResult(0, 12))
+
+	n, err := repo.PrunePublishedEvents(context.Background(), time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(outboxPruneBatch+12), n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,158 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/yokitheyo/go_wallet_test/internal/logctx"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"github.com/yokitheyo/go_wallet_test/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OwnerWallets returns every wallet of an owner, oldest first. Owners hold a
+// handful of wallets, so there is no paging.
+func (r *Repo) OwnerWallets(ctx context.Context, ownerID string) ([]model.Wallet, error) {
+	var wallets []model.Wallet
+	err := r.read(ctx, func(db *sql.DB) error {
+		rows, err := db.QueryContext(ctx,
+			`SELECT `+walletColumns+` FROM wallets WHERE owner_id = $1 ORDER BY created_at, wallet_id`, ownerID)
+		if err != nil {
+			return fmt.Errorf("failed to get owner wallets: %w", err)
+		}
+		defer rows.Close()
+
+		wallets = []model.Wallet{}
+		for rows.Next() {
+			w, err := scanWallet(rows)
+			if err != nil {
+				return fmt.Errorf("failed to scan wallet: %w", err)
+			}
+			wallets = append(wallets, w)
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to iterate owner wallets: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return wallets, nil
+}
+
+// Transfer moves req.Amount between two wallets that both belong to ownerID,
+// in one transaction. Each side gets a ledger entry and a BalanceChanged
+// event carrying the transfer ID. A wallet of another owner is reported as
+// ErrNotFound.
+func (r *Repo) Transfer(ctx context.Context, ownerID string, req model.TransferRequest) (model.Transfer, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "Repo.Transfer", trace.WithAttributes(
+		attribute.String("wallet.owner_id", ownerID),
+		attribute.String("wallet.transfer.from", req.FromWalletID.String()),
+		attribute.String("wallet.transfer.to", req.ToWalletID.String()),
+	))
+	defer span.End()
+
+	var from, to model.LedgerEntry
+	err := r.guard(func() error {
+		var err error
+		from, to, err = r.transferAtomic(ctx, ownerID, req)
+		return err
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return model.Transfer{}, err
+	}
+
+	r.notifyBalanceChange(from)
+	r.notifyBalanceChange(to)
+	return model.Transfer{
+		ID:           *from.TransferID,
+		OwnerID:      ownerID,
+		FromWalletID: req.FromWalletID,
+		ToWalletID:   req.ToWalletID,
+		Amount:       req.Amount,
+		FromBalance:  from.BalanceAfter,
+		ToBalance:    to.BalanceAfter,
+		CreatedAt:    from.CreatedAt,
+	}, nil
+}
+
+func (r *Repo) transferAtomic(ctx context.Context, ownerID string, req model.TransferRequest) (from, to model.LedgerEntry, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return from, to, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock both rows in a fixed order so opposite transfers cannot deadlock.
+	rows, err := tx.QueryContext(ctx, `
+		SELECT wallet_id, balance, min_balance, frozen_at IS NOT NULL
+		FROM wallets
+		WHERE wallet_id = ANY($1::uuid[]) AND owner_id = $2
+		ORDER BY wallet_id
+		FOR UPDATE
+	`, uuidArray([]uuid.UUID{req.FromWalletID, req.ToWalletID}), ownerID)
+	if err != nil {
+		return from, to, fmt.Errorf("failed to lock wallets: %w", err)
+	}
+	balances := make(map[uuid.UUID]model.WalletBalance, 2)
+	frozen := false
+	for rows.Next() {
+		var (
+			id                  uuid.UUID
+			balance, minBalance int64
+			isFrozen            bool
+		)
+		if err := rows.Scan(&id, &balance, &minBalance, &isFrozen); err != nil {
+			rows.Close()
+			return from, to, fmt.Errorf("failed to scan wallet: %w", err)
+		}
+		balances[id] = model.WalletBalance{Balance: balance, MinBalance: minBalance}
+		frozen = frozen || isFrozen
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return from, to, fmt.Errorf("failed to lock wallets: %w", err)
+	}
+
+	if len(balances) != 2 {
+		return from, to, ErrNotFound
+	}
+	if frozen {
+		return from, to, ErrWalletFrozen
+	}
+	if src := balances[req.FromWalletID]; src.Balance-src.MinBalance < req.Amount {
+		return from, to, ErrInsufficientFunds
+	}
+
+	transferID := uuid.New()
+	requestID := logctx.RequestID(ctx)
+	from = model.LedgerEntry{WalletID: req.FromWalletID, OperationType: model.Withdraw, Amount: req.Amount, RequestID: requestID, TransferID: &transferID}
+	to = model.LedgerEntry{WalletID: req.ToWalletID, OperationType: model.Deposit, Amount: req.Amount, RequestID: requestID, TransferID: &transferID}
+
+	for _, entry := range []*model.LedgerEntry{&from, &to} {
+		delta := entry.Amount
+		if entry.OperationType == model.Withdraw {
+			delta = -delta
+		}
+		if err := tx.QueryRowContext(ctx,
+			`UPDATE wallets SET balance = balance + $1 WHERE wallet_id = $2 RETURNING balance`,
+			delta, entry.WalletID).Scan(&entry.BalanceAfter); err != nil {
+			return from, to, fmt.Errorf("failed to update balance: %w", err)
+		}
+		if err := recordEntry(ctx, tx, entry); err != nil {
+			return from, to, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return from, to, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return from, to, nil
+}
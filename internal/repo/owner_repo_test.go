@@ -0,0 +1,114 @@
+package repo
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+)
+
+func TestRepo_Transfer(t *testing.T) {
+	from, to := uuid.New(), uuid.New()
+	req := model.TransferRequest{FromWalletID: from, ToWalletID: to, Amount: 300}
+	lockedRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"wallet_id", "balance", "min_balance", "frozen"}).
+			AddRow(from, int64(1000), int64(-500), false).
+			AddRow(to, int64(50), int64(0), false)
+	}
+
+	t.Run("moves money and records both legs", func(t *testing.T) {
+		db, mock, repo := setupTestDB(t)
+		defer db.Close()
+
+		var notified []model.LedgerEntry
+		repo.OnBalanceChange(func(e model.LedgerEntry) { notified = append(notified, e) })
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT wallet_id, balance, min_balance, frozen_at IS NOT NULL FROM wallets (.+) FOR UPDATE").
+			WithArgs(sqlmock.AnyArg(), "cust-1").
+			WillReturnRows(lockedRows())
+		for _, leg := range []struct {
+			id      uuid.UUID
+			delta   int64
+			op      model.OperationType
+			balance int64
+		}{{from, -300, model.Withdraw, 700}, {to, 300, model.Deposit, 350}} {
+			mock.ExpectQuery("UPDATE wallets SET balance = balance \\+ \\$1").
+				WithArgs(leg.delta, leg.id).
+				WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(leg.balance))
+			mock.ExpectQuery("INSERT INTO ledger_entries").
+				WithArgs(leg.id, leg.op, int64(300), leg.balance, "", sqlmock.AnyArg()).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(int64(1), time.Now()))
+			mock.ExpectExec("INSERT INTO outbox").
+				WithArgs(leg.id, model.EventBalanceChanged, sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectExec("SELECT pg_notify").
+				WithArgs(BalanceChannel, sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+		mock.ExpectCommit()
+
+		transfer, err := repo.Transfer(context.Background(), "cust-1", req)
+		require.NoError(t, err)
+		assert.NotEqual(t, uuid.Nil, transfer.ID)
+		assert.Equal(t, int64(700), transfer.FromBalance)
+		assert.Equal(t, int64(350), transfer.ToBalance)
+		require.Len(t, notified, 2)
+		assert.Equal(t, transfer.ID, *notified[0].TransferID)
+		assert.Equal(t, transfer.ID, *notified[1].TransferID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("wallet of another owner", func(t *testing.T) {
+		db, mock, repo := setupTestDB(t)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT wallet_id, balance").
+			WithArgs(sqlmock.AnyArg(), "cust-1").
+			WillReturnRows(sqlmock.NewRows([]string{"wallet_id", "balance", "min_balance", "frozen"}).AddRow(from, int64(1000), int64(0), false))
+		mock.ExpectRollback()
+
+		_, err := repo.Transfer(context.Background(), "cust-1", req)
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("beyond the credit line", func(t *testing.T) {
+		db, mock, repo := setupTestDB(t)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT wallet_id, balance").
+			WithArgs(sqlmock.AnyArg(), "cust-1").
+			WillReturnRows(lockedRows())
+		mock.ExpectRollback()
+
+		_, err := repo.Transfer(context.Background(), "cust-1", model.TransferRequest{FromWalletID: from, ToWalletID: to, Amount: 1501})
+		assert.ErrorIs(t, err, ErrInsufficientFunds)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("huge amount on a negative balance", func(t *testing.T) {
+		db, mock, repo := setupTestDB(t)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT wallet_id, balance").
+			WithArgs(sqlmock.AnyArg(), "cust-1").
+			WillReturnRows(sqlmock.NewRows([]string{"wallet_id", "balance", "min_balance", "frozen"}).
+				AddRow(from, int64(-200), int64(-500), false).
+				AddRow(to, int64(50), int64(0), false))
+		mock.ExpectRollback()
+
+		_, err := repo.Transfer(context.Background(), "cust-1", model.TransferRequest{FromWalletID: from, ToWalletID: to, Amount: math.MaxInt64})
+		assert.ErrorIs(t, err, ErrInsufficientFunds)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
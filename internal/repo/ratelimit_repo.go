@@ -0,0 +1,44 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// TakeRateLimitToken refills the bucket for key and takes one token from it
+// if at least one is available. All SET expressions see the row as it was
+// before the update, so the refill is computed once per statement.
+func (r *Repo) TakeRateLimitToken(ctx context.Context, key string, rate float64, burst int) (float64, bool, error) {
+	var (
+		tokens  float64
+		allowed bool
+	)
+	err := r.queryRow(ctx, func(row *sql.Row) error { return row.Scan(&tokens, &allowed) }, `
+		INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at)
+		VALUES ($1, $2::double precision - 1, TRUE, now())
+		ON CONFLICT (key) DO UPDATE SET
+			tokens = CASE
+				WHEN LEAST($2::double precision, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at) * $3::double precision) >= 1
+				THEN LEAST($2::double precision, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at) * $3::double precision) - 1
+				ELSE LEAST($2::double precision, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at) * $3::double precision)
+			END,
+			allowed = LEAST($2::double precision, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at) * $3::double precision) >= 1,
+			updated_at = now()
+		RETURNING tokens, allowed`,
+		key, burst, rate)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to take rate limit token: %w", err)
+	}
+	return tokens, allowed, nil
+}
+
+func (r *Repo) PruneRateLimitBuckets(ctx context.Context, idle time.Duration) (int64, error) {
+	res, err := r.exec(ctx,
+		`DELETE FROM rate_limit_buckets WHERE updated_at < now() - $1 * interval '1 second'`, idle.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune rate limit buckets: %w", err)
+	}
+	return res.RowsAffected()
+}
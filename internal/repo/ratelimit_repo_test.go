@@ -0,0 +1,39 @@
+package repo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepo_TakeRateLimitToken(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+
+	mock.ExpectQuery("INSERT INTO rate_limit_buckets AS b (.+) ON CONFLICT \\(key\\) DO UPDATE SET (.+) RETURNING tokens, allowed").
+		WithArgs("ip:10.0.0.1", 20, 10.0).
+		WillReturnRows(sqlmock.NewRows([]string{"tokens", "allowed"}).AddRow(0.4, false))
+
+	tokens, allowed, err := repo.TakeRateLimitToken(context.Background(), "ip:10.0.0.1", 10, 20)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.4, tokens)
+	assert.False(t, allowed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_PruneRateLimitBuckets(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+
+	mock.ExpectExec("DELETE FROM rate_limit_buckets WHERE updated_at < now\\(\\) - \\$1 \\* interval '1 second'").
+		WithArgs(90.0).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	n, err := repo.PruneRateLimitBuckets(context.Background(), 90*time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
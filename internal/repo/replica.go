@@ -0,0 +1,136 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/yokitheyo/go_wallet_test/internal/config"
+	"go.uber.org/zap"
+)
+
+// replicaLagQuery returns how far a replica is behind in seconds. A replica
+// that has replayed everything it received is not lagging even if the last
+// replayed transaction is old, which happens when the primary is idle.
+const replicaLagQuery = `
+	SELECT CASE
+		WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+	END`
+
+type replica struct {
+	name    string
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+type primaryKey struct{}
+
+// WithPrimary makes reads done with ctx go to the primary, so a caller sees
+// its own writes regardless of replication lag.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// UsesPrimary reports whether WithPrimary was applied to ctx.
+func UsesPrimary(ctx context.Context) bool {
+	v, _ := ctx.Value(primaryKey{}).(bool)
+	return v
+}
+
+func openReplicas(cfg *config.Config) ([]*replica, error) {
+	var replicas []*replica
+	for i, url := range cfg.DB.Replicas {
+		replicaCfg := *cfg
+		replicaCfg.DB.URL = url
+		dsn, err := DSN(&replicaCfg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid db.replicas[%d]: %w", i, err)
+		}
+		db, err := openDB(dsn, cfg.DB)
+		if err != nil {
+			return nil, err
+		}
+		rep := &replica{name: config.MaskURL(url), db: db}
+		// An unreachable replica is left out until the monitor sees it
+		// recover; the primary serves its reads meanwhile.
+		rep.healthy.Store(db.Ping() == nil)
+		replicas = append(replicas, rep)
+	}
+	return replicas, nil
+}
+
+// read runs a read-only query on a healthy replica, or on the primary when
+// there is none or ctx asks for it. A replica that fails the query is taken
+// out of rotation and the query is retried on the primary. Reads from the
+// primary go through the circuit breaker.
+func (r *Repo) read(ctx context.Context, query func(db *sql.DB) error) error {
+	if rep := r.pickReplica(ctx); rep != nil {
+		err := query(rep.db)
+		if err == nil || errors.Is(err, sql.ErrNoRows) || ctx.Err() != nil {
+			return err
+		}
+		rep.healthy.Store(false)
+	}
+	return r.guard(func() error { return query(r.db) })
+}
+
+func (r *Repo) pickReplica(ctx context.Context) *replica {
+	if len(r.replicas) == 0 || UsesPrimary(ctx) {
+		return nil
+	}
+	start := r.nextReplica.Add(1)
+	for i := range r.replicas {
+		rep := r.replicas[(start+uint64(i))%uint64(len(r.replicas))]
+		if rep.healthy.Load() {
+			return rep
+		}
+	}
+	return nil
+}
+
+// MonitorReplicas checks every interval that each replica is reachable and
+// no more than maxLag behind, and routes reads away from those that are not.
+func (r *Repo) MonitorReplicas(ctx context.Context, interval, maxLag time.Duration, logger *zap.Logger) {
+	if len(r.replicas) == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, rep := range r.replicas {
+			r.checkReplica(ctx, rep, interval, maxLag, logger)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Repo) checkReplica(ctx context.Context, rep *replica, timeout, maxLag time.Duration, logger *zap.Logger) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var lagSeconds float64
+	err := rep.db.QueryRowContext(ctx, replicaLagQuery).Scan(&lagSeconds)
+	lag := time.Duration(lagSeconds * float64(time.Second))
+	if err == nil && lag > maxLag {
+		err = fmt.Errorf("replication lag %s exceeds %s", lag.Round(time.Millisecond), maxLag)
+	}
+
+	healthy := err == nil
+	if rep.healthy.Swap(healthy) == healthy {
+		return
+	}
+	if healthy {
+		logger.Info("read replica back in rotation", zap.String("replica", rep.name), zap.Duration("lag", lag))
+	} else {
+		logger.Warn("read replica taken out of rotation", zap.String("replica", rep.name), zap.Error(err))
+	}
+}
@@ -0,0 +1,95 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func setupReplica(t *testing.T, repo *Repo) sqlmock.Sqlmock {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	rep := &replica{name: "replica", db: db}
+	rep.healthy.Store(true)
+	repo.replicas = append(repo.replicas, rep)
+	return mock
+}
+
+func TestRepo_ReadsFromReplica(t *testing.T) {
+	db, primary, repo := setupTestDB(t)
+	defer db.Close()
+	replica := setupReplica(t, repo)
+
+	walletID := uuid.New()
+	balanceQuery := "SELECT balance FROM wallets WHERE wallet_id = \\$1"
+	replica.ExpectQuery(balanceQuery).WithArgs(walletID).
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(90))
+	primary.ExpectQuery(balanceQuery).WithArgs(walletID).
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(100))
+
+	bal, err := repo.GetBalance(context.Background(), walletID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(90), bal)
+
+	bal, err = repo.GetBalance(WithPrimary(context.Background()), walletID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), bal, "WithPrimary reads the primary")
+
+	assert.NoError(t, primary.ExpectationsWereMet())
+	assert.NoError(t, replica.ExpectationsWereMet())
+}
+
+func TestRepo_ReplicaFailureFallsBackToPrimary(t *testing.T) {
+	db, primary, repo := setupTestDB(t)
+	defer db.Close()
+	replica := setupReplica(t, repo)
+
+	walletID := uuid.New()
+	balanceQuery := "SELECT balance FROM wallets WHERE wallet_id = \\$1"
+	replica.ExpectQuery(balanceQuery).WithArgs(walletID).WillReturnError(errors.New("connection refused"))
+	primary.ExpectQuery(balanceQuery).WithArgs(walletID).
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(100))
+	primary.ExpectQuery(balanceQuery).WithArgs(walletID).
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(100))
+
+	for i := 0; i < 2; i++ {
+		bal, err := repo.GetBalance(context.Background(), walletID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(100), bal)
+	}
+	assert.False(t, repo.replicas[0].healthy.Load(), "a failed replica leaves the rotation")
+
+	assert.NoError(t, primary.ExpectationsWereMet())
+	assert.NoError(t, replica.ExpectationsWereMet())
+}
+
+func TestRepo_CheckReplicaLag(t *testing.T) {
+	db, _, repo := setupTestDB(t)
+	defer db.Close()
+	replica := setupReplica(t, repo)
+	rep := repo.replicas[0]
+
+	replica.ExpectQuery("SELECT CASE WHEN NOT pg_is_in_recovery\\(\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(12.5))
+	repo.checkReplica(context.Background(), rep, time.Second, 10*time.Second, zap.NewNop())
+	assert.False(t, rep.healthy.Load())
+	assert.Nil(t, repo.pickReplica(context.Background()))
+
+	replica.ExpectQuery("SELECT CASE WHEN NOT pg_is_in_recovery\\(\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(0.2))
+	repo.checkReplica(context.Background(), rep, time.Second, 10*time.Second, zap.NewNop())
+	assert.True(t, rep.healthy.Load())
+	assert.Same(t, rep, repo.pickReplica(context.Background()))
+
+	assert.NoError(t, replica.ExpectationsWereMet())
+}
@@ -0,0 +1,117 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+)
+
+// ListWallets returns up to f.Limit wallets matching f, in keyset order after
+// f.After. Ties on the sort column are broken by wallet_id.
+func (r *Repo) ListWallets(ctx context.Context, f model.WalletFilter) ([]model.Wallet, error) {
+	query, args, err := listWalletsQuery(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var wallets []model.Wallet
+	err = r.read(ctx, func(db *sql.DB) error {
+		rows, err := db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to list wallets: %w", err)
+		}
+		defer rows.Close()
+
+		wallets = make([]model.Wallet, 0, f.Limit)
+		for rows.Next() {
+			w, err := scanWallet(rows)
+			if err != nil {
+				return fmt.Errorf("failed to scan wallet: %w", err)
+			}
+			wallets = append(wallets, w)
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to iterate wallets: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return wallets, nil
+}
+
+func listWalletsQuery(f model.WalletFilter) (string, []any, error) {
+	var (
+		where []string
+		args  []any
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	switch f.Status {
+	case model.WalletStatusActive:
+		where = append(where, "frozen_at IS NULL")
+	case model.WalletStatusFrozen:
+		where = append(where, "frozen_at IS NOT NULL")
+	}
+	if f.OwnerID != "" {
+		where = append(where, "owner_id = "+arg(f.OwnerID))
+	}
+	if f.MinBalance != nil {
+		where = append(where, "balance >= "+arg(*f.MinBalance))
+	}
+	if f.MaxBalance != nil {
+		where = append(where, "balance <= "+arg(*f.MaxBalance))
+	}
+	if f.CreatedFrom != nil {
+		where = append(where, "created_at >= "+arg(*f.CreatedFrom))
+	}
+	if f.CreatedTo != nil {
+		where = append(where, "created_at < "+arg(*f.CreatedTo))
+	}
+	for _, sel := range f.Labels {
+		if sel.Value == nil {
+			where = append(where, "labels ? "+arg(sel.Key))
+			continue
+		}
+		match, err := json.Marshal(map[string]string{sel.Key: *sel.Value})
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to encode label selector: %w", err)
+		}
+		where = append(where, "labels @> "+arg(string(match))+"::jsonb")
+	}
+	if f.WalletIDs != nil {
+		where = append(where, "wallet_id = ANY("+arg(uuidArray(f.WalletIDs))+"::uuid[])")
+	}
+
+	column := "created_at"
+	if f.Sort == model.WalletSortBalance {
+		column = "balance"
+	}
+	direction, cmp := "ASC", ">"
+	if f.Desc {
+		direction, cmp = "DESC", "<"
+	}
+	if f.After != nil {
+		var after any = f.After.CreatedAt
+		if f.Sort == model.WalletSortBalance {
+			after = f.After.Balance
+		}
+		where = append(where, fmt.Sprintf("(%s, wallet_id) %s (%s, %s)", column, cmp, arg(after), arg(f.After.ID)))
+	}
+
+	query := `SELECT ` + walletColumns + ` FROM wallets`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	query += fmt.Sprintf(` ORDER BY %s %s, wallet_id %s LIMIT %s`, column, direction, direction, arg(f.Limit))
+	return query, args, nil
+}
@@ -0,0 +1,62 @@
+package repo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+)
+
+func TestListWalletsQuery(t *testing.T) {
+	minBalance := int64(100)
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	gold := "gold"
+	after := uuid.New()
+
+	query, args, err := listWalletsQuery(model.WalletFilter{
+		Status:      model.WalletStatusFrozen,
+		OwnerID:     "cust-1",
+		MinBalance:  &minBalance,
+		CreatedFrom: &from,
+		Labels:      []model.LabelSelector{{Key: "tier", Value: &gold}, {Key: "vip"}},
+		Sort:        model.WalletSortBalance,
+		Desc:        true,
+		After:       &model.WalletCursor{Sort: model.WalletSortBalance, Desc: true, Balance: 500, ID: after},
+		Limit:       11,
+	})
+	require.NoError(t, err)
+	assert.Contains(t, query, "WHERE frozen_at IS NOT NULL AND owner_id = $1 AND balance >= $2 AND created_at >= $3 "+
+		"AND labels @> $4::jsonb AND labels ? $5 AND (balance, wallet_id) < ($6, $7)")
+	assert.Contains(t, query, "ORDER BY balance DESC, wallet_id DESC LIMIT $8")
+	assert.Equal(t, []any{"cust-1", minBalance, from, `{"tier":"gold"}`, "vip", int64(500), after, 11}, args)
+
+	query, args, err = listWalletsQuery(model.WalletFilter{Sort: model.WalletSortCreatedAt, Limit: 5})
+	require.NoError(t, err)
+	assert.NotContains(t, query, "WHERE")
+	assert.Contains(t, query, "ORDER BY created_at ASC, wallet_id ASC LIMIT $1")
+	assert.Equal(t, []any{5}, args)
+}
+
+func TestRepo_ListWallets(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+
+	id := uuid.New()
+	now := time.Now()
+	mock.ExpectQuery("SELECT (.+) FROM wallets WHERE owner_id = \\$1 ORDER BY created_at DESC").
+		WithArgs("cust-1", 3).
+		WillReturnRows(sqlmock.NewRows([]string{"wallet_id", "balance", "min_balance", "owner_id", "external_ref", "display_name", "labels", "frozen", "created_at", "updated_at"}).
+			AddRow(id, 250, 0, "cust-1", "main", "Main", []byte(`{"tier":"gold"}`), false, now, now))
+
+	wallets, err := repo.ListWallets(context.Background(), model.WalletFilter{OwnerID: "cust-1", Sort: model.WalletSortCreatedAt, Desc: true, Limit: 3})
+	require.NoError(t, err)
+	require.Len(t, wallets, 1)
+	assert.Equal(t, id, wallets[0].ID)
+	assert.Equal(t, "gold", wallets[0].Labels["tier"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
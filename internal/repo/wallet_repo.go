@@ -3,53 +3,136 @@ package repo
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"sync"
-	"time"
+	"sync/atomic"
 
+	"github.com/XSAM/otelsql"
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/yokitheyo/go_wallet_test/internal/config"
+	"github.com/yokitheyo/go_wallet_test/internal/logctx"
 	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"github.com/yokitheyo/go_wallet_test/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 )
 
+var (
+	ErrNotFound          = errors.New("not found")
+	ErrWalletFrozen      = errors.New("wallet is frozen")
+	ErrConflict          = errors.New("conflict")
+	ErrInsufficientFunds = errors.New("insufficient balance")
+	ErrInvalid           = errors.New("invalid")
+)
+
+const BalanceChannel = "wallet_balance_changes"
+
+// DefaultQueueCapacity is used when the queue capacity is not configured.
+const DefaultQueueCapacity = 1000
+
 type Repo struct {
-	db     *sql.DB
-	mu     sync.Mutex
-	queues map[uuid.UUID]chan func()
-	wg     sync.WaitGroup
+	db            *sql.DB
+	replicas      []*replica
+	nextReplica   atomic.Uint64
+	breaker       *breaker
+	mu            sync.Mutex
+	queues        map[uuid.UUID]chan func()
+	queueCapacity int
+	wg            sync.WaitGroup
+
+	hooksMu sync.RWMutex
+	hooks   []func(model.LedgerEntry)
 }
 
-func NewPostgres(cfg *config.Config) (*Repo, error) {
-	dsn := fmt.Sprintf(
-		"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPass, cfg.DBName,
-	)
-	db, err := sql.Open("postgres", dsn)
+// NewPostgres connects to the primary, retrying for up to
+// cfg.DB.ConnectTimeout while it is not reachable yet.
+func NewPostgres(ctx context.Context, cfg *config.Config, logger *zap.Logger) (*Repo, error) {
+	db, err := OpenPrimary(ctx, cfg, logger)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open db: %w", err)
+		return nil, err
 	}
 
-	db.SetMaxOpenConns(200)
-	db.SetMaxIdleConns(50)
-	db.SetConnMaxLifetime(time.Minute * 5)
-
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping db: %w", err)
+	replicas, err := openReplicas(cfg)
+	if err != nil {
+		db.Close()
+		return nil, err
 	}
 
 	return &Repo{
-		db:     db,
-		queues: make(map[uuid.UUID]chan func()),
+		db:            db,
+		replicas:      replicas,
+		breaker:       newBreaker(cfg.DB.Breaker.FailureThreshold, cfg.DB.Breaker.OpenTimeout, logger),
+		queues:        make(map[uuid.UUID]chan func()),
+		queueCapacity: cfg.Queue.Capacity,
 	}, nil
 }
 
+// OpenPrimary opens a connection pool to the primary and waits for up to
+// cfg.DB.ConnectTimeout for it to answer.
+func OpenPrimary(ctx context.Context, cfg *config.Config, logger *zap.Logger) (*sql.DB, error) {
+	dsn, err := DSN(cfg)
+	if err != nil {
+		return nil, err
+	}
+	db, err := openDB(dsn, cfg.DB)
+	if err != nil {
+		return nil, err
+	}
+	if err := connect(ctx, db, cfg.DB.ConnectTimeout, logger); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func openDB(dsn string, cfg config.DBConfig) (*sql.DB, error) {
+	db, err := otelsql.Open("postgres", dsn,
+		otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			OmitConnResetSession: true,
+			OmitRows:             true,
+			// Only trace queries made on behalf of a traced operation, so
+			// background pollers do not emit a root span every tick.
+			SpanFilter: func(ctx context.Context, _ otelsql.Method, _ string, _ []driver.NamedValue) bool {
+				return trace.SpanContextFromContext(ctx).IsValid()
+			},
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open db: %w", err)
+	}
+
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	return db, nil
+}
+
+// QueueCapacity is the number of jobs a wallet queue buffers before
+// ChangeBalance blocks.
+func (r *Repo) QueueCapacity() int {
+	if r.queueCapacity <= 0 {
+		return DefaultQueueCapacity
+	}
+	return r.queueCapacity
+}
+
 func (r *Repo) DB() *sql.DB {
 	return r.db
 }
 
 func (r *Repo) Close() error {
 	r.wg.Wait()
+	for _, rep := range r.replicas {
+		rep.db.Close()
+	}
 	return r.db.Close()
 }
 
@@ -61,7 +144,7 @@ func (r *Repo) getQueue(walletID uuid.UUID) chan func() {
 		return ch
 	}
 
-	ch := make(chan func(), 1000)
+	ch := make(chan func(), r.QueueCapacity())
 	r.queues[walletID] = ch
 
 	r.wg.Add(1)
@@ -75,61 +158,180 @@ func (r *Repo) getQueue(walletID uuid.UUID) chan func() {
 	return ch
 }
 
-func (r *Repo) ChangeBalance(ctx context.Context, req model.WalletRequest) (int64, error) {
+// QueueStats returns the number of jobs waiting in each wallet queue and the
+// number of worker goroutines, one per queue.
+func (r *Repo) QueueStats() (map[uuid.UUID]int, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	depths := make(map[uuid.UUID]int, len(r.queues))
+	for id, ch := range r.queues {
+		depths[id] = len(ch)
+	}
+	return depths, len(r.queues)
+}
+
+func (r *Repo) OnBalanceChange(fn func(model.LedgerEntry)) {
+	r.hooksMu.Lock()
+	defer r.hooksMu.Unlock()
+	r.hooks = append(r.hooks, fn)
+}
+
+func (r *Repo) notifyBalanceChange(entry model.LedgerEntry) {
+	r.hooksMu.RLock()
+	defer r.hooksMu.RUnlock()
+	for _, fn := range r.hooks {
+		fn(entry)
+	}
+}
+
+// ChangeBalance applies a deposit or withdrawal and returns the new balance.
+// A withdrawal may take the balance down to the wallet's minimum balance.
+func (r *Repo) ChangeBalance(ctx context.Context, req model.WalletRequest) (model.WalletBalance, error) {
 	resultChan := make(chan struct {
-		balance int64
-		err     error
+		entry      model.LedgerEntry
+		minBalance int64
+		err        error
 	}, 1)
 
+	ctx, span := tracing.Tracer().Start(ctx, "Repo.ChangeBalance", trace.WithAttributes(
+		attribute.String("wallet.id", req.WalletID.String()),
+		attribute.String("wallet.operation", string(req.OperationType)),
+	))
+	defer span.End()
+
+	// Fail fast during an outage instead of queueing behind jobs that
+	// will time out anyway. The queued job takes the probe slot itself.
+	if err := r.breaker.open(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return model.WalletBalance{}, err
+	}
+
 	q := r.getQueue(req.WalletID)
+	_, wait := tracing.Tracer().Start(ctx, "wallet.queue.wait", trace.WithAttributes(
+		attribute.Int("wallet.queue.depth", len(q)),
+	))
 
 	q <- func() {
-		newBalance, err := r.changeBalanceAtomic(ctx, req)
+		wait.End()
+		var (
+			entry      model.LedgerEntry
+			minBalance int64
+		)
+		err := r.guard(func() error {
+			var err error
+			entry, minBalance, err = r.changeBalanceAtomic(ctx, req)
+			return err
+		})
 		resultChan <- struct {
-			balance int64
-			err     error
-		}{newBalance, err}
+			entry      model.LedgerEntry
+			minBalance int64
+			err        error
+		}{entry, minBalance, err}
 	}
 
 	res := <-resultChan
-	return res.balance, res.err
+	if res.err != nil {
+		span.RecordError(res.err)
+		span.SetStatus(codes.Error, res.err.Error())
+		return model.WalletBalance{}, res.err
+	}
+	r.notifyBalanceChange(res.entry)
+	return model.NewWalletBalance(res.entry.BalanceAfter, res.minBalance), nil
 }
 
-func (r *Repo) changeBalanceAtomic(ctx context.Context, req model.WalletRequest) (int64, error) {
-	var newBalance int64
-	var err error
+func (r *Repo) changeBalanceAtomic(ctx context.Context, req model.WalletRequest) (entry model.LedgerEntry, minBalance int64, err error) {
+	entry = model.LedgerEntry{
+		WalletID:      req.WalletID,
+		OperationType: req.OperationType,
+		Amount:        req.Amount,
+		RequestID:     logctx.RequestID(ctx),
+	}
+
+	if req.OperationType != model.Deposit && req.OperationType != model.Withdraw {
+		return entry, 0, fmt.Errorf("unknown operation type")
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return entry, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
 
 	switch req.OperationType {
 	case model.Deposit:
-		err = r.db.QueryRowContext(ctx, `
+		err = tx.QueryRowContext(ctx, `
 			INSERT INTO wallets(wallet_id, balance)
 			VALUES ($1, $2)
 			ON CONFLICT (wallet_id) DO UPDATE
 			SET balance = wallets.balance + EXCLUDED.balance
-			RETURNING balance
-		`, req.WalletID, req.Amount).Scan(&newBalance)
+			WHERE wallets.frozen_at IS NULL
+			RETURNING balance, min_balance
+		`, req.WalletID, req.Amount).Scan(&entry.BalanceAfter, &minBalance)
+		if err == sql.ErrNoRows {
+			return entry, 0, ErrWalletFrozen
+		}
 
 	case model.Withdraw:
-		err = r.db.QueryRowContext(ctx, `
+		err = tx.QueryRowContext(ctx, `
 			UPDATE wallets
 			SET balance = balance - $1
-			WHERE wallet_id = $2 AND balance >= $1
-			RETURNING balance
-		`, req.Amount, req.WalletID).Scan(&newBalance)
+			WHERE wallet_id = $2 AND balance - min_balance >= $1 AND frozen_at IS NULL
+			RETURNING balance, min_balance
+		`, req.Amount, req.WalletID).Scan(&entry.BalanceAfter, &minBalance)
 		if err == sql.ErrNoRows {
-			return 0, fmt.Errorf("insufficient balance")
+			return entry, 0, withdrawRejection(ctx, tx, req.WalletID)
 		}
+	}
+	if err != nil {
+		return entry, 0, err
+	}
+
+	if err := recordEntry(ctx, tx, &entry); err != nil {
+		return entry, 0, err
+	}
 
-	default:
-		return 0, fmt.Errorf("unknown operation type")
+	if err := tx.Commit(); err != nil {
+		return entry, 0, fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	return entry, minBalance, nil
+}
 
-	return newBalance, err
+// recordEntry writes a balance change to the ledger and the outbox and
+// notifies listeners once tx commits. It fills in the entry's ID and time.
+func recordEntry(ctx context.Context, tx *sql.Tx, entry *model.LedgerEntry) error {
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO ledger_entries(wallet_id, operation_type, amount, balance_after, request_id, transfer_id)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
+		RETURNING id, created_at
+	`, entry.WalletID, entry.OperationType, entry.Amount, entry.BalanceAfter, entry.RequestID, entry.TransferID).Scan(&entry.ID, &entry.CreatedAt); err != nil {
+		return fmt.Errorf("failed to write ledger entry: %w", err)
+	}
+	if err := insertBalanceChanged(ctx, tx, *entry); err != nil {
+		return err
+	}
+	return notifyBalanceChanged(ctx, tx, *entry)
 }
 
-func (r *Repo) GetBalance(walletID uuid.UUID) (int64, error) {
+// withdrawRejection tells apart the two reasons a withdraw updates no row.
+func withdrawRejection(ctx context.Context, tx *sql.Tx, walletID uuid.UUID) error {
+	var frozen bool
+	err := tx.QueryRowContext(ctx, `SELECT frozen_at IS NOT NULL FROM wallets WHERE wallet_id = $1`, walletID).Scan(&frozen)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to check wallet state: %w", err)
+	}
+	if frozen {
+		return ErrWalletFrozen
+	}
+	return ErrInsufficientFunds
+}
+
+func (r *Repo) GetBalance(ctx context.Context, walletID uuid.UUID) (int64, error) {
 	var bal int64
-	err := r.db.QueryRow(`SELECT balance FROM wallets WHERE wallet_id = $1`, walletID).Scan(&bal)
+	err := r.read(ctx, func(db *sql.DB) error {
+		return db.QueryRowContext(ctx, `SELECT balance FROM wallets WHERE wallet_id = $1`, walletID).Scan(&bal)
+	})
 	if err == sql.ErrNoRows {
 		return 0, nil
 	}
@@ -138,3 +340,60 @@ func (r *Repo) GetBalance(walletID uuid.UUID) (int64, error) {
 	}
 	return bal, nil
 }
+
+// GetWalletBalance returns the balance with the wallet's credit line. A
+// wallet that does not exist yet has a zero balance and no credit line.
+func (r *Repo) GetWalletBalance(ctx context.Context, walletID uuid.UUID) (model.WalletBalance, error) {
+	var bal, minBalance int64
+	err := r.read(ctx, func(db *sql.DB) error {
+		return db.QueryRowContext(ctx, `SELECT balance, min_balance FROM wallets WHERE wallet_id = $1`, walletID).
+			Scan(&bal, &minBalance)
+	})
+	if err == sql.ErrNoRows {
+		return model.WalletBalance{}, nil
+	}
+	if err != nil {
+		return model.WalletBalance{}, fmt.Errorf("failed to get balance: %w", err)
+	}
+	return model.NewWalletBalance(bal, minBalance), nil
+}
+
+// GetBalances returns the balances of the wallets that exist, with their
+// credit lines.
+func (r *Repo) GetBalances(ctx context.Context, walletIDs []uuid.UUID) (map[uuid.UUID]model.WalletBalance, error) {
+	var balances map[uuid.UUID]model.WalletBalance
+	err := r.read(ctx, func(db *sql.DB) error {
+		rows, err := db.QueryContext(ctx,
+			`SELECT wallet_id, balance, min_balance FROM wallets WHERE wallet_id = ANY($1::uuid[])`, uuidArray(walletIDs))
+		if err != nil {
+			return fmt.Errorf("failed to get balances: %w", err)
+		}
+		defer rows.Close()
+
+		balances = make(map[uuid.UUID]model.WalletBalance, len(walletIDs))
+		for rows.Next() {
+			var id uuid.UUID
+			var bal, minBalance int64
+			if err := rows.Scan(&id, &bal, &minBalance); err != nil {
+				return fmt.Errorf("failed to scan balance: %w", err)
+			}
+			balances[id] = model.NewWalletBalance(bal, minBalance)
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to iterate balances: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
+
+func uuidArray(ids []uuid.UUID) driver.Valuer {
+	strs := make([]string, len(ids))
+	for i, id := range ids {
+		strs[i] = id.String()
+	}
+	return pq.Array(strs)
+}
@@ -3,13 +3,22 @@ package repo
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/yokitheyo/go_wallet_test/internal/logctx"
 	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func setupTestDB(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *Repo) {
@@ -37,7 +46,7 @@ func TestRepo_GetBalance(t *testing.T) {
 			WithArgs(walletID).
 			WillReturnRows(rows)
 
-		balance, err := repo.GetBalance(walletID)
+		balance, err := repo.GetBalance(context.Background(), walletID)
 		assert.NoError(t, err)
 		assert.Equal(t, expectedBalance, balance)
 	})
@@ -47,7 +56,7 @@ func TestRepo_GetBalance(t *testing.T) {
 			WithArgs(walletID).
 			WillReturnError(sql.ErrNoRows)
 
-		balance, err := repo.GetBalance(walletID)
+		balance, err := repo.GetBalance(context.Background(), walletID)
 		assert.NoError(t, err)
 		assert.Equal(t, int64(0), balance)
 	})
@@ -57,7 +66,7 @@ func TestRepo_GetBalance(t *testing.T) {
 			WithArgs(walletID).
 			WillReturnError(sql.ErrConnDone)
 
-		balance, err := repo.GetBalance(walletID)
+		balance, err := repo.GetBalance(context.Background(), walletID)
 		assert.Error(t, err)
 		assert.Equal(t, int64(0), balance)
 	})
@@ -65,6 +74,28 @@ func TestRepo_GetBalance(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestRepo_GetWalletBalance(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+
+	walletID := uuid.New()
+	mock.ExpectQuery("SELECT balance, min_balance FROM wallets WHERE wallet_id = \\$1").
+		WithArgs(walletID).
+		WillReturnRows(sqlmock.NewRows([]string{"balance", "min_balance"}).AddRow(int64(-300), int64(-1000)))
+	mock.ExpectQuery("SELECT balance, min_balance FROM wallets WHERE wallet_id = \\$1").
+		WithArgs(walletID).
+		WillReturnError(sql.ErrNoRows)
+
+	balance, err := repo.GetWalletBalance(context.Background(), walletID)
+	assert.NoError(t, err)
+	assert.Equal(t, model.WalletBalance{Balance: -300, MinBalance: -1000, CreditUsed: 300, CreditAvailable: 700}, balance)
+
+	balance, err = repo.GetWalletBalance(context.Background(), walletID)
+	assert.NoError(t, err)
+	assert.Equal(t, model.WalletBalance{}, balance)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestRepo_ChangeBalance(t *testing.T) {
 	db, mock, repo := setupTestDB(t)
 	defer db.Close()
@@ -80,14 +111,25 @@ func TestRepo_ChangeBalance(t *testing.T) {
 		}
 
 		expectedBalance := int64(1100)
-		rows := sqlmock.NewRows([]string{"balance"}).AddRow(expectedBalance)
+		rows := sqlmock.NewRows([]string{"balance", "min_balance"}).AddRow(expectedBalance, int64(0))
+		mock.ExpectBegin()
 		mock.ExpectQuery("INSERT INTO wallets").
 			WithArgs(walletID, req.Amount).
 			WillReturnRows(rows)
+		mock.ExpectQuery("INSERT INTO ledger_entries").
+			WithArgs(walletID, model.Deposit, req.Amount, expectedBalance, "", nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(int64(1), time.Now()))
+		mock.ExpectExec("INSERT INTO outbox").
+			WithArgs(walletID, model.EventBalanceChanged, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("SELECT pg_notify").
+			WithArgs(BalanceChannel, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
 
 		balance, err := repo.ChangeBalance(ctx, req)
 		assert.NoError(t, err)
-		assert.Equal(t, expectedBalance, balance)
+		assert.Equal(t, expectedBalance, balance.Balance)
 	})
 
 	t.Run("withdraw operation success", func(t *testing.T) {
@@ -98,14 +140,52 @@ func TestRepo_ChangeBalance(t *testing.T) {
 		}
 
 		expectedBalance := int64(1050)
-		rows := sqlmock.NewRows([]string{"balance"}).AddRow(expectedBalance)
+		rows := sqlmock.NewRows([]string{"balance", "min_balance"}).AddRow(expectedBalance, int64(0))
+		mock.ExpectBegin()
 		mock.ExpectQuery("UPDATE wallets").
 			WithArgs(req.Amount, walletID).
 			WillReturnRows(rows)
+		mock.ExpectQuery("INSERT INTO ledger_entries").
+			WithArgs(walletID, model.Withdraw, req.Amount, expectedBalance, "req-42", nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(int64(2), time.Now()))
+		mock.ExpectExec("INSERT INTO outbox").
+			WithArgs(walletID, model.EventBalanceChanged, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectExec("SELECT pg_notify").
+			WithArgs(BalanceChannel, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		balance, err := repo.ChangeBalance(logctx.WithRequestID(ctx, "req-42"), req)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedBalance, balance.Balance)
+	})
+
+	t.Run("withdraw into the credit line", func(t *testing.T) {
+		req := model.WalletRequest{
+			WalletID:      walletID,
+			OperationType: model.Withdraw,
+			Amount:        1250,
+		}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE wallets (.+) balance - min_balance >= \\$1").
+			WithArgs(req.Amount, walletID).
+			WillReturnRows(sqlmock.NewRows([]string{"balance", "min_balance"}).AddRow(int64(-200), int64(-500)))
+		mock.ExpectQuery("INSERT INTO ledger_entries").
+			WithArgs(walletID, model.Withdraw, req.Amount, int64(-200), "", nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(int64(3), time.Now()))
+		mock.ExpectExec("INSERT INTO outbox").
+			WithArgs(walletID, model.EventBalanceChanged, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(3, 1))
+		mock.ExpectExec("SELECT pg_notify").
+			WithArgs(BalanceChannel, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
 
 		balance, err := repo.ChangeBalance(ctx, req)
 		assert.NoError(t, err)
-		assert.Equal(t, expectedBalance, balance)
+		assert.Equal(t, model.WalletBalance{Balance: -200, MinBalance: -500, CreditUsed: 200, CreditAvailable: 300}, balance)
 	})
 
 	t.Run("withdraw operation insufficient funds", func(t *testing.T) {
@@ -115,14 +195,42 @@ func TestRepo_ChangeBalance(t *testing.T) {
 			Amount:        2000,
 		}
 
+		mock.ExpectBegin()
 		mock.ExpectQuery("UPDATE wallets").
 			WithArgs(req.Amount, walletID).
 			WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery("SELECT frozen_at IS NOT NULL FROM wallets").
+			WithArgs(walletID).
+			WillReturnRows(sqlmock.NewRows([]string{"frozen"}).AddRow(false))
+		mock.ExpectRollback()
 
 		balance, err := repo.ChangeBalance(ctx, req)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "insufficient balance")
-		assert.Equal(t, int64(0), balance)
+		assert.Zero(t, balance)
+	})
+
+	t.Run("outbox write failure rolls back", func(t *testing.T) {
+		req := model.WalletRequest{
+			WalletID:      walletID,
+			OperationType: model.Deposit,
+			Amount:        10,
+		}
+
+		rows := sqlmock.NewRows([]string{"balance", "min_balance"}).AddRow(int64(1060), int64(0))
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO wallets").
+			WithArgs(walletID, req.Amount).
+			WillReturnRows(rows)
+		mock.ExpectQuery("INSERT INTO ledger_entries").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(int64(3), time.Now()))
+		mock.ExpectExec("INSERT INTO outbox").
+			WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		balance, err := repo.ChangeBalance(ctx, req)
+		assert.Error(t, err)
+		assert.Zero(t, balance)
 	})
 
 	t.Run("unknown operation type", func(t *testing.T) {
@@ -135,7 +243,7 @@ func TestRepo_ChangeBalance(t *testing.T) {
 		balance, err := repo.ChangeBalance(ctx, req)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "unknown operation type")
-		assert.Equal(t, int64(0), balance)
+		assert.Zero(t, balance)
 	})
 
 	assert.NoError(t, mock.ExpectationsWereMet())
@@ -150,3 +258,136 @@ func TestRepo_Close(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestRepo_GetBalances(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+
+	known := uuid.New()
+	unknown := uuid.New()
+	ctx := context.Background()
+
+	t.Run("mixed wallets", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"wallet_id", "balance", "min_balance"}).AddRow(known.String(), int64(700), int64(-300))
+		mock.ExpectQuery("SELECT wallet_id, balance, min_balance FROM wallets WHERE wallet_id = ANY").
+			WithArgs(sqlmock.AnyArg()).
+			WillReturnRows(rows)
+
+		balances, err := repo.GetBalances(ctx, []uuid.UUID{known, unknown})
+		assert.NoError(t, err)
+		assert.Equal(t, map[uuid.UUID]model.WalletBalance{known: model.NewWalletBalance(700, -300)}, balances)
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		mock.ExpectQuery("SELECT wallet_id, balance, min_balance FROM wallets WHERE wallet_id = ANY").
+			WithArgs(sqlmock.AnyArg()).
+			WillReturnError(sql.ErrConnDone)
+
+		balances, err := repo.GetBalances(ctx, []uuid.UUID{known})
+		assert.Error(t, err)
+		assert.Nil(t, balances)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_ChangeBalance_NotifiesHooks(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+
+	walletID := uuid.New()
+	var got []model.LedgerEntry
+	repo.OnBalanceChange(func(e model.LedgerEntry) { got = append(got, e) })
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO wallets").
+		WithArgs(walletID, int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"balance", "min_balance"}).AddRow(int64(5), int64(0)))
+	mock.ExpectQuery("INSERT INTO ledger_entries").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(int64(77), time.Now()))
+	mock.ExpectExec("INSERT INTO outbox").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("SELECT pg_notify").
+		WithArgs(BalanceChannel, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	_, err := repo.ChangeBalance(context.Background(), model.WalletRequest{WalletID: walletID, OperationType: model.Deposit, Amount: 5})
+	assert.NoError(t, err)
+	if assert.Len(t, got, 1) {
+		assert.Equal(t, int64(77), got[0].ID)
+		assert.Equal(t, int64(5), got[0].BalanceAfter)
+	}
+
+	_, err = repo.ChangeBalance(context.Background(), model.WalletRequest{WalletID: walletID, OperationType: "UNKNOWN", Amount: 5})
+	assert.Error(t, err)
+	assert.Len(t, got, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_ChangeBalance_Traces(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(prev)
+
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+
+	walletID := uuid.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE wallets").
+		WithArgs(int64(10), walletID).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT frozen_at IS NOT NULL FROM wallets").
+		WithArgs(walletID).
+		WillReturnError(sql.ErrNoRows)
+
+	_, err := repo.ChangeBalance(context.Background(), model.WalletRequest{WalletID: walletID, OperationType: model.Withdraw, Amount: 10})
+	assert.Error(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	wait, op := spans[0], spans[1]
+	assert.Equal(t, "wallet.queue.wait", wait.Name())
+	assert.Equal(t, "Repo.ChangeBalance", op.Name())
+	assert.Equal(t, op.SpanContext().SpanID(), wait.Parent().SpanID())
+	assert.Equal(t, codes.Error, op.Status().Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_SetWalletMetadata_Conflict(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+
+	walletID := uuid.New()
+	mock.ExpectQuery("INSERT INTO wallets(.+)ON CONFLICT").
+		WithArgs(walletID, "cust-1", "main", "", []byte(`{}`)).
+		WillReturnError(&pq.Error{Code: "23505"})
+
+	_, err := repo.SetWalletMetadata(context.Background(), walletID, model.WalletMetadata{OwnerID: "cust-1", ExternalRef: "main"})
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_PatchWalletMetadata_ValidatesMergedLabels(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+
+	walletID := uuid.New()
+	labels := map[string]any{}
+	for i := range model.MaxLabels {
+		labels[fmt.Sprintf("k%d", i)] = i
+	}
+	encoded, _ := json.Marshal(labels)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM wallets WHERE wallet_id = \\$1 FOR UPDATE").WithArgs(walletID).
+		WillReturnRows(sqlmock.NewRows([]string{"wallet_id", "balance", "min_balance", "owner_id", "external_ref", "display_name", "labels", "frozen", "created_at", "updated_at"}).
+			AddRow(walletID, 100, 0, "cust-1", "", "", encoded, false, time.Now(), time.Now()))
+	mock.ExpectRollback()
+
+	_, err := repo.PatchWalletMetadata(context.Background(), walletID, model.PatchWalletMetadataRequest{Labels: map[string]any{"one-more": 1}})
+	assert.ErrorIs(t, err, ErrInvalid)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,370 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+)
+
+const webhookColumns = `id, url, event_types, wallet_ids, secret, enabled, failure_count, created_at, updated_at, disabled_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanWebhook(row rowScanner) (model.WebhookSubscription, error) {
+	var (
+		sub       model.WebhookSubscription
+		walletIDs []string
+	)
+	err := row.Scan(&sub.ID, &sub.URL, pq.Array(&sub.EventTypes), pq.Array(&walletIDs), &sub.Secret,
+		&sub.Enabled, &sub.FailureCount, &sub.CreatedAt, &sub.UpdatedAt, &sub.DisabledAt)
+	if err != nil {
+		return sub, err
+	}
+	sub.WalletIDs, err = parseUUIDs(walletIDs)
+	return sub, err
+}
+
+func parseUUIDs(strs []string) ([]uuid.UUID, error) {
+	ids := make([]uuid.UUID, len(strs))
+	for i, s := range strs {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid uuid %q: %w", s, err)
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+func (r *Repo) CreateWebhook(ctx context.Context, sub model.WebhookSubscription) (model.WebhookSubscription, error) {
+	if sub.EventTypes == nil {
+		sub.EventTypes = []string{}
+	}
+	var created model.WebhookSubscription
+	err := r.queryRow(ctx, func(row *sql.Row) error {
+		var err error
+		created, err = scanWebhook(row)
+		return err
+	}, `
+		INSERT INTO webhook_subscriptions(id, url, event_types, wallet_ids, secret)
+		VALUES ($1, $2, $3, $4::uuid[], $5)
+		RETURNING `+webhookColumns,
+		sub.ID, sub.URL, pq.Array(sub.EventTypes), uuidArray(sub.WalletIDs), sub.Secret)
+	if err != nil {
+		return created, fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return created, nil
+}
+
+func (r *Repo) ListWebhooks(ctx context.Context) ([]model.WebhookSubscription, error) {
+	rows, err := r.query(ctx, `SELECT `+webhookColumns+` FROM webhook_subscriptions ORDER BY created_at`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	subs := []model.WebhookSubscription{}
+	for rows.Next() {
+		sub, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		subs = append(subs, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate webhooks: %w", err)
+	}
+	return subs, nil
+}
+
+func (r *Repo) GetWebhook(ctx context.Context, id uuid.UUID) (model.WebhookSubscription, error) {
+	var sub model.WebhookSubscription
+	err := r.queryRow(ctx, func(row *sql.Row) error {
+		var err error
+		sub, err = scanWebhook(row)
+		return err
+	}, `SELECT `+webhookColumns+` FROM webhook_subscriptions WHERE id = $1`, id)
+	if err == sql.ErrNoRows {
+		return sub, ErrNotFound
+	}
+	if err != nil {
+		return sub, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	return sub, nil
+}
+
+func (r *Repo) UpdateWebhook(ctx context.Context, id uuid.UUID, req model.UpdateWebhookRequest) (model.WebhookSubscription, error) {
+	var eventTypes, walletIDs any
+	if req.EventTypes != nil {
+		eventTypes = pq.Array(*req.EventTypes)
+	}
+	if req.WalletIDs != nil {
+		walletIDs = uuidArray(*req.WalletIDs)
+	}
+
+	// Re-enabling a subscription clears its failure streak.
+	var sub model.WebhookSubscription
+	err := r.queryRow(ctx, func(row *sql.Row) error {
+		var err error
+		sub, err = scanWebhook(row)
+		return err
+	}, `
+		UPDATE webhook_subscriptions SET
+			url = COALESCE($2, url),
+			event_types = COALESCE($3::text[], event_types),
+			wallet_ids = COALESCE($4::uuid[], wallet_ids),
+			enabled = COALESCE($5, enabled),
+			failure_count = CASE WHEN $5 THEN 0 ELSE failure_count END,
+			disabled_at = CASE WHEN $5 THEN NULL WHEN $5 = FALSE THEN now() ELSE disabled_at END,
+			updated_at = now()
+		WHERE id = $1
+		RETURNING `+webhookColumns,
+		id, req.URL, eventTypes, walletIDs, req.Enabled)
+	if err == sql.ErrNoRows {
+		return sub, ErrNotFound
+	}
+	if err != nil {
+		return sub, fmt.Errorf("failed to update webhook: %w", err)
+	}
+	return sub, nil
+}
+
+func (r *Repo) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	res, err := r.exec(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// EnqueueWebhookDeliveries fans an outbox event out to every enabled
+// subscription whose filters match. Re-delivered events are ignored, so the
+// outbox relay can safely retry.
+func (r *Repo) EnqueueWebhookDeliveries(ctx context.Context, event model.Event) (int64, error) {
+	res, err := r.exec(ctx, `
+		INSERT INTO webhook_deliveries(subscription_id, event_id, event_type, wallet_id, payload)
+		SELECT id, $1, $2, $3, $4
+		FROM webhook_subscriptions
+		WHERE enabled
+		  AND (cardinality(event_types) = 0 OR $2 = ANY(event_types))
+		  AND (cardinality(wallet_ids) = 0 OR $3 = ANY(wallet_ids))
+		ON CONFLICT (subscription_id, event_id) DO NOTHING
+	`, event.ID, event.Type, event.WalletID, []byte(event.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to enqueue webhook deliveries: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n, nil
+}
+
+const deliveryColumns = `d.id, d.subscription_id, d.event_id, d.event_type, d.wallet_id, d.payload, d.status,
+	d.attempts, d.next_attempt_at, d.last_status_code, d.last_error, d.created_at, d.delivered_at`
+
+func scanDelivery(row rowScanner, extra ...any) (model.WebhookDelivery, error) {
+	var d model.WebhookDelivery
+	dest := append([]any{&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.WalletID, &d.Payload, &d.Status,
+		&d.Attempts, &d.NextAttemptAt, &d.LastStatusCode, &d.LastError, &d.CreatedAt, &d.DeliveredAt}, extra...)
+	err := row.Scan(dest...)
+	return d, err
+}
+
+func (r *Repo) ListWebhookDeliveries(ctx context.Context, subscriptionID uuid.UUID, limit int) ([]model.WebhookDelivery, error) {
+	rows, err := r.query(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries d
+		WHERE d.subscription_id = $1
+		ORDER BY d.id DESC
+		LIMIT $2
+	`, subscriptionID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []model.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+func (r *Repo) RedeliverWebhook(ctx context.Context, subscriptionID uuid.UUID, deliveryID int64) (model.WebhookDelivery, error) {
+	// Attempts restart from zero so a dead delivery gets a full retry budget;
+	// disabled subscriptions are skipped by the worker, so refuse those up front.
+	var d model.WebhookDelivery
+	err := r.queryRow(ctx, func(row *sql.Row) error {
+		var err error
+		d, err = scanDelivery(row)
+		return err
+	}, `
+		UPDATE webhook_deliveries d
+		SET status = 'pending', attempts = 0, next_attempt_at = now(), delivered_at = NULL
+		FROM webhook_subscriptions s
+		WHERE d.id = $1 AND d.subscription_id = $2 AND s.id = d.subscription_id AND s.enabled
+		RETURNING `+deliveryColumns,
+		deliveryID, subscriptionID)
+	if err == sql.ErrNoRows {
+		var exists bool
+		err = r.queryRow(ctx, func(row *sql.Row) error { return row.Scan(&exists) }, `
+			SELECT EXISTS (SELECT 1 FROM webhook_deliveries WHERE id = $1 AND subscription_id = $2)`,
+			deliveryID, subscriptionID)
+		if err != nil {
+			return d, fmt.Errorf("failed to redeliver webhook: %w", err)
+		}
+		if exists {
+			return d, fmt.Errorf("%w: subscription is disabled", ErrConflict)
+		}
+		return d, ErrNotFound
+	}
+	if err != nil {
+		return d, fmt.Errorf("failed to redeliver webhook: %w", err)
+	}
+	return d, nil
+}
+
+// ClaimWebhookDeliveries takes up to limit due deliveries and pushes their
+// next attempt lease into the future, so other replicas skip them while this
+// one sends. Rows locked by a concurrent claim are skipped rather than waited
+// for. A worker that dies mid-send leaves its deliveries to be claimed again
+// once the lease runs out.
+func (r *Repo) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]model.WebhookDelivery, error) {
+	rows, err := r.query(ctx, `
+		WITH due AS (
+			SELECT d.id
+			FROM webhook_deliveries d
+			JOIN webhook_subscriptions s ON s.id = d.subscription_id
+			WHERE d.status = 'pending' AND d.next_attempt_at <= now() AND s.enabled
+			ORDER BY d.id
+			LIMIT $1
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = now() + $2 * interval '1 second'
+		FROM due, webhook_subscriptions s
+		WHERE d.id = due.id AND s.id = d.subscription_id
+		RETURNING `+deliveryColumns+`, s.url, s.secret
+	`, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim due webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []model.WebhookDelivery
+	for rows.Next() {
+		var url, secret string
+		d, err := scanDelivery(rows, &url, &secret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		d.URL, d.Secret = url, secret
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+func (r *Repo) RecordWebhookSuccess(ctx context.Context, d model.WebhookDelivery, statusCode int) error {
+	return r.guard(func() error { return r.recordWebhookSuccess(ctx, d, statusCode) })
+}
+
+func (r *Repo) recordWebhookSuccess(ctx context.Context, d model.WebhookDelivery, statusCode int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = 'succeeded', attempts = attempts + 1, last_status_code = $2, last_error = NULL, delivered_at = now()
+		WHERE id = $1
+	`, d.ID, statusCode); err != nil {
+		return fmt.Errorf("failed to record webhook success: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE webhook_subscriptions SET failure_count = 0 WHERE id = $1`, d.SubscriptionID); err != nil {
+		return fmt.Errorf("failed to reset webhook failures: %w", err)
+	}
+	return tx.Commit()
+}
+
+// RecordWebhookFailure stores a failed attempt. A nil nextAttempt marks the
+// delivery as permanently failed. The subscription is disabled once its
+// consecutive failures reach disableAfter; it reports whether this failure
+// was the one that disabled it.
+func (r *Repo) RecordWebhookFailure(ctx context.Context, d model.WebhookDelivery, statusCode int, cause error, nextAttempt *time.Time, disableAfter int) (bool, error) {
+	var disabled bool
+	err := r.guard(func() error {
+		var err error
+		disabled, err = r.recordWebhookFailure(ctx, d, statusCode, cause, nextAttempt, disableAfter)
+		return err
+	})
+	return disabled, err
+}
+
+func (r *Repo) recordWebhookFailure(ctx context.Context, d model.WebhookDelivery, statusCode int, cause error, nextAttempt *time.Time, disableAfter int) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var code *int
+	if statusCode > 0 {
+		code = &statusCode
+	}
+	status := model.DeliveryPending
+	next := time.Now()
+	if nextAttempt == nil {
+		status = model.DeliveryFailed
+	} else {
+		next = *nextAttempt
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = attempts + 1, next_attempt_at = $3, last_status_code = $4, last_error = $5
+		WHERE id = $1
+	`, d.ID, status, next, code, cause.Error()); err != nil {
+		return false, fmt.Errorf("failed to record webhook failure: %w", err)
+	}
+
+	// RETURNING sees the new row, so the old enabled flag comes from a locked
+	// read: only the update that flips it reports the subscription disabled.
+	var disabled bool
+	if err := tx.QueryRowContext(ctx, `
+		UPDATE webhook_subscriptions s
+		SET failure_count = s.failure_count + 1,
+			enabled = CASE WHEN s.failure_count + 1 >= $2 THEN FALSE ELSE s.enabled END,
+			disabled_at = CASE WHEN s.failure_count + 1 >= $2 AND s.enabled THEN now() ELSE s.disabled_at END
+		FROM (SELECT enabled FROM webhook_subscriptions WHERE id = $1 FOR UPDATE) old
+		WHERE s.id = $1
+		RETURNING old.enabled AND NOT s.enabled
+	`, d.SubscriptionID, disableAfter).Scan(&disabled); err != nil {
+		return false, fmt.Errorf("failed to update webhook failures: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return disabled, nil
+}
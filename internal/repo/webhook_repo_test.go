@@ -0,0 +1,178 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+)
+
+func TestRepo_GetWebhook(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+
+	id := uuid.New()
+	walletID := uuid.New()
+	now := time.Now()
+
+	t.Run("existing", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "url", "event_types", "wallet_ids", "secret", "enabled", "failure_count", "created_at", "updated_at", "disabled_at"}).
+			AddRow(id.String(), "https://example.com", "{BalanceChanged}", "{"+walletID.String()+"}", "s", true, 0, now, now, nil)
+		mock.ExpectQuery("SELECT (.+) FROM webhook_subscriptions WHERE id = \\$1").
+			WithArgs(id).
+			WillReturnRows(rows)
+
+		sub, err := repo.GetWebhook(context.Background(), id)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"BalanceChanged"}, sub.EventTypes)
+		assert.Equal(t, []uuid.UUID{walletID}, sub.WalletIDs)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM webhook_subscriptions WHERE id = \\$1").
+			WithArgs(id).
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.GetWebhook(context.Background(), id)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_EnqueueWebhookDeliveries(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+
+	event := model.Event{ID: 9, WalletID: uuid.New(), Type: model.EventBalanceChanged, Payload: json.RawMessage(`{}`)}
+	mock.ExpectExec("INSERT INTO webhook_deliveries").
+		WithArgs(event.ID, event.Type, event.WalletID, []byte(event.Payload)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	n, err := repo.EnqueueWebhookDeliveries(context.Background(), event)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_RecordWebhookFailure(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+
+	d := model.WebhookDelivery{ID: 4, SubscriptionID: uuid.New()}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE webhook_deliveries").
+		WithArgs(d.ID, model.DeliveryFailed, sqlmock.AnyArg(), 500, "boom").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE webhook_subscriptions").
+		WithArgs(d.SubscriptionID, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"disabled"}).AddRow(true))
+	mock.ExpectCommit()
+
+	disabled, err := repo.RecordWebhookFailure(context.Background(), d, 500, errors.New("boom"), nil, 3)
+	assert.NoError(t, err)
+	assert.True(t, disabled)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_RecordWebhookFailureOnDisabledSubscription(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+
+	d := model.WebhookDelivery{ID: 5, SubscriptionID: uuid.New()}
+	next := time.Now().Add(time.Minute)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE webhook_deliveries").
+		WithArgs(d.ID, model.DeliveryPending, next, nil, "boom").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE webhook_subscriptions s (.+) RETURNING old.enabled AND NOT s.enabled").
+		WithArgs(d.SubscriptionID, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"disabled"}).AddRow(false))
+	mock.ExpectCommit()
+
+	disabled, err := repo.RecordWebhookFailure(context.Background(), d, 0, errors.New("boom"), &next, 3)
+	assert.NoError(t, err)
+	assert.False(t, disabled)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_RedeliverWebhook(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+
+	subID := uuid.New()
+	ctx := context.Background()
+
+	t.Run("resets attempts", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "subscription_id", "event_id", "event_type", "wallet_id", "payload", "status",
+			"attempts", "next_attempt_at", "last_status_code", "last_error", "created_at", "delivered_at"}).
+			AddRow(3, subID.String(), 9, "BalanceChanged", uuid.New().String(), []byte(`{}`), "pending",
+				0, time.Now(), nil, nil, time.Now(), nil)
+		mock.ExpectQuery("UPDATE webhook_deliveries d SET status = 'pending', attempts = 0, (.+) AND s.enabled").
+			WithArgs(int64(3), subID).
+			WillReturnRows(rows)
+
+		d, err := repo.RedeliverWebhook(ctx, subID, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, model.DeliveryPending, d.Status)
+		assert.Equal(t, 0, d.Attempts)
+	})
+
+	t.Run("disabled subscription", func(t *testing.T) {
+		mock.ExpectQuery("UPDATE webhook_deliveries d").
+			WithArgs(int64(3), subID).
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery("SELECT EXISTS").
+			WithArgs(int64(3), subID).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+		_, err := repo.RedeliverWebhook(ctx, subID, 3)
+		assert.ErrorIs(t, err, ErrConflict)
+	})
+
+	t.Run("missing delivery", func(t *testing.T) {
+		mock.ExpectQuery("UPDATE webhook_deliveries d").
+			WithArgs(int64(4), subID).
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery("SELECT EXISTS").
+			WithArgs(int64(4), subID).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		_, err := repo.RedeliverWebhook(ctx, subID, 4)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_ClaimWebhookDeliveries(t *testing.T) {
+	db, mock, repo := setupTestDB(t)
+	defer db.Close()
+
+	subID := uuid.New()
+	rows := sqlmock.NewRows([]string{"id", "subscription_id", "event_id", "event_type", "wallet_id", "payload", "status",
+		"attempts", "next_attempt_at", "last_status_code", "last_error", "created_at", "delivered_at", "url", "secret"}).
+		AddRow(7, subID.String(), 9, "BalanceChanged", uuid.New().String(), []byte(`{}`), "pending",
+			1, time.Now().Add(time.Minute), nil, nil, time.Now(), nil, "https://example.com/hook", "s")
+	mock.ExpectQuery("FOR UPDATE OF d SKIP LOCKED (.+) UPDATE webhook_deliveries d SET next_attempt_at = now\\(\\) \\+ \\$2 (.+) RETURNING").
+		WithArgs(50, float64(80)).
+		WillReturnRows(rows)
+
+	deliveries, err := repo.ClaimWebhookDeliveries(context.Background(), 50, 80*time.Second)
+	assert.NoError(t, err)
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, int64(7), deliveries[0].ID)
+		assert.Equal(t, "https://example.com/hook", deliveries[0].URL)
+		assert.Equal(t, "s", deliveries[0].Secret)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
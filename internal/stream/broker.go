@@ -0,0 +1,106 @@
+package stream
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+)
+
+// Subscription receives ledger entries for one wallet. C is closed when the
+// subscriber falls too far behind or the broker shuts down; clients are
+// expected to reconnect and resume from the last entry they saw.
+type Subscription struct {
+	C <-chan model.LedgerEntry
+
+	ch       chan model.LedgerEntry
+	walletID uuid.UUID
+	broker   *Broker
+	once     sync.Once
+}
+
+func (s *Subscription) Close() {
+	s.broker.unsubscribe(s)
+}
+
+type Broker struct {
+	mu         sync.Mutex
+	bufferSize int
+	subs       map[uuid.UUID]map[*Subscription]struct{}
+	closed     bool
+}
+
+func NewBroker(bufferSize int) *Broker {
+	if bufferSize <= 0 {
+		bufferSize = 64
+	}
+	return &Broker{
+		bufferSize: bufferSize,
+		subs:       make(map[uuid.UUID]map[*Subscription]struct{}),
+	}
+}
+
+func (b *Broker) Subscribe(walletID uuid.UUID) *Subscription {
+	ch := make(chan model.LedgerEntry, b.bufferSize)
+	sub := &Subscription{C: ch, ch: ch, walletID: walletID, broker: b}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(ch)
+		return sub
+	}
+	if b.subs[walletID] == nil {
+		b.subs[walletID] = make(map[*Subscription]struct{})
+	}
+	b.subs[walletID][sub] = struct{}{}
+	return sub
+}
+
+func (b *Broker) Publish(entry model.LedgerEntry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs[entry.WalletID] {
+		select {
+		case sub.ch <- entry:
+		default:
+			b.removeLocked(sub)
+		}
+	}
+}
+
+func (b *Broker) Subscribers(walletID uuid.UUID) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs[walletID])
+}
+
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for _, subs := range b.subs {
+		for sub := range subs {
+			b.removeLocked(sub)
+		}
+	}
+}
+
+func (b *Broker) unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.removeLocked(sub)
+}
+
+func (b *Broker) removeLocked(sub *Subscription) {
+	if subs, ok := b.subs[sub.walletID]; ok {
+		delete(subs, sub)
+		if len(subs) == 0 {
+			delete(b.subs, sub.walletID)
+		}
+	}
+	sub.once.Do(func() { close(sub.ch) })
+}
@@ -0,0 +1,56 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+)
+
+func TestBroker_PublishToWalletSubscribers(t *testing.T) {
+	b := NewBroker(4)
+	walletA := uuid.New()
+	walletB := uuid.New()
+
+	subA := b.Subscribe(walletA)
+	subB := b.Subscribe(walletB)
+	defer subA.Close()
+	defer subB.Close()
+
+	b.Publish(model.LedgerEntry{ID: 1, WalletID: walletA, BalanceAfter: 10})
+
+	assert.Equal(t, int64(1), (<-subA.C).ID)
+	assert.Len(t, subB.C, 0)
+}
+
+func TestBroker_SlowSubscriberIsDropped(t *testing.T) {
+	b := NewBroker(1)
+	walletID := uuid.New()
+	sub := b.Subscribe(walletID)
+
+	b.Publish(model.LedgerEntry{ID: 1, WalletID: walletID})
+	b.Publish(model.LedgerEntry{ID: 2, WalletID: walletID})
+
+	assert.Equal(t, 0, b.Subscribers(walletID))
+	e, ok := <-sub.C
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), e.ID)
+	_, ok = <-sub.C
+	assert.False(t, ok)
+
+	sub.Close()
+}
+
+func TestBroker_Close(t *testing.T) {
+	b := NewBroker(1)
+	sub := b.Subscribe(uuid.New())
+
+	b.Close()
+	_, ok := <-sub.C
+	assert.False(t, ok)
+
+	late := b.Subscribe(uuid.New())
+	_, ok = <-late.C
+	assert.False(t, ok)
+}
@@ -0,0 +1,178 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"go.uber.org/zap"
+)
+
+const backfillBatch = 500
+
+// backfillMargin is how far below the last seen id a backfill starts. Ledger
+// ids come from a sequence and are not assigned in commit order, so an entry
+// committed late can sit below ids already delivered.
+const backfillMargin = 1000
+
+type NotificationSource interface {
+	Listen(channel string) error
+	NotificationChannel() <-chan *pq.Notification
+	Ping() error
+	Close() error
+}
+
+type LedgerStore interface {
+	LedgerAfter(ctx context.Context, afterID int64, limit int) ([]model.LedgerEntry, error)
+	LatestLedgerID(ctx context.Context) (int64, error)
+}
+
+// Listener feeds the broker from Postgres NOTIFY so that balance changes made
+// by any instance reach subscribers connected to this one. After a reconnect
+// it replays the ledger from a margin below the last entry it saw, skipping
+// entries it has already published.
+type Listener struct {
+	source       NotificationSource
+	store        LedgerStore
+	broker       *Broker
+	channel      string
+	pingInterval time.Duration
+	logger       *zap.Logger
+	lastID       int64
+	floor        int64
+	seen         map[int64]struct{}
+}
+
+func NewPQListener(dsn string, logger *zap.Logger) *pq.Listener {
+	return pq.NewListener(dsn, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		switch ev {
+		case pq.ListenerEventDisconnected:
+			logger.Warn("balance listener disconnected", zap.Error(err))
+		case pq.ListenerEventReconnected:
+			logger.Info("balance listener reconnected")
+		case pq.ListenerEventConnectionAttemptFailed:
+			logger.Warn("balance listener reconnect attempt failed", zap.Error(err))
+		}
+	})
+}
+
+func NewListener(source NotificationSource, store LedgerStore, broker *Broker, channel string, logger *zap.Logger) *Listener {
+	return &Listener{
+		source:       source,
+		store:        store,
+		broker:       broker,
+		channel:      channel,
+		pingInterval: time.Minute,
+		logger:       logger,
+		seen:         make(map[int64]struct{}),
+	}
+}
+
+func (l *Listener) Start(ctx context.Context) error {
+	lastID, err := l.store.LatestLedgerID(ctx)
+	if err != nil {
+		return err
+	}
+	l.lastID = lastID
+	l.floor = lastID
+
+	if err := l.source.Listen(l.channel); err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", l.channel, err)
+	}
+	l.logger.Info("balance listener started", zap.String("channel", l.channel), zap.Int64("last_ledger_id", lastID))
+	return nil
+}
+
+func (l *Listener) Run(ctx context.Context) {
+	defer l.source.Close()
+
+	ticker := time.NewTicker(l.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			l.logger.Info("balance listener stopped")
+			return
+		case n, ok := <-l.source.NotificationChannel():
+			if !ok {
+				return
+			}
+			// pq delivers a nil notification after re-establishing a lost
+			// connection; anything committed in between has to come from the ledger.
+			if n == nil {
+				l.backfill(ctx)
+				continue
+			}
+			l.handle(n)
+		case <-ticker.C:
+			go l.source.Ping()
+		}
+	}
+}
+
+func (l *Listener) handle(n *pq.Notification) {
+	var entry model.LedgerEntry
+	if err := json.Unmarshal([]byte(n.Extra), &entry); err != nil {
+		l.logger.Error("invalid balance notification payload", zap.String("payload", n.Extra), zap.Error(err))
+		return
+	}
+	l.publish(entry)
+	if len(l.seen) > 2*backfillMargin {
+		l.forget()
+	}
+}
+
+func (l *Listener) publish(e model.LedgerEntry) {
+	if _, ok := l.seen[e.ID]; ok {
+		return
+	}
+	l.seen[e.ID] = struct{}{}
+	if e.ID > l.lastID {
+		l.lastID = e.ID
+	}
+	l.broker.Publish(e)
+}
+
+// forget drops seen ids that no backfill will reach again.
+func (l *Listener) forget() {
+	cutoff := l.lastID - backfillMargin
+	if cutoff <= l.floor {
+		return
+	}
+	l.floor = cutoff
+	for id := range l.seen {
+		if id <= cutoff {
+			delete(l.seen, id)
+		}
+	}
+}
+
+func (l *Listener) backfill(ctx context.Context) {
+	from := max(l.floor, l.lastID-backfillMargin)
+	after := from
+	replayed := 0
+	for {
+		entries, err := l.store.LedgerAfter(ctx, after, backfillBatch)
+		if err != nil {
+			l.logger.Error("failed to backfill ledger after reconnect", zap.Int64("after_id", after), zap.Error(err))
+			return
+		}
+		for _, e := range entries {
+			after = e.ID
+			if _, ok := l.seen[e.ID]; ok {
+				continue
+			}
+			l.publish(e)
+			replayed++
+		}
+		if len(entries) < backfillBatch {
+			break
+		}
+	}
+	l.forget()
+	l.logger.Info("ledger backfilled after reconnect", zap.Int64("from_id", from), zap.Int("entries", replayed))
+}
@@ -0,0 +1,164 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"go.uber.org/zap"
+)
+
+type fakeSource struct {
+	ch       chan *pq.Notification
+	listened string
+	closed   bool
+}
+
+func (f *fakeSource) Listen(channel string) error {
+	f.listened = channel
+	return nil
+}
+
+func (f *fakeSource) NotificationChannel() <-chan *pq.Notification { return f.ch }
+func (f *fakeSource) Ping() error                                  { return nil }
+func (f *fakeSource) Close() error {
+	f.closed = true
+	return nil
+}
+
+type MockLedgerStore struct {
+	mock.Mock
+}
+
+func (m *MockLedgerStore) LedgerAfter(ctx context.Context, afterID int64, limit int) ([]model.LedgerEntry, error) {
+	args := m.Called(ctx, afterID, limit)
+	return args.Get(0).([]model.LedgerEntry), args.Error(1)
+}
+
+func (m *MockLedgerStore) LatestLedgerID(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func notification(t *testing.T, e model.LedgerEntry) *pq.Notification {
+	payload, err := json.Marshal(e)
+	assert.NoError(t, err)
+	return &pq.Notification{Channel: "balances", Extra: string(payload)}
+}
+
+func TestListener_FanOutAndBackfill(t *testing.T) {
+	walletID := uuid.New()
+	source := &fakeSource{ch: make(chan *pq.Notification)}
+	store := &MockLedgerStore{}
+	broker := NewBroker(8)
+
+	store.On("LatestLedgerID", mock.Anything).Return(int64(10), nil)
+	store.On("LedgerAfter", mock.Anything, int64(10), backfillBatch).Return([]model.LedgerEntry{
+		{ID: 11, WalletID: walletID, BalanceAfter: 20},
+		{ID: 12, WalletID: walletID, BalanceAfter: 30},
+		{ID: 13, WalletID: uuid.New(), BalanceAfter: 5},
+	}, nil)
+
+	l := NewListener(source, store, broker, "balances", zap.NewNop())
+	assert.NoError(t, l.Start(context.Background()))
+	assert.Equal(t, "balances", source.listened)
+
+	sub := broker.Subscribe(walletID)
+	defer sub.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.Run(ctx)
+	}()
+
+	source.ch <- notification(t, model.LedgerEntry{ID: 11, WalletID: walletID, BalanceAfter: 20})
+	assert.Equal(t, int64(11), (<-sub.C).ID)
+
+	// nil signals a reconnect: the listener must replay what it missed
+	// without repeating what it already delivered.
+	source.ch <- nil
+	assert.Equal(t, int64(12), (<-sub.C).ID)
+
+	cancel()
+	<-done
+	assert.True(t, source.closed)
+	assert.Equal(t, int64(13), l.lastID)
+	store.AssertExpectations(t)
+}
+
+func TestListener_BackfillCatchesLateCommits(t *testing.T) {
+	walletID := uuid.New()
+	source := &fakeSource{ch: make(chan *pq.Notification)}
+	store := &MockLedgerStore{}
+	broker := NewBroker(8)
+
+	store.On("LatestLedgerID", mock.Anything).Return(int64(0), nil)
+	// Entry 1 took its id first but committed after 2 and 3 were delivered.
+	store.On("LedgerAfter", mock.Anything, int64(0), backfillBatch).Return([]model.LedgerEntry{
+		{ID: 1, WalletID: walletID, BalanceAfter: 10},
+		{ID: 2, WalletID: walletID, BalanceAfter: 20},
+		{ID: 3, WalletID: walletID, BalanceAfter: 30},
+		{ID: 4, WalletID: walletID, BalanceAfter: 40},
+	}, nil)
+
+	l := NewListener(source, store, broker, "balances", zap.NewNop())
+	assert.NoError(t, l.Start(context.Background()))
+
+	sub := broker.Subscribe(walletID)
+	defer sub.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.Run(ctx)
+	}()
+
+	source.ch <- notification(t, model.LedgerEntry{ID: 2, WalletID: walletID, BalanceAfter: 20})
+	source.ch <- notification(t, model.LedgerEntry{ID: 3, WalletID: walletID, BalanceAfter: 30})
+	assert.Equal(t, int64(2), (<-sub.C).ID)
+	assert.Equal(t, int64(3), (<-sub.C).ID)
+
+	source.ch <- nil
+	assert.Equal(t, int64(1), (<-sub.C).ID)
+	assert.Equal(t, int64(4), (<-sub.C).ID)
+
+	cancel()
+	<-done
+	assert.Empty(t, sub.C)
+	store.AssertExpectations(t)
+}
+
+func TestListener_IgnoresInvalidPayload(t *testing.T) {
+	broker := NewBroker(1)
+	l := NewListener(&fakeSource{}, &MockLedgerStore{}, broker, "balances", zap.NewNop())
+
+	l.handle(&pq.Notification{Extra: "not json"})
+	assert.Equal(t, int64(0), l.lastID)
+}
+
+func TestListener_RunStopsWhenSourceClosed(t *testing.T) {
+	source := &fakeSource{ch: make(chan *pq.Notification)}
+	l := NewListener(source, &MockLedgerStore{}, NewBroker(1), "balances", zap.NewNop())
+	close(source.ch)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.Run(context.Background())
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("listener did not stop")
+	}
+}
@@ -0,0 +1,53 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func TestSetup_FileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.json")
+	shutdown, err := Setup(context.Background(), Config{Exporter: "file", FilePath: path, ServiceName: "wallet-test", SampleRatio: 1})
+	require.NoError(t, err)
+
+	_, span := Tracer().Start(context.Background(), "test-span")
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"Name":"test-span"`)
+	assert.Contains(t, string(data), "wallet-test")
+}
+
+func TestSetup_UnknownExporter(t *testing.T) {
+	_, err := Setup(context.Background(), Config{Exporter: "jaeger"})
+	assert.Error(t, err)
+}
+
+func TestLogFields_FromTraceparent(t *testing.T) {
+	shutdown, err := Setup(context.Background(), Config{Exporter: "none", ServiceName: "wallet-test", SampleRatio: 1})
+	require.NoError(t, err)
+	defer shutdown(context.Background())
+
+	assert.Empty(t, LogFields(context.Background()))
+
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(header))
+	ctx, span := Tracer().Start(ctx, "child")
+	defer span.End()
+
+	fields := LogFields(ctx)
+	require.Len(t, fields, 2)
+	assert.Equal(t, "trace_id", fields[0].Key)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", fields[0].String)
+}
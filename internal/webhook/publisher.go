@@ -0,0 +1,26 @@
+package webhook
+
+import (
+	"context"
+
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+)
+
+type Enqueuer interface {
+	EnqueueWebhookDeliveries(ctx context.Context, event model.Event) (int64, error)
+}
+
+// Publisher plugs webhooks into the outbox relay: each relayed event becomes
+// one pending delivery per matching subscription.
+type Publisher struct {
+	store Enqueuer
+}
+
+func NewPublisher(store Enqueuer) *Publisher {
+	return &Publisher{store: store}
+}
+
+func (p *Publisher) Publish(ctx context.Context, event model.Event) error {
+	_, err := p.store.EnqueueWebhookDeliveries(ctx, event)
+	return err
+}
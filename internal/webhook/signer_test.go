@@ -0,0 +1,31 @@
+package webhook
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign_Verify(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	body := []byte(`{"id":1}`)
+
+	sig := Sign("secret", ts, body)
+	assert.Len(t, sig, 64)
+	assert.True(t, Verify("secret", ts, body, sig))
+	assert.False(t, Verify("other", ts, body, sig))
+	assert.False(t, Verify("secret", ts.Add(time.Second), body, sig))
+	assert.False(t, Verify("secret", ts, []byte(`{"id":2}`), sig))
+}
+
+func TestGenerateSecret(t *testing.T) {
+	a, err := GenerateSecret()
+	assert.NoError(t, err)
+	b, err := GenerateSecret()
+	assert.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(a, "whsec_"))
+	assert.NotEqual(t, a, b)
+}
@@ -0,0 +1,231 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"go.uber.org/zap"
+)
+
+type Store interface {
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]model.WebhookDelivery, error)
+	RecordWebhookSuccess(ctx context.Context, d model.WebhookDelivery, statusCode int) error
+	RecordWebhookFailure(ctx context.Context, d model.WebhookDelivery, statusCode int, cause error, nextAttempt *time.Time, disableAfter int) (bool, error)
+}
+
+// WorkerConfig tunes the worker. Concurrency bounds how many deliveries of a
+// batch are sent at once, so a slow endpoint holds up only its own sends.
+type WorkerConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	Concurrency  int
+	Timeout      time.Duration
+	MaxAttempts  int
+	DisableAfter int
+	MinBackoff   time.Duration
+	MaxBackoff   time.Duration
+}
+
+type Worker struct {
+	store  Store
+	client *http.Client
+	cfg    WorkerConfig
+	lease  time.Duration
+	logger *zap.Logger
+	now    func() time.Time
+}
+
+type deliveryBody struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	WalletID  string          `json:"walletId"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+func NewWorker(store Store, cfg WorkerConfig, logger *zap.Logger) *Worker {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 50
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 8
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 10
+	}
+	if cfg.DisableAfter <= 0 {
+		cfg.DisableAfter = 20
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = 5 * time.Second
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = cfg.MinBackoff
+	}
+	// The lease has to outlast the whole batch: the last send starts after
+	// at most ceil(BatchSize/Concurrency)-1 timeouts, and one more covers it.
+	rounds := (cfg.BatchSize + cfg.Concurrency - 1) / cfg.Concurrency
+	return &Worker{
+		store:  store,
+		client: &http.Client{Timeout: cfg.Timeout},
+		cfg:    cfg,
+		lease:  time.Duration(rounds+1) * cfg.Timeout,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+func (w *Worker) Run(ctx context.Context) {
+	w.logger.Info("webhook worker started", zap.Duration("poll_interval", w.cfg.PollInterval))
+
+	ticker := time.NewTicker(w.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.logger.Info("webhook worker stopped")
+			return
+		case <-ticker.C:
+			if _, err := w.DeliverOnce(ctx); err != nil && ctx.Err() == nil {
+				w.logger.Error("webhook delivery iteration failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+// DeliverOnce claims a batch of due deliveries and sends them, at most
+// Concurrency at a time. It returns how many succeeded and the first error
+// from recording an outcome; the rest of the batch is still sent.
+func (w *Worker) DeliverOnce(ctx context.Context) (int, error) {
+	deliveries, err := w.store.ClaimWebhookDeliveries(ctx, w.cfg.BatchSize, w.lease)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		delivered int
+		firstErr  error
+	)
+	slots := make(chan struct{}, w.cfg.Concurrency)
+	for _, d := range deliveries {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			ok, err := w.deliver(ctx, d)
+			mu.Lock()
+			defer mu.Unlock()
+			if ok {
+				delivered++
+			}
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}()
+	}
+	wg.Wait()
+	return delivered, firstErr
+}
+
+// deliver sends d and records the outcome. It reports whether the endpoint
+// accepted it; the error is from the store only.
+func (w *Worker) deliver(ctx context.Context, d model.WebhookDelivery) (bool, error) {
+	statusCode, err := w.send(ctx, d)
+	if err == nil {
+		if err := w.store.RecordWebhookSuccess(ctx, d, statusCode); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	var next *time.Time
+	if d.Attempts+1 < w.cfg.MaxAttempts {
+		t := w.now().Add(w.backoff(d.Attempts))
+		next = &t
+	}
+	w.logger.Warn("webhook delivery failed",
+		zap.Int64("delivery_id", d.ID),
+		zap.String("subscription_id", d.SubscriptionID.String()),
+		zap.Int("attempts", d.Attempts+1),
+		zap.Int("status", statusCode),
+		zap.Bool("giving_up", next == nil),
+		zap.Error(err))
+
+	disabled, err := w.store.RecordWebhookFailure(ctx, d, statusCode, err, next, w.cfg.DisableAfter)
+	if err != nil {
+		return false, err
+	}
+	if disabled {
+		w.logger.Warn("webhook subscription disabled after repeated failures",
+			zap.String("subscription_id", d.SubscriptionID.String()))
+	}
+	return false, nil
+}
+
+func (w *Worker) send(ctx context.Context, d model.WebhookDelivery) (int, error) {
+	body, err := json.Marshal(deliveryBody{
+		ID:        d.EventID,
+		Type:      d.EventType,
+		WalletID:  d.WalletID.String(),
+		Payload:   d.Payload,
+		CreatedAt: d.CreatedAt,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal webhook body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to build webhook request: %w", err)
+	}
+
+	ts := w.now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, strconv.FormatInt(d.ID, 10))
+	req.Header.Set(HeaderEvent, d.EventType)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts.Unix(), 10))
+	req.Header.Set(HeaderSignature, "sha256="+Sign(d.Secret, ts, body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook endpoint returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (w *Worker) backoff(attempts int) time.Duration {
+	d := w.cfg.MinBackoff
+	for i := 0; i < attempts; i++ {
+		d *= 2
+		if d >= w.cfg.MaxBackoff {
+			return w.cfg.MaxBackoff
+		}
+	}
+	return d
+}
@@ -0,0 +1,245 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/yokitheyo/go_wallet_test/internal/model"
+	"go.uber.org/zap"
+)
+
+type MockStore struct {
+	mock.Mock
+}
+
+func (m *MockStore) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]model.WebhookDelivery, error) {
+	args := m.Called(ctx, limit, lease)
+	return args.Get(0).([]model.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockStore) RecordWebhookSuccess(ctx context.Context, d model.WebhookDelivery, statusCode int) error {
+	return m.Called(ctx, d, statusCode).Error(0)
+}
+
+func (m *MockStore) RecordWebhookFailure(ctx context.Context, d model.WebhookDelivery, statusCode int, cause error, nextAttempt *time.Time, disableAfter int) (bool, error) {
+	args := m.Called(ctx, d, statusCode, cause, nextAttempt, disableAfter)
+	return args.Bool(0), args.Error(1)
+}
+
+func newTestDelivery(url string, attempts int) model.WebhookDelivery {
+	return model.WebhookDelivery{
+		ID:             11,
+		SubscriptionID: uuid.New(),
+		EventID:        5,
+		EventType:      model.EventBalanceChanged,
+		WalletID:       uuid.New(),
+		Payload:        json.RawMessage(`{"balance":100}`),
+		Attempts:       attempts,
+		URL:            url,
+		Secret:         "topsecret",
+	}
+}
+
+func TestWorker_DeliverOnce_SignedSuccess(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ts, _ := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		sig := strings.TrimPrefix(r.Header.Get(HeaderSignature), "sha256=")
+
+		assert.Equal(t, "11", r.Header.Get(HeaderID))
+		assert.Equal(t, model.EventBalanceChanged, r.Header.Get(HeaderEvent))
+		assert.True(t, Verify("topsecret", time.Unix(ts, 0), body, sig))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	d := newTestDelivery(server.URL, 0)
+	store := &MockStore{}
+	// 50 deliveries, 8 at a time, take at most 7 rounds of 10s; the lease adds one more.
+	store.On("ClaimWebhookDeliveries", mock.Anything, 50, 80*time.Second).Return([]model.WebhookDelivery{d}, nil)
+	store.On("RecordWebhookSuccess", mock.Anything, d, http.StatusNoContent).Return(nil)
+
+	w := NewWorker(store, WorkerConfig{}, zap.NewNop())
+	w.now = func() time.Time { return now }
+
+	delivered, err := w.DeliverOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	store.AssertExpectations(t)
+}
+
+func TestWorker_DeliverOnce_FailureSchedulesRetry(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	d := newTestDelivery(server.URL, 2)
+	expectedNext := now.Add(4 * time.Second)
+
+	store := &MockStore{}
+	store.On("ClaimWebhookDeliveries", mock.Anything, 50, mock.Anything).Return([]model.WebhookDelivery{d}, nil)
+	store.On("RecordWebhookFailure", mock.Anything, d, http.StatusInternalServerError, mock.Anything, &expectedNext, 3).Return(true, nil)
+
+	w := NewWorker(store, WorkerConfig{MinBackoff: time.Second, MaxBackoff: time.Minute, DisableAfter: 3}, zap.NewNop())
+	w.now = func() time.Time { return now }
+
+	delivered, err := w.DeliverOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, delivered)
+	store.AssertExpectations(t)
+}
+
+func TestWorker_DeliverOnce_GivesUpAfterMaxAttempts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer server.Close()
+
+	d := newTestDelivery(server.URL, 2)
+
+	store := &MockStore{}
+	store.On("ClaimWebhookDeliveries", mock.Anything, 50, mock.Anything).Return([]model.WebhookDelivery{d}, nil)
+	store.On("RecordWebhookFailure", mock.Anything, d, http.StatusGone, mock.Anything, (*time.Time)(nil), 20).Return(false, nil)
+
+	w := NewWorker(store, WorkerConfig{MaxAttempts: 3}, zap.NewNop())
+
+	_, err := w.DeliverOnce(context.Background())
+	assert.NoError(t, err)
+	store.AssertExpectations(t)
+}
+
+// claimStore keeps deliveries in memory and claims them the way the
+// Postgres query does: a claimed delivery is not due again until its lease
+// runs out or its outcome is recorded.
+type claimStore struct {
+	mu         sync.Mutex
+	deliveries map[int64]*model.WebhookDelivery
+	done       map[int64]bool
+}
+
+func (s *claimStore) ClaimWebhookDeliveries(_ context.Context, limit int, lease time.Duration) ([]model.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var claimed []model.WebhookDelivery
+	for _, d := range s.deliveries {
+		if len(claimed) == limit {
+			break
+		}
+		if s.done[d.ID] || d.NextAttemptAt.After(time.Now()) {
+			continue
+		}
+		d.NextAttemptAt = time.Now().Add(lease)
+		claimed = append(claimed, *d)
+	}
+	return claimed, nil
+}
+
+func (s *claimStore) RecordWebhookSuccess(_ context.Context, d model.WebhookDelivery, _ int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done[d.ID] = true
+	return nil
+}
+
+func (s *claimStore) RecordWebhookFailure(context.Context, model.WebhookDelivery, int, error, *time.Time, int) (bool, error) {
+	return false, nil
+}
+
+func TestWorker_TwoReplicasSendEachDeliveryOnce(t *testing.T) {
+	var mu sync.Mutex
+	hits := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		hits[r.Header.Get(HeaderID)]++
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	store := &claimStore{deliveries: map[int64]*model.WebhookDelivery{}, done: map[int64]bool{}}
+	for id := int64(1); id <= 40; id++ {
+		d := newTestDelivery(server.URL, 0)
+		d.ID = id
+		store.deliveries[id] = &d
+	}
+
+	var delivered atomic.Int64
+	var wg sync.WaitGroup
+	for range 2 {
+		replica := NewWorker(store, WorkerConfig{BatchSize: 7, Concurrency: 3}, zap.NewNop())
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for delivered.Load() < 40 {
+				n, err := replica.DeliverOnce(context.Background())
+				assert.NoError(t, err)
+				delivered.Add(int64(n))
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(40), delivered.Load())
+	assert.Len(t, hits, 40)
+	for id, n := range hits {
+		assert.Equal(t, 1, n, "delivery %s sent more than once", id)
+	}
+}
+
+func TestWorker_SlowEndpointDoesNotBlockOthers(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer slow.Close()
+	var fastHits atomic.Int32
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fastHits.Add(1) == 3 {
+			close(release)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer fast.Close()
+
+	deliveries := []model.WebhookDelivery{newTestDelivery(slow.URL, 0)}
+	for id := int64(12); id < 15; id++ {
+		d := newTestDelivery(fast.URL, 0)
+		d.ID = id
+		deliveries = append(deliveries, d)
+	}
+	store := &MockStore{}
+	store.On("ClaimWebhookDeliveries", mock.Anything, 50, mock.Anything).Return(deliveries, nil)
+	store.On("RecordWebhookSuccess", mock.Anything, mock.Anything, http.StatusNoContent).Return(nil)
+
+	// The slow endpoint answers only after every fast one was reached, so a
+	// worker sending one delivery at a time would hang here.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		delivered, err := NewWorker(store, WorkerConfig{Concurrency: 2}, zap.NewNop()).DeliverOnce(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 4, delivered)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a slow endpoint held up the other deliveries")
+	}
+}
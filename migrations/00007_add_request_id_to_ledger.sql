@@ -0,0 +1,6 @@
+-- +goose Up
+ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS request_id TEXT;
+CREATE INDEX IF NOT EXISTS ledger_entries_request_id_idx ON ledger_entries (request_id) WHERE request_id IS NOT NULL;
+-- +goose Down
+DROP INDEX IF EXISTS ledger_entries_request_id_idx;
+ALTER TABLE ledger_entries DROP COLUMN IF EXISTS request_id;
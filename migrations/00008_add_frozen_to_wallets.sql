@@ -0,0 +1,6 @@
+-- +goose Up
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS frozen_at TIMESTAMPTZ;
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS frozen_reason TEXT;
+-- +goose Down
+ALTER TABLE wallets DROP COLUMN IF EXISTS frozen_reason;
+ALTER TABLE wallets DROP COLUMN IF EXISTS frozen_at;
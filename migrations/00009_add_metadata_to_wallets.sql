@@ -0,0 +1,18 @@
+-- +goose Up
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS owner_id TEXT;
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS external_ref TEXT;
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS display_name TEXT;
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS labels JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE wallets ADD CONSTRAINT wallets_external_ref_owner_check CHECK (external_ref IS NULL OR owner_id IS NOT NULL);
+CREATE UNIQUE INDEX IF NOT EXISTS wallets_owner_external_ref_idx ON wallets(owner_id, external_ref) WHERE external_ref IS NOT NULL;
+-- +goose Down
+DROP INDEX IF EXISTS wallets_owner_external_ref_idx;
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_external_ref_owner_check;
+ALTER TABLE wallets DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE wallets DROP COLUMN IF EXISTS created_at;
+ALTER TABLE wallets DROP COLUMN IF EXISTS labels;
+ALTER TABLE wallets DROP COLUMN IF EXISTS display_name;
+ALTER TABLE wallets DROP COLUMN IF EXISTS external_ref;
+ALTER TABLE wallets DROP COLUMN IF EXISTS owner_id;
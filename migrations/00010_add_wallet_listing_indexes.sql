@@ -0,0 +1,13 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+CREATE INDEX CONCURRENTLY IF NOT EXISTS wallets_created_at_idx ON wallets(created_at, wallet_id);
+CREATE INDEX CONCURRENTLY IF NOT EXISTS wallets_balance_idx ON wallets(balance, wallet_id);
+CREATE INDEX CONCURRENTLY IF NOT EXISTS wallets_owner_created_at_idx ON wallets(owner_id, created_at, wallet_id) WHERE owner_id IS NOT NULL;
+CREATE INDEX CONCURRENTLY IF NOT EXISTS wallets_frozen_idx ON wallets(frozen_at) WHERE frozen_at IS NOT NULL;
+CREATE INDEX CONCURRENTLY IF NOT EXISTS wallets_labels_idx ON wallets USING GIN (labels);
+-- +goose Down
+DROP INDEX CONCURRENTLY IF EXISTS wallets_labels_idx;
+DROP INDEX CONCURRENTLY IF EXISTS wallets_frozen_idx;
+DROP INDEX CONCURRENTLY IF EXISTS wallets_owner_created_at_idx;
+DROP INDEX CONCURRENTLY IF EXISTS wallets_balance_idx;
+DROP INDEX CONCURRENTLY IF EXISTS wallets_created_at_idx;
@@ -0,0 +1,6 @@
+-- +goose Up
+ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS transfer_id UUID;
+CREATE INDEX IF NOT EXISTS ledger_entries_transfer_id_idx ON ledger_entries (transfer_id) WHERE transfer_id IS NOT NULL;
+-- +goose Down
+DROP INDEX IF EXISTS ledger_entries_transfer_id_idx;
+ALTER TABLE ledger_entries DROP COLUMN IF EXISTS transfer_id;
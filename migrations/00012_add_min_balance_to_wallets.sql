@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS min_balance BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE wallets ADD CONSTRAINT wallets_min_balance_check CHECK (min_balance <= 0);
+CREATE TABLE IF NOT EXISTS wallet_min_balance_changes (
+    id BIGSERIAL PRIMARY KEY,
+    wallet_id UUID NOT NULL,
+    old_min_balance BIGINT NOT NULL,
+    new_min_balance BIGINT NOT NULL,
+    reason TEXT,
+    request_id TEXT,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS wallet_min_balance_changes_wallet_idx ON wallet_min_balance_changes (wallet_id, id);
+-- +goose Down
+DROP TABLE IF EXISTS wallet_min_balance_changes;
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_min_balance_check;
+ALTER TABLE wallets DROP COLUMN IF EXISTS min_balance;
@@ -0,0 +1,8 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS signature_nonces (
+    key TEXT PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS signature_nonces_expires_at_idx ON signature_nonces(expires_at);
+-- +goose Down
+DROP TABLE IF EXISTS signature_nonces;
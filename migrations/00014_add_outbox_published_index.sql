@@ -0,0 +1,5 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+CREATE INDEX CONCURRENTLY IF NOT EXISTS outbox_published_at_idx ON outbox(published_at) WHERE published_at IS NOT NULL;
+-- +goose Down
+DROP INDEX CONCURRENTLY IF EXISTS outbox_published_at_idx;